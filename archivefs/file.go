@@ -0,0 +1,295 @@
+package archivefs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// dirFile is an open directory of an archive.
+type dirFile struct {
+	fs    *Fs
+	name  string
+	inner string
+	entry *entry
+	read  int
+}
+
+func (f *dirFile) Close() error {
+	return nil
+}
+
+func (f *dirFile) Name() string {
+	return f.name
+}
+
+func (f *dirFile) Stat() (os.FileInfo, error) {
+	return f.fs.fileInfo(f.inner, f.entry), nil
+}
+
+func (f *dirFile) Readdir(count int) ([]os.FileInfo, error) {
+	names, err := f.Readdirnames(count)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(names))
+	for _, name := range names {
+		inner := path.Join(f.inner, name)
+		infos = append(infos, f.fs.fileInfo(inner, f.fs.idx.entries[inner]))
+	}
+
+	return infos, nil
+}
+
+func (f *dirFile) Readdirnames(count int) ([]string, error) {
+	rest := f.entry.children[f.read:]
+	if count <= 0 {
+		f.read += len(rest)
+		return append([]string{}, rest...), nil
+	}
+
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(rest) {
+		count = len(rest)
+	}
+	f.read += count
+
+	return append([]string{}, rest[:count]...), nil
+}
+
+func (f *dirFile) Read([]byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: f.name, Err: errIsDir}
+}
+
+func (f *dirFile) ReadAt([]byte, int64) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: f.name, Err: errIsDir}
+}
+
+func (f *dirFile) Seek(int64, int) (int64, error) {
+	return 0, nil
+}
+
+func (f *dirFile) Write([]byte) (int, error) {
+	return 0, readOnly("write", f.name)
+}
+
+func (f *dirFile) WriteAt([]byte, int64) (int, error) {
+	return 0, readOnly("write", f.name)
+}
+
+func (f *dirFile) WriteString(string) (int, error) {
+	return 0, readOnly("write", f.name)
+}
+
+func (f *dirFile) Sync() error {
+	return nil
+}
+
+func (f *dirFile) Truncate(int64) error {
+	return readOnly("truncate", f.name)
+}
+
+// memberFile is an open file of an archive. The entries of compressed
+// archives can only be read sequentially, so seeking backwards opens
+// the entry again and seeking forward discards the data in between.
+type memberFile struct {
+	fs    *Fs
+	name  string
+	inner string
+	entry *entry
+
+	rc  io.ReadCloser
+	pos int64 // position of rc
+	off int64 // offset of the next read
+}
+
+func (f *memberFile) Close() error {
+	if f.rc == nil {
+		return nil
+	}
+
+	err := f.rc.Close()
+	f.rc = nil
+	return err
+}
+
+func (f *memberFile) Name() string {
+	return f.name
+}
+
+func (f *memberFile) Stat() (os.FileInfo, error) {
+	return f.fs.fileInfo(f.inner, f.entry), nil
+}
+
+func (f *memberFile) Read(b []byte) (int, error) {
+	if f.off >= f.entry.size {
+		return 0, io.EOF
+	}
+
+	if f.rc == nil || f.pos > f.off {
+		if err := f.Close(); err != nil {
+			return 0, err
+		}
+
+		rc, err := f.fs.openEntry(f.entry)
+		if err != nil {
+			return 0, err
+		}
+		f.rc, f.pos = rc, 0
+	}
+
+	if f.pos < f.off {
+		n, err := io.CopyN(io.Discard, f.rc, f.off-f.pos)
+		f.pos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.rc.Read(b)
+	f.pos += int64(n)
+	f.off += int64(n)
+	return n, err
+}
+
+func (f *memberFile) ReadAt(b []byte, off int64) (int, error) {
+	saved := f.off
+	defer func() { f.off = saved }()
+
+	f.off = off
+	n, err := io.ReadFull(f, b)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *memberFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += f.entry.size
+	default:
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: os.ErrInvalid}
+	}
+
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: os.ErrInvalid}
+	}
+
+	f.off = offset
+	return offset, nil
+}
+
+func (f *memberFile) Readdir(int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.name, Err: errNotDir}
+}
+
+func (f *memberFile) Readdirnames(int) ([]string, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.name, Err: errNotDir}
+}
+
+func (f *memberFile) Write([]byte) (int, error) {
+	return 0, readOnly("write", f.name)
+}
+
+func (f *memberFile) WriteAt([]byte, int64) (int, error) {
+	return 0, readOnly("write", f.name)
+}
+
+func (f *memberFile) WriteString(string) (int, error) {
+	return 0, readOnly("write", f.name)
+}
+
+func (f *memberFile) Sync() error {
+	return nil
+}
+
+func (f *memberFile) Truncate(int64) error {
+	return readOnly("truncate", f.name)
+}
+
+// openEntry returns a reader of the content of e. Tar archives are
+// scanned from the start until the entry is found.
+func (fs *Fs) openEntry(e *entry) (io.ReadCloser, error) {
+	file, err := fs.base.Open(fs.archive)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := fs.openEntryIn(file, e)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{rc, closers{rc, file}}, nil
+}
+
+func (fs *Fs) openEntryIn(file io.ReaderAt, e *entry) (io.ReadCloser, error) {
+	if fs.idx.zip {
+		zr, err := zip.NewReader(file, fs.info.Size())
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", err, errors.ErrUnsupportedArchive)
+		}
+		for _, f := range zr.File {
+			if f.Name == e.orig {
+				return f.Open()
+			}
+		}
+		return nil, os.ErrNotExist
+	}
+
+	reader, err := tarReader(fs.archive)
+	if err != nil {
+		return nil, err
+	}
+	if err = reader.Open(io.NewSectionReader(file, 0, fs.info.Size()), fs.info.Size()); err != nil {
+		return nil, fmt.Errorf("%v: %w", err, errors.ErrUnsupportedArchive)
+	}
+
+	for {
+		f, err := reader.Read()
+		if err != nil {
+			reader.Close()
+			if err == io.EOF {
+				err = os.ErrNotExist
+			}
+			return nil, err
+		}
+
+		if h, ok := f.Header.(*tar.Header); ok && h.Name == e.orig {
+			return struct {
+				io.Reader
+				io.Closer
+			}{f, closers{f, reader}}, nil
+		}
+		f.Close()
+	}
+}
+
+// closers closes all its members, returning the first error.
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var first error
+	for _, closer := range c {
+		if err := closer.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,246 @@
+// Package archivefs exposes the content of zip and tar archives as
+// read-only directory trees.
+package archivefs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+)
+
+// Split returns the path of the archive that name goes through, if
+// any. A name ending with a slash goes through the archive it names.
+func Split(fs afero.Fs, name string) (string, bool) {
+	clean := path.Clean("/" + name)
+	if clean == "/" {
+		return "", false
+	}
+
+	elems := strings.Split(clean, "/")[1:]
+	archive := ""
+	for i, elem := range elems {
+		archive += "/" + elem
+		if i == len(elems)-1 && !strings.HasSuffix(name, "/") {
+			break
+		}
+		if !fileutils.IsArchive(elem) {
+			continue
+		}
+
+		info, err := fs.Stat(archive)
+		if err != nil {
+			return "", false
+		}
+		if info.Mode().IsRegular() {
+			return archive, true
+		}
+	}
+
+	return "", false
+}
+
+// Fs is a read-only afero.Fs over the entries of an archive. It is
+// mounted at the path of the archive in the base filesystem, so the
+// names it accepts are the same the base filesystem would use.
+type Fs struct {
+	base    afero.Fs
+	archive string
+	info    os.FileInfo
+	idx     *index
+}
+
+// New reads the index of the archive at name in base.
+func New(base afero.Fs, name string) (*Fs, error) {
+	name = path.Clean("/" + name)
+
+	info, err := base.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	key := ""
+	if realName, ok := realPath(base, name); ok {
+		key = fmt.Sprintf("%s:%d:%d", realName, info.Size(), info.ModTime().UnixNano())
+	}
+
+	idx, ok := cache.get(key)
+	if !ok {
+		idx, err = buildIndex(base, name, info.ModTime())
+		if err != nil {
+			return nil, err
+		}
+		if key != "" {
+			cache.put(key, idx)
+		}
+	}
+
+	return &Fs{base: base, archive: name, info: info, idx: idx}, nil
+}
+
+// inner returns the path of name relative to the archive root.
+func (fs *Fs) inner(name string) (string, bool) {
+	name = path.Clean("/" + name)
+	switch {
+	case name == fs.archive:
+		return "/", true
+	case strings.HasPrefix(name, fs.archive+"/"):
+		return strings.TrimPrefix(name, fs.archive), true
+	default:
+		return "", false
+	}
+}
+
+func (fs *Fs) lookup(op, name string) (string, *entry, error) {
+	inner, ok := fs.inner(name)
+	if !ok {
+		return "", nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+
+	e, ok := fs.idx.entries[inner]
+	if !ok {
+		return "", nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+
+	return inner, e, nil
+}
+
+func (fs *Fs) fileInfo(inner string, e *entry) os.FileInfo {
+	if inner == "/" {
+		return &fileInfo{name: fs.info.Name(), entry: e}
+	}
+	return &fileInfo{name: path.Base(inner), entry: e}
+}
+
+// RealPath returns a path identifying name on the host, made of
+// the real path of the archive followed by the entry path.
+func (fs *Fs) RealPath(name string) (string, error) {
+	inner, ok := fs.inner(name)
+	if !ok {
+		return "", os.ErrNotExist
+	}
+
+	realName, ok := realPath(fs.base, fs.archive)
+	if !ok {
+		realName = fs.archive
+	}
+
+	return realName + strings.TrimSuffix(inner, "/"), nil
+}
+
+func (fs *Fs) Name() string {
+	return "archivefs"
+}
+
+func (fs *Fs) Stat(name string) (os.FileInfo, error) {
+	inner, e, err := fs.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.fileInfo(inner, e), nil
+}
+
+func (fs *Fs) Open(name string) (afero.File, error) {
+	inner, e, err := fs.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.isDir {
+		return &dirFile{fs: fs, name: name, inner: inner, entry: e}, nil
+	}
+
+	return &memberFile{fs: fs, name: name, inner: inner, entry: e}, nil
+}
+
+func (fs *Fs) OpenFile(name string, flag int, _ os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, readOnly("open", name)
+	}
+
+	return fs.Open(name)
+}
+
+func (fs *Fs) Create(name string) (afero.File, error) {
+	return nil, readOnly("create", name)
+}
+
+func (fs *Fs) Mkdir(name string, _ os.FileMode) error {
+	return readOnly("mkdir", name)
+}
+
+func (fs *Fs) MkdirAll(name string, _ os.FileMode) error {
+	return readOnly("mkdir", name)
+}
+
+func (fs *Fs) Remove(name string) error {
+	return readOnly("remove", name)
+}
+
+func (fs *Fs) RemoveAll(name string) error {
+	return readOnly("remove", name)
+}
+
+func (fs *Fs) Rename(oldname, _ string) error {
+	return readOnly("rename", oldname)
+}
+
+func (fs *Fs) Chmod(name string, _ os.FileMode) error {
+	return readOnly("chmod", name)
+}
+
+func (fs *Fs) Chown(name string, _, _ int) error {
+	return readOnly("chown", name)
+}
+
+func (fs *Fs) Chtimes(name string, _, _ time.Time) error {
+	return readOnly("chtimes", name)
+}
+
+func readOnly(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+}
+
+func realPath(fs afero.Fs, name string) (string, bool) {
+	realPathFs, ok := fs.(interface {
+		RealPath(name string) (string, error)
+	})
+	if !ok {
+		return "", false
+	}
+
+	realName, err := realPathFs.RealPath(name)
+	return realName, err == nil
+}
+
+type fileInfo struct {
+	name  string
+	entry *entry
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.entry.size }
+func (i *fileInfo) ModTime() time.Time { return i.entry.modTime }
+func (i *fileInfo) IsDir() bool        { return i.entry.isDir }
+func (i *fileInfo) Sys() interface{}   { return nil }
+
+func (i *fileInfo) Mode() os.FileMode {
+	if i.entry.isDir {
+		return os.ModeDir | 0555 //nolint:gomnd
+	}
+	return 0444 //nolint:gomnd
+}
+
+// errNotDir and errIsDir are returned by the operations that
+// don't apply to the kind of file they are called on.
+var (
+	errNotDir = syscall.ENOTDIR
+	errIsDir  = syscall.EISDIR
+)
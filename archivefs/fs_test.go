@@ -0,0 +1,141 @@
+package archivefs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+var testEntries = map[string]string{
+	"a.txt":         "hello",
+	"dir/b.txt":     "0123456789",
+	"dir/sub/c.txt": "c",
+}
+
+func writeZip(t *testing.T, fs afero.Fs, name string) {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for entry, content := range testEntries {
+		w, err := zw.Create(entry)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, afero.WriteFile(fs, name, buf.Bytes(), 0644))
+}
+
+func writeTarGz(t *testing.T, fs afero.Fs, name string) {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for entry, content := range testEntries {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     entry,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	require.NoError(t, afero.WriteFile(fs, name, buf.Bytes(), 0644))
+}
+
+func TestSplit(t *testing.T) {
+	base := afero.NewMemMapFs()
+	require.NoError(t, base.MkdirAll("/data/folder.zip", 0755))
+	writeZip(t, base, "/data/archive.zip")
+
+	testCases := map[string]struct {
+		name   string
+		want   string
+		wantOk bool
+	}{
+		"entry":             {name: "/data/archive.zip/dir/b.txt", want: "/data/archive.zip", wantOk: true},
+		"archive root":      {name: "/data/archive.zip/", want: "/data/archive.zip", wantOk: true},
+		"archive itself":    {name: "/data/archive.zip"},
+		"directory named":   {name: "/data/folder.zip/x"},
+		"missing archive":   {name: "/data/missing.zip/a.txt"},
+		"regular directory": {name: "/data/"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, ok := Split(base, tc.name)
+			require.Equal(t, tc.wantOk, ok)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestFs(t *testing.T) {
+	writers := map[string]func(t *testing.T, fs afero.Fs, name string){
+		"/archive.zip":    writeZip,
+		"/archive.tar.gz": writeTarGz,
+	}
+
+	for archive, write := range writers {
+		t.Run(archive, func(t *testing.T) {
+			base := afero.NewMemMapFs()
+			write(t, base, archive)
+
+			fs, err := New(base, archive)
+			require.NoError(t, err)
+
+			infos, err := afero.ReadDir(fs, archive)
+			require.NoError(t, err)
+			names := []string{}
+			for _, info := range infos {
+				names = append(names, info.Name())
+			}
+			sort.Strings(names)
+			require.Equal(t, []string{"a.txt", "dir"}, names)
+
+			info, err := fs.Stat(archive + "/dir/sub")
+			require.NoError(t, err)
+			require.True(t, info.IsDir())
+
+			content, err := afero.ReadFile(fs, archive+"/dir/b.txt")
+			require.NoError(t, err)
+			require.Equal(t, "0123456789", string(content))
+
+			file, err := fs.Open(archive + "/dir/b.txt")
+			require.NoError(t, err)
+			defer file.Close()
+
+			_, err = file.Seek(6, io.SeekStart)
+			require.NoError(t, err)
+			buf := make([]byte, 4)
+			_, err = io.ReadFull(file, buf)
+			require.NoError(t, err)
+			require.Equal(t, "6789", string(buf))
+
+			_, err = file.Seek(2, io.SeekStart)
+			require.NoError(t, err)
+			_, err = io.ReadFull(file, buf)
+			require.NoError(t, err)
+			require.Equal(t, "2345", string(buf))
+
+			_, err = fs.Stat(archive + "/missing.txt")
+			require.True(t, os.IsNotExist(err))
+
+			err = fs.Remove(archive + "/a.txt")
+			require.True(t, os.IsPermission(err))
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package archivefs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mholt/archiver/v3"
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// maxCachedIndexes is the number of archive indexes kept in memory.
+const maxCachedIndexes = 16
+
+// entry is a file or a directory inside an archive.
+type entry struct {
+	// orig is the name of the entry as stored in the archive.
+	orig     string
+	size     int64
+	modTime  time.Time
+	isDir    bool
+	children []string
+}
+
+// index maps the cleaned paths of the archive entries, relative
+// to the archive root, to the entries.
+type index struct {
+	zip     bool
+	entries map[string]*entry
+}
+
+func buildIndex(fs afero.Fs, name string, modTime time.Time) (*index, error) {
+	file, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &index{entries: map[string]*entry{
+		"/": {isDir: true, modTime: modTime},
+	}}
+
+	if isZip(name) {
+		idx.zip = true
+		zr, err := zip.NewReader(file, info.Size()) //nolint:govet
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", err, errors.ErrUnsupportedArchive)
+		}
+		for _, f := range zr.File {
+			idx.add(f.Name, f.Mode(), f.FileInfo().Size(), f.Modified)
+		}
+	} else {
+		reader, err := tarReader(name) //nolint:govet
+		if err != nil {
+			return nil, err
+		}
+		if err = reader.Open(file, info.Size()); err != nil {
+			return nil, fmt.Errorf("%v: %w", err, errors.ErrUnsupportedArchive)
+		}
+		defer reader.Close()
+
+		for {
+			f, err := reader.Read() //nolint:govet
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if h, ok := f.Header.(*tar.Header); ok {
+				idx.add(h.Name, h.FileInfo().Mode(), h.Size, h.ModTime)
+			}
+			f.Close()
+		}
+	}
+
+	for _, e := range idx.entries {
+		sort.Strings(e.children)
+	}
+
+	return idx, nil
+}
+
+// add records an entry and its missing parent directories. Links and
+// special files are left out.
+func (idx *index) add(orig string, mode os.FileMode, size int64, modTime time.Time) {
+	name := strings.ReplaceAll(orig, "\\", "/")
+	isDir := mode.IsDir() || strings.HasSuffix(name, "/")
+	if !isDir && !mode.IsRegular() {
+		return
+	}
+
+	name = path.Clean("/" + name)
+	if name == "/" {
+		return
+	}
+
+	e, ok := idx.entries[name]
+	switch {
+	case !ok:
+		e = &entry{}
+		idx.entries[name] = e
+		idx.link(name, modTime)
+	case e.isDir != isDir:
+		// keep the first of two entries with the same name
+		return
+	}
+
+	e.orig = orig
+	e.modTime = modTime
+	e.isDir = isDir
+	if !isDir {
+		e.size = size
+	}
+}
+
+// link adds name to the children of its parent, creating the
+// parent directories that aren't in the archive.
+func (idx *index) link(name string, modTime time.Time) {
+	for child := name; child != "/"; child = path.Dir(child) {
+		parent, ok := idx.entries[path.Dir(child)]
+		if !ok {
+			parent = &entry{isDir: true, modTime: modTime}
+			idx.entries[path.Dir(child)] = parent
+		}
+		parent.children = append(parent.children, path.Base(child))
+		if ok {
+			return
+		}
+	}
+}
+
+func isZip(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".zip")
+}
+
+func tarReader(name string) (archiver.Reader, error) {
+	format, err := archiver.ByExtension(strings.ToLower(name))
+	if err != nil {
+		return nil, errors.ErrUnsupportedArchive
+	}
+
+	switch r := format.(type) {
+	case *archiver.Tar, *archiver.TarGz, *archiver.TarBz2, *archiver.TarXz,
+		*archiver.TarZstd, *archiver.TarLz4, *archiver.TarSz:
+		return r.(archiver.Reader), nil
+	default:
+		return nil, errors.ErrUnsupportedArchive
+	}
+}
+
+// indexCache keeps the most recently used indexes, keyed by the
+// location, the size and the modification time of the archives.
+type indexCache struct {
+	mu      sync.Mutex
+	keys    []string
+	indexes map[string]*index
+}
+
+var cache = &indexCache{indexes: map[string]*index{}}
+
+func (c *indexCache) get(key string) (*index, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx, ok := c.indexes[key]
+	if ok {
+		c.touch(key)
+	}
+	return idx, ok
+}
+
+func (c *indexCache) put(key string, idx *index) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.indexes[key]; !ok && len(c.keys) >= maxCachedIndexes {
+		delete(c.indexes, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+	c.indexes[key] = idx
+	c.touch(key)
+}
+
+// touch moves key to the end of the eviction order. The caller must hold c.mu.
+func (c *indexCache) touch(key string) {
+	for i, k := range c.keys {
+		if k == key {
+			c.keys = append(c.keys[:i], c.keys[i+1:]...)
+			break
+		}
+	}
+	c.keys = append(c.keys, key)
+}
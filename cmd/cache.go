@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/filebrowser/filebrowser/v2/diskcache"
+)
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.PersistentFlags().String("cache-dir", "", "file cache directory")
+	cacheCmd.PersistentFlags().String("cache-mode", "disk", "cache mode of the server: disk or tiered")
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "File cache management utility",
+	Long: `File cache statistics and eviction utility.
+
+The commands act on the disk cache of the disk mode, and on the disk
+cache of the instance for the tiered mode. The entries of the redis
+mode are evicted by Redis, whose INFO command gives the statistics.`,
+	Args: cobra.NoArgs,
+}
+
+// openFileCache opens the cache of the cache-dir flag, with the limits
+// of the cache-max-size and cache-max-age flags if defined.
+func openFileCache(flags *pflag.FlagSet) *diskcache.FileCache {
+	switch cacheMode := mustGetString(flags, "cache-mode"); cacheMode {
+	case "disk", "tiered":
+	case "redis":
+		log.Fatal("the cache of the redis mode is managed by Redis")
+	default:
+		log.Fatalf("unknown cache mode %s", cacheMode)
+	}
+
+	cacheDir := mustGetString(flags, "cache-dir")
+	if cacheDir == "" {
+		log.Fatal("--cache-dir is required")
+	}
+
+	var opts []diskcache.Option
+	if maxSize, err := flags.GetInt64("cache-max-size"); err == nil {
+		opts = append(opts, diskcache.WithMaxSize(maxSize))
+	}
+	if maxAge, err := flags.GetDuration("cache-max-age"); err == nil {
+		opts = append(opts, diskcache.WithMaxAge(maxAge))
+	}
+
+	return diskcache.New(afero.NewOsFs(), cacheDir, opts...)
+}
+
+func printCacheStats(stats diskcache.Stats) {
+	fmt.Printf("Entries:\t%d\n", stats.Entries)
+	fmt.Printf("Bytes:\t\t%d\n", stats.Bytes)
+	fmt.Printf("Hits:\t\t%d\n", stats.Hits)
+	fmt.Printf("Misses:\t\t%d\n", stats.Misses)
+	fmt.Printf("Hit rate:\t%.1f%%\n", stats.HitRate*100) //nolint:gomnd
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cacheCmd.AddCommand(cacheCleanCmd)
+
+	cacheCleanCmd.Flags().Int64("cache-max-size", 0, "size in bytes above which the least recently used entries are evicted (0 for no limit)")
+	cacheCleanCmd.Flags().Duration("cache-max-age", 0, "time after which the unused entries are evicted (0 for no limit)")
+}
+
+var cacheCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Evict the cache entries",
+	Long: `Evict the cache entries unused for longer than --cache-max-age,
+then the least recently used ones until the cache fits in
+--cache-max-size, just like the janitor of the server does:
+
+  filebrowser cache clean --cache-dir /var/cache/filebrowser --cache-max-size 10000000000`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cache := openFileCache(cmd.Flags())
+
+		evicted, err := cache.Evict(ctx)
+		checkErr(err)
+		fmt.Printf("%d entries evicted\n", evicted)
+		printCacheStats(cache.Stats())
+	},
+}
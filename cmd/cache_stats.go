@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cacheCmd.AddCommand(cacheStatsCmd)
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Print the cache statistics",
+	Long: `Print the number of entries and bytes of the cache, and its hits
+and misses as of the last run of the janitor of the server. The cache
+is left untouched.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cache := openFileCache(cmd.Flags())
+		printCacheStats(cache.Stats())
+	},
+}
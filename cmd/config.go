@@ -3,6 +3,9 @@ package cmd
 import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+
+	"github.com/filebrowser/filebrowser/v2/preview"
+	"github.com/filebrowser/filebrowser/v2/settings"
 )
 
 func init() {
@@ -32,4 +35,14 @@ func addConfigFlags(flags *pflag.FlagSet) {
 	flags.String("branding.files", "", "path to directory with images and custom styles")
 	flags.Bool("branding.disableExternal", false, "disable external links such as GitHub links")
 	flags.Bool("branding.disableUsedPercentage", false, "disable used disk percentage graph")
+
+	flags.Bool("trash.disabled", false, "delete files permanently instead of moving them to the trash")
+	flags.Duration("trash.retention", settings.DefaultTrashRetention, "how long deleted files are kept in the trash (negative keeps them forever, 0 for the default)")
+
+	flags.Int("versions.maxCount", settings.DefaultVersionsMaxCount, "number of previous versions kept for each file (negative to keep them by age only)")
+	flags.Duration("versions.maxAge", 0, "keep every previous version younger than this")
+
+	flags.Int("previews.maxConcurrent", preview.DefaultMaxConcurrent, "number of preview generators running at once")
+	flags.Duration("previews.timeout", preview.DefaultTimeout, "time after which a preview generator is killed")
+	flags.Bool("previews.contentKeys", false, "key the previews by the hash of the contents of the files, to share them between identical files")
 }
@@ -36,6 +36,19 @@ override the options.`,
 				DisableUsedPercentage: mustGetBool(flags, "branding.DisableUsedPercentage"),
 				Files:                 mustGetString(flags, "branding.files"),
 			},
+			Trash: settings.Trash{
+				Disabled:  mustGetBool(flags, "trash.disabled"),
+				Retention: mustGetDuration(flags, "trash.retention"),
+			},
+			Versions: settings.Versions{
+				MaxCount: mustGetInt(flags, "versions.maxCount"),
+				MaxAge:   mustGetDuration(flags, "versions.maxAge"),
+			},
+			Previews: settings.Previews{
+				MaxConcurrent: mustGetInt(flags, "previews.maxConcurrent"),
+				Timeout:       mustGetDuration(flags, "previews.timeout"),
+				ContentKeys:   mustGetBool(flags, "previews.contentKeys"),
+			},
 		}
 
 		ser := &settings.Server{
@@ -54,6 +54,20 @@ you want to change. Other options will remain unchanged.`,
 				set.Branding.DisableUsedPercentage = mustGetBool(flags, flag.Name)
 			case "branding.files":
 				set.Branding.Files = mustGetString(flags, flag.Name)
+			case "trash.disabled":
+				set.Trash.Disabled = mustGetBool(flags, flag.Name)
+			case "trash.retention":
+				set.Trash.Retention = mustGetDuration(flags, flag.Name)
+			case "versions.maxCount":
+				set.Versions.MaxCount = mustGetInt(flags, flag.Name)
+			case "versions.maxAge":
+				set.Versions.MaxAge = mustGetDuration(flags, flag.Name)
+			case "previews.maxConcurrent":
+				set.Previews.MaxConcurrent = mustGetInt(flags, flag.Name)
+			case "previews.timeout":
+				set.Previews.Timeout = mustGetDuration(flags, flag.Name)
+			case "previews.contentKeys":
+				set.Previews.ContentKeys = mustGetBool(flags, flag.Name)
 			}
 		})
 		err = d.store.Settings.Save(set)
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+func init() {
+	rootCmd.AddCommand(previewsCmd)
+}
+
+var previewsCmd = &cobra.Command{
+	Use:   "previews",
+	Short: "Previews management utility",
+	Long:  `Preview generators and sizes management utility.`,
+	Args:  cobra.NoArgs,
+}
+
+func printPreviewGenerators(generators []settings.PreviewGenerator) {
+	for i, gen := range generators {
+		fmt.Printf("(%d) %s: %s\n", i, strings.Join(gen.MimeTypes, ","), strings.Join(gen.Command, " "))
+	}
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+func init() {
+	previewsCmd.AddCommand(previewsAddCmd)
+}
+
+var previewsAddCmd = &cobra.Command{
+	Use:   "add <mime types> <command>",
+	Short: "Add a command generating the previews of some MIME types",
+	Long: `Add a command generating the previews of some MIME types.
+The MIME types are a comma separated list of patterns such as
+video/* or application/pdf. The command must write an image
+to its standard output. $FILE is replaced by the path of the
+file and $SIZE by the largest dimension of the preview:
+
+  filebrowser previews add "video/*" -- ffmpeg -ss 5 -i $FILE -frames:v 1 -vf scale=$SIZE:-1 -f image2pipe -c:v png -
+
+Preview generators only run when the command runner is enabled.`,
+	Args: cobra.MinimumNArgs(2), //nolint:gomnd
+	Run: python(func(cmd *cobra.Command, args []string, d pythonData) {
+		s, err := d.store.Settings.Get()
+		checkErr(err)
+		s.Previews.Generators = append(s.Previews.Generators, settings.PreviewGenerator{
+			MimeTypes: strings.Split(args[0], ","),
+			Command:   args[1:],
+		})
+		err = d.store.Settings.Save(s)
+		checkErr(err)
+		printPreviewGenerators(s.Previews.Generators)
+	}, pythonConfig{}),
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	previewsCmd.AddCommand(previewsLsCmd)
+}
+
+var previewsLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the preview generators",
+	Long:  `List the preview generators, in the order they are matched.`,
+	Args:  cobra.NoArgs,
+	Run: python(func(cmd *cobra.Command, args []string, d pythonData) {
+		s, err := d.store.Settings.Get()
+		checkErr(err)
+		printPreviewGenerators(s.Previews.Generators)
+	}, pythonConfig{}),
+}
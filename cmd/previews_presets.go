@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+func init() {
+	previewsCmd.AddCommand(previewsPresetsCmd)
+}
+
+var previewsPresetsCmd = &cobra.Command{
+	Use:   "presets",
+	Short: "Preview sizes management utility",
+	Long: `Preview sizes management utility. The previews are requested
+by the name of their preset, and the presets ending with @2x are
+used on high density screens.`,
+	Args: cobra.NoArgs,
+}
+
+func printPreviewPresets(presets []settings.PreviewPreset) {
+	for _, preset := range presets {
+		thumbnail := ""
+		if preset.Thumbnail {
+			thumbnail = " thumbnail"
+		}
+		fmt.Printf("%s: %dx%d %s %s%s\n", preset.Name, preset.Width, preset.Height, preset.Mode, preset.Quality, thumbnail)
+	}
+}
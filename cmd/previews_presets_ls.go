@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	previewsPresetsCmd.AddCommand(previewsPresetsLsCmd)
+}
+
+var previewsPresetsLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the preview sizes",
+	Long:  `List the preview sizes, the default ones if none was set.`,
+	Args:  cobra.NoArgs,
+	Run: python(func(cmd *cobra.Command, args []string, d pythonData) {
+		s, err := d.store.Settings.Get()
+		checkErr(err)
+		printPreviewPresets(s.Previews.AllPresets())
+	}, pythonConfig{}),
+}
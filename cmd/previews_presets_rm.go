@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+func init() {
+	previewsPresetsCmd.AddCommand(previewsPresetsRmCmd)
+}
+
+var previewsPresetsRmCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Short: "Removes a preview size",
+	Long: `Removes a preview size. Removing all of them restores the
+default sizes.`,
+	Args: cobra.ExactArgs(1),
+	Run: python(func(cmd *cobra.Command, args []string, d pythonData) {
+		s, err := d.store.Settings.Get()
+		checkErr(err)
+
+		presets := []settings.PreviewPreset{}
+		for _, preset := range s.Previews.AllPresets() {
+			if preset.Name != args[0] {
+				presets = append(presets, preset)
+			}
+		}
+		if len(presets) == len(s.Previews.AllPresets()) {
+			checkErr(fmt.Errorf("no preview size called %s", args[0]))
+		}
+
+		s.Previews.Presets = presets
+		err = d.store.Settings.Save(s)
+		checkErr(err)
+		printPreviewPresets(s.Previews.AllPresets())
+	}, pythonConfig{}),
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/filebrowser/filebrowser/v2/img"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+func init() {
+	previewsPresetsCmd.AddCommand(previewsPresetsSetCmd)
+
+	previewsPresetsSetCmd.Flags().String("mode", img.ResizeModeFit.String(), "resize mode ("+img.ResizeModeFit.String()+" or "+img.ResizeModeFill.String()+")")
+	previewsPresetsSetCmd.Flags().String("quality", img.QualityMedium.String(), "resize quality (high, medium or low)")
+	previewsPresetsSetCmd.Flags().Bool("thumbnail", false, "use the preset in listings")
+}
+
+var previewsPresetsSetCmd = &cobra.Command{
+	Use:   "set <name> <width>x<height>",
+	Short: "Add or update a preview size",
+	Long: `Add or update a preview size. The default sizes are kept
+when the first preset is set:
+
+  filebrowser previews presets set thumb@3x 768x768 --mode fill --thumbnail`,
+	Args: cobra.ExactArgs(2), //nolint:gomnd
+	Run: python(func(cmd *cobra.Command, args []string, d pythonData) {
+		flags := cmd.Flags()
+		preset := settings.PreviewPreset{
+			Name:      args[0],
+			Thumbnail: mustGetBool(flags, "thumbnail"),
+		}
+
+		_, err := fmt.Sscanf(args[1], "%dx%d", &preset.Width, &preset.Height)
+		if err != nil || preset.Width < 1 || preset.Height < 1 {
+			checkErr(fmt.Errorf("invalid preview size %s, expected <width>x<height>", args[1]))
+		}
+		preset.Mode, err = img.ParseResizeMode(mustGetString(flags, "mode"))
+		checkErr(err)
+		preset.Quality, err = img.ParseQuality(mustGetString(flags, "quality"))
+		checkErr(err)
+
+		s, err := d.store.Settings.Get()
+		checkErr(err)
+
+		presets := append([]settings.PreviewPreset{}, s.Previews.AllPresets()...)
+		replaced := false
+		for i := range presets {
+			if presets[i].Name == preset.Name {
+				presets[i] = preset
+				replaced = true
+			}
+		}
+		if !replaced {
+			presets = append(presets, preset)
+		}
+
+		s.Previews.Presets = presets
+		err = d.store.Settings.Save(s)
+		checkErr(err)
+		printPreviewPresets(s.Previews.AllPresets())
+	}, pythonConfig{}),
+}
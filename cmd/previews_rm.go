@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	previewsCmd.AddCommand(previewsRmCmd)
+}
+
+var previewsRmCmd = &cobra.Command{
+	Use:   "rm <index>",
+	Short: "Removes a preview generator",
+	Long: `Removes a preview generator. The provided index is the
+same that's printed when you run 'previews ls'.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+			return err
+		}
+		_, err := strconv.Atoi(args[0])
+		return err
+	},
+	Run: python(func(cmd *cobra.Command, args []string, d pythonData) {
+		s, err := d.store.Settings.Get()
+		checkErr(err)
+
+		i, err := strconv.Atoi(args[0])
+		checkErr(err)
+		if i < 0 || i >= len(s.Previews.Generators) {
+			checkErr(fmt.Errorf("no preview generator at index %d", i))
+		}
+
+		s.Previews.Generators = append(s.Previews.Generators[:i], s.Previews.Generators[i+1:]...)
+		err = d.store.Settings.Save(s)
+		checkErr(err)
+		printPreviewGenerators(s.Previews.Generators)
+	}, pythonConfig{}),
+}
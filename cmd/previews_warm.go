@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	fbhttp "github.com/filebrowser/filebrowser/v2/http"
+	"github.com/filebrowser/filebrowser/v2/preview"
+)
+
+func init() {
+	previewsCmd.AddCommand(previewsWarmCmd)
+
+	flags := previewsWarmCmd.Flags()
+	addImgFlags(flags)
+	flags.StringP("root", "r", ".", "root to prepend to relative paths")
+	flags.StringSlice("presets", fbhttp.DefaultWarmPresets, "preview sizes to make")
+	flags.Float64("rate", 0, "number of images per second whose previews are made (0 for no limit)")
+	flags.Duration("since", 0, "only make the previews of the images modified this long ago at most (0 for all)")
+}
+
+var previewsWarmCmd = &cobra.Command{
+	Use:   "warm <path>",
+	Short: "Make the previews of the images of a directory",
+	Long: `Make the missing previews of the images under a directory,
+relative to the root, and store them in the cache directory, just
+like the server does for the directories given to --previews-warm:
+
+  filebrowser previews warm /photos --cache-dir /var/cache/filebrowser`,
+	Args: cobra.ExactArgs(1),
+	Run: python(func(cmd *cobra.Command, args []string, d pythonData) {
+		flags := cmd.Flags()
+		if mustGetString(flags, "cache-dir") == "" {
+			checkErr(fmt.Errorf("the previews can't be stored without --cache-dir"))
+		}
+
+		server, err := d.store.Settings.GetServer()
+		checkErr(err)
+		if val, set := getParamB(flags, "root"); set {
+			server.Root = val
+		}
+		server.Root, err = filepath.Abs(server.Root)
+		checkErr(err)
+		server.EnableThumbnails = true
+		server.ResizePreview = true
+		server.TypeDetectionByHeader = true
+
+		presets, err := flags.GetStringSlice("presets")
+		checkErr(err)
+		rate, err := flags.GetFloat64("rate")
+		checkErr(err)
+
+		var since time.Time
+		if age := mustGetDuration(flags, "since"); age > 0 {
+			since = time.Now().Add(-age)
+		}
+
+		warmer := fbhttp.NewPreviewWarmer(getImgService(flags), getFileCache(flags), preview.NewGroup(),
+			d.store, server, presets, rate)
+		stats, err := warmer.Warm(ctx, args[0], since)
+		checkErr(err)
+		fmt.Printf("%d previews made, %d skipped, %d failed\n", stats.Warmed, stats.Skipped, stats.Failed)
+	}, pythonConfig{}),
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"expvar"
 	"io"
 	"io/fs"
 	"log"
@@ -14,6 +15,7 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/redis/go-redis/v9"
@@ -27,13 +29,26 @@ import (
 	"github.com/filebrowser/filebrowser/v2/frontend"
 	fbhttp "github.com/filebrowser/filebrowser/v2/http"
 	"github.com/filebrowser/filebrowser/v2/img"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+	"github.com/filebrowser/filebrowser/v2/preview"
 	"github.com/filebrowser/filebrowser/v2/settings"
 	"github.com/filebrowser/filebrowser/v2/storage"
+	"github.com/filebrowser/filebrowser/v2/trash"
 	"github.com/filebrowser/filebrowser/v2/users"
 	"github.com/filebrowser/filebrowser/v2/utils"
+	"github.com/filebrowser/filebrowser/v2/watch"
 )
 
 var ctx = context.Background()
+
+// trashPurgeInterval is the interval at which the items past the
+// retention are dropped from the trashes.
+const trashPurgeInterval = time.Hour
+
+// jobPurgeInterval is the interval at which the finished jobs past their
+// retention are dropped, along with their artifacts.
+const jobPurgeInterval = time.Minute
+
 var (
 	cfgFile string
 )
@@ -70,12 +85,74 @@ func addServerFlags(flags *pflag.FlagSet) {
 	flags.String("socket", "", "socket to listen to (cannot be used with address, port, cert nor key flags)")
 	flags.Uint32("socket-perm", 0666, "unix socket file permissions") //nolint:gomnd
 	flags.StringP("baseurl", "b", "", "base url")
-	flags.String("cache-dir", "", "file cache directory (disabled if empty)")
-	flags.Int("img-processors", 4, "image processors count") //nolint:gomnd
+	addImgFlags(flags)
+	flags.StringSlice("previews-warm", nil, "directories whose new images get their previews made before being requested")
+	flags.StringSlice("previews-warm-presets", fbhttp.DefaultWarmPresets, "preview sizes made before being requested")
+	flags.Duration("previews-warm-interval", 10*time.Minute, "interval at which the directories are searched for new images (0 to search them once)") //nolint:gomnd
+	flags.Float64("previews-warm-rate", 2, "number of images per second whose previews are made before being requested")                              //nolint:gomnd
 	flags.Bool("disable-thumbnails", false, "disable image thumbnails")
 	flags.Bool("disable-preview-resize", false, "disable resize of image previews")
 	flags.Bool("disable-exec", false, "disables Command Runner feature")
 	flags.Bool("disable-type-detection-by-header", false, "disables type detection by reading file headers")
+	flags.Duration("cache-janitor-interval", 10*time.Minute, "interval at which the cache entries are evicted (0 to evict them once at startup)") //nolint:gomnd
+	flags.String("cache-mode", "disk", "where the cache entries are kept: disk, redis (6.2 or later), or tiered for disk with their deletions broadcast through redis")
+	flags.String("metrics-address", "", "address serving the metrics at /debug/vars (disabled if empty)")
+	flags.Duration("job-retention", time.Hour, "how long finished background jobs stay queryable")
+	flags.Duration("watch-delay", 250*time.Millisecond, "interval at which directory changes are batched")
+	flags.Int64("extract-max-size", 10<<30, "maximum uncompressed size in bytes of extracted archives (0 for no limit)") //nolint:gomnd
+	flags.Int("extract-max-entries", 100000, "maximum number of entries of extracted archives (0 for no limit)")         //nolint:gomnd
+}
+
+// addImgFlags adds the flags of the image service and of the cache of
+// the previews.
+func addImgFlags(flags *pflag.FlagSet) {
+	flags.String("cache-dir", "", "file cache directory (disabled if empty)")
+	flags.Int64("cache-max-size", 0, "size in bytes above which the least recently used cache entries are evicted (0 for no limit)")
+	flags.Duration("cache-max-age", 0, "time after which the unused cache entries are evicted (0 for no limit)")
+	flags.Int("img-processors", 4, "image processors count") //nolint:gomnd
+	flags.String("img-converter", "", "command converting HEIC and AVIF images to png or jpeg on its output, $FILE being the image")
+	flags.String("img-webp-encoder", "", "command encoding png images to webp on its output, $FILE being the image, enables webp previews")
+	flags.Int("img-max-pixels", img.DefaultMaxPixels, "largest number of pixels of the resized images")
+	flags.Int64("img-max-memory", img.DefaultMaxMemory, "memory in bytes the images being resized at once may take")
+}
+
+func getImgService(flags *pflag.FlagSet) *img.Service {
+	workersCount, err := flags.GetInt("img-processors")
+	checkErr(err)
+	if workersCount < 1 {
+		log.Fatal("Image resize workers count could not be < 1")
+	}
+	imgConverter, err := flags.GetString("img-converter")
+	checkErr(err)
+	imgWebpEncoder, err := flags.GetString("img-webp-encoder")
+	checkErr(err)
+	imgMaxPixels, err := flags.GetInt("img-max-pixels")
+	checkErr(err)
+	imgMaxMemory, err := flags.GetInt64("img-max-memory")
+	checkErr(err)
+
+	return img.New(workersCount,
+		img.WithConverter(convertCmdStrToCmdArray(imgConverter)),
+		img.WithWebpEncoder(convertCmdStrToCmdArray(imgWebpEncoder)),
+		img.WithMaxPixels(imgMaxPixels),
+		img.WithMaxMemory(imgMaxMemory))
+}
+
+func getFileCache(flags *pflag.FlagSet) diskcache.Interface {
+	cacheDir, err := flags.GetString("cache-dir")
+	checkErr(err)
+	if cacheDir == "" {
+		return diskcache.NewNoOp()
+	}
+	maxSize, err := flags.GetInt64("cache-max-size")
+	checkErr(err)
+	maxAge, err := flags.GetDuration("cache-max-age")
+	checkErr(err)
+
+	if err := os.MkdirAll(cacheDir, 0700); err != nil { //nolint:govet,gomnd
+		log.Fatalf("can't make directory %s: %s", cacheDir, err)
+	}
+	return diskcache.New(afero.NewOsFs(), cacheDir, diskcache.WithMaxSize(maxSize), diskcache.WithMaxAge(maxAge))
 }
 
 var rootCmd = &cobra.Command{
@@ -123,27 +200,44 @@ user created with the credentials from options "username" and "password".`,
 			quickSetup(cmd.Flags(), d)
 		}
 
-		// build img service
-		workersCount, err := cmd.Flags().GetInt("img-processors")
+		imgSvc := getImgService(cmd.Flags())
+
+		metricsAddress, err := cmd.Flags().GetString("metrics-address")
 		checkErr(err)
-		if workersCount < 1 {
-			log.Fatal("Image resize workers count could not be < 1")
+		if metricsAddress != "" {
+			mux := http.NewServeMux()
+			mux.Handle("/debug/vars", expvar.Handler())
+			go func() {
+				//nolint: gosec
+				if err := http.ListenAndServe(metricsAddress, mux); err != nil { //nolint:govet
+					log.Printf("metrics are disabled: %v", err)
+				}
+			}()
 		}
-		imgSvc := img.New(workersCount)
 
-		var fileCache diskcache.Interface = diskcache.NewNoOp()
-		cacheDir, err := cmd.Flags().GetString("cache-dir")
+		jobRetention, err := cmd.Flags().GetDuration("job-retention")
 		checkErr(err)
-		if cacheDir != "" {
-			if err := os.MkdirAll(cacheDir, 0700); err != nil { //nolint:govet,gomnd
-				log.Fatalf("can't make directory %s: %s", cacheDir, err)
-			}
-			fileCache = diskcache.New(afero.NewOsFs(), cacheDir)
+		jobMgr := jobs.NewManager(jobRetention)
+		go jobMgr.Run(ctx, jobPurgeInterval)
+
+		watchDelay, err := cmd.Flags().GetDuration("watch-delay")
+		checkErr(err)
+		watchHub, err := watch.NewHub(watchDelay)
+		if err != nil {
+			log.Printf("directory watching is disabled: %v", err)
 		}
 
 		server := getRunParams(cmd.Flags(), d.store)
 		setupLog(server.Log)
 
+		trashPurger := trash.NewPurger(d.store.TrashScopes, server.Root)
+		go trashPurger.Run(ctx, trashPurgeInterval, d.store.Settings.Get)
+
+		server.ExtractMaxSize, err = cmd.Flags().GetInt64("extract-max-size")
+		checkErr(err)
+		server.ExtractMaxEntries, err = cmd.Flags().GetInt("extract-max-entries")
+		checkErr(err)
+
 		root, err := filepath.Abs(server.Root)
 		checkErr(err)
 		server.Root = root
@@ -191,8 +285,53 @@ user created with the credentials from options "username" and "password".`,
 
 		go utils.SubscribeRedisEvent(rdb, server.TokenCredentialsSecret, server.TokenSecret, server.MountScriptPath)
 
-		handler, err := fbhttp.NewHandler(imgSvc, fileCache, d.store, server, assetsFs, rdb)
+		// the instances behind a load balancer share their cache, or at
+		// least its deletions
+		var fileCache diskcache.Interface
+		switch cacheMode := mustGetString(cmd.Flags(), "cache-mode"); cacheMode {
+		case "disk", "tiered":
+			fileCache = getFileCache(cmd.Flags())
+			if fc, ok := fileCache.(*diskcache.FileCache); ok {
+				janitorInterval, err := cmd.Flags().GetDuration("cache-janitor-interval") //nolint:govet
+				checkErr(err)
+				go fc.RunJanitor(ctx, janitorInterval)
+				expvar.Publish("cache", expvar.Func(func() interface{} { return fc.Stats() }))
+			}
+			if cacheMode == "tiered" {
+				fileCache, err = diskcache.NewTiered(ctx, fileCache, rdb)
+				checkErr(err)
+			}
+		case "redis":
+			// Redis evicts the entries itself and keeps their statistics,
+			// the janitor only forgets the paths of the evicted ones
+			cacheMaxAge, err := cmd.Flags().GetDuration("cache-max-age") //nolint:govet
+			checkErr(err)
+			janitorInterval, err := cmd.Flags().GetDuration("cache-janitor-interval")
+			checkErr(err)
+			rc := diskcache.NewRedis(rdb, cacheMaxAge)
+			go rc.RunJanitor(ctx, janitorInterval)
+			fileCache = rc
+		default:
+			log.Fatalf("unknown cache mode %s", cacheMode)
+		}
+
+		// the previews being warmed and requested at once are made once
+		previewGroup := preview.NewGroup()
+		handler, err := fbhttp.NewHandler(imgSvc, fileCache, previewGroup, jobMgr, trashPurger, watchHub, d.store, server, assetsFs, rdb)
+		checkErr(err)
+
+		warmScopes, err := cmd.Flags().GetStringSlice("previews-warm")
 		checkErr(err)
+		if len(warmScopes) > 0 {
+			warmPresets, err := cmd.Flags().GetStringSlice("previews-warm-presets") //nolint:govet
+			checkErr(err)
+			warmInterval, err := cmd.Flags().GetDuration("previews-warm-interval")
+			checkErr(err)
+			warmRate, err := cmd.Flags().GetFloat64("previews-warm-rate")
+			checkErr(err)
+			warmer := fbhttp.NewPreviewWarmer(imgSvc, fileCache, previewGroup, d.store, server, warmPresets, warmRate)
+			go warmer.Run(ctx, warmScopes, warmInterval)
+		}
 
 		defer listener.Close()
 
@@ -371,6 +510,12 @@ func quickSetup(flags *pflag.FlagSet, d pythonData) {
 			},
 		},
 		Branding: settings.Branding{},
+		Trash:    settings.Trash{Retention: settings.DefaultTrashRetention},
+		Versions: settings.Versions{MaxCount: settings.DefaultVersionsMaxCount},
+		Previews: settings.Previews{
+			MaxConcurrent: preview.DefaultMaxConcurrent,
+			Timeout:       preview.DefaultTimeout,
+		},
 		Commands: nil,
 		Shell:    nil,
 		Rules:    nil,
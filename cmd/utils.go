@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/asdine/storm/v3"
 	"github.com/spf13/cobra"
@@ -36,6 +37,18 @@ func mustGetBool(flags *pflag.FlagSet, flag string) bool {
 	return b
 }
 
+func mustGetInt(flags *pflag.FlagSet, flag string) int {
+	i, err := flags.GetInt(flag)
+	checkErr(err)
+	return i
+}
+
+func mustGetDuration(flags *pflag.FlagSet, flag string) time.Duration {
+	d, err := flags.GetDuration(flag)
+	checkErr(err)
+	return d
+}
+
 func generateKey() []byte {
 	k, err := settings.GenerateKey()
 	checkErr(err)
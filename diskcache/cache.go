@@ -8,4 +8,10 @@ type Interface interface {
 	Store(ctx context.Context, key string, value []byte) error
 	Load(ctx context.Context, key string) (value []byte, exist bool, err error)
 	Delete(ctx context.Context, key string) error
+	// Record records key as the key of an entry about the file at name,
+	// for DeletePath.
+	Record(ctx context.Context, name, key string) error
+	// DeletePath deletes the entries recorded for the file at name and
+	// for all the files under it.
+	DeletePath(ctx context.Context, name string) error
 }
@@ -4,46 +4,131 @@ import (
 	"context"
 	"crypto/sha1" //nolint:gosec
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/spf13/afero"
 )
 
+const (
+	// statsFileName is the file the janitor saves the hits and misses
+	// to, at the root of the cache.
+	statsFileName = "stats.json"
+	// pathsDir is the directory mirroring the paths recorded by Record,
+	// at the root of the cache. The records of the entries are empty
+	// files named after their entries in the directories of the paths.
+	pathsDir = "/paths"
+	// touchInterval is the precision of the access times of the
+	// entries, which are only updated once in a while by the loads.
+	touchInterval = time.Minute
+)
+
+// Stats are the statistics of a cache.
+type Stats struct {
+	Entries int64   `json:"entries"`
+	Bytes   int64   `json:"bytes"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hitRate"`
+}
+
 type FileCache struct {
+	// the counters come first for their 64-bit alignment
+	entries int64
+	bytes   int64
+	hits    int64
+	misses  int64
+	// counted is set once the entries and bytes were counted.
+	counted int32
+
 	fs afero.Fs
+	// maxSize is the size in bytes of the entries above which the least
+	// recently accessed ones are evicted, 0 for no limit.
+	maxSize int64
+	// maxAge is the time after which the entries not accessed are
+	// evicted, 0 for no limit.
+	maxAge time.Duration
 
 	// granular locks
 	scopedLocks struct {
 		sync.Mutex
 		sync.Once
-		locks map[string]sync.Locker
+		locks map[string]*scopedLock
+	}
+}
+
+// scopedLock is the lock of an entry, which is removed from the map
+// once nobody holds or waits for it.
+type scopedLock struct {
+	sync.Mutex
+	refs int
+}
+
+// Option configures a FileCache.
+type Option func(*FileCache)
+
+// WithMaxSize sets the size in bytes of the entries above which the
+// least recently accessed ones are evicted.
+func WithMaxSize(size int64) Option {
+	return func(f *FileCache) {
+		f.maxSize = size
+	}
+}
+
+// WithMaxAge sets the time after which the entries that weren't
+// accessed are evicted.
+func WithMaxAge(age time.Duration) Option {
+	return func(f *FileCache) {
+		f.maxAge = age
 	}
 }
 
-func New(fs afero.Fs, root string) *FileCache {
-	return &FileCache{
+func New(fs afero.Fs, root string, opts ...Option) *FileCache {
+	f := &FileCache{
 		fs: afero.NewBasePathFs(fs, root),
 	}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	// the hits and misses are kept across restarts
+	if data, err := afero.ReadFile(f.fs, statsFileName); err == nil {
+		var stats Stats
+		if err := json.Unmarshal(data, &stats); err == nil {
+			f.hits, f.misses = stats.Hits, stats.Misses
+		}
+	}
+
+	return f
 }
 
 func (f *FileCache) Store(ctx context.Context, key string, value []byte) error {
-	mu := f.getScopedLocks(key)
-	mu.Lock()
-	defer mu.Unlock()
-
 	fileName := f.getFileName(key)
+	unlock := f.lock(fileName)
+	defer unlock()
+
 	if err := f.fs.MkdirAll(filepath.Dir(fileName), 0700); err != nil { //nolint:gomnd
 		return err
 	}
 
+	if info, err := f.fs.Stat(fileName); err == nil {
+		atomic.AddInt64(&f.entries, -1)
+		atomic.AddInt64(&f.bytes, -info.Size())
+	}
 	if err := afero.WriteFile(f.fs, fileName, value, 0700); err != nil { //nolint:gomnd
 		return err
 	}
+	atomic.AddInt64(&f.entries, 1)
+	atomic.AddInt64(&f.bytes, int64(len(value)))
 
 	return nil
 }
@@ -51,6 +136,9 @@ func (f *FileCache) Store(ctx context.Context, key string, value []byte) error {
 func (f *FileCache) Load(ctx context.Context, key string) (value []byte, exist bool, err error) {
 	r, ok, err := f.open(key)
 	if err != nil || !ok {
+		if err == nil {
+			atomic.AddInt64(&f.misses, 1)
+		}
 		return nil, ok, err
 	}
 	defer r.Close()
@@ -59,18 +147,302 @@ func (f *FileCache) Load(ctx context.Context, key string) (value []byte, exist b
 	if err != nil {
 		return nil, false, err
 	}
+	atomic.AddInt64(&f.hits, 1)
+
+	// the modification time of the entries is their access time, the
+	// access time of the filesystem is often not updated
+	if info, err := r.Stat(); err == nil && time.Since(info.ModTime()) > touchInterval { //nolint:govet
+		now := time.Now()
+		_ = f.fs.Chtimes(f.getFileName(key), now, now)
+	}
+
 	return value, true, nil
 }
 
 func (f *FileCache) Delete(ctx context.Context, key string) error {
-	mu := f.getScopedLocks(key)
-	mu.Lock()
-	defer mu.Unlock()
-
 	fileName := f.getFileName(key)
+	unlock := f.lock(fileName)
+	defer unlock()
+
+	return f.remove(fileName)
+}
+
+// Record records key as the key of an entry about the file at name,
+// for DeletePath. The records of the entries that are gone are pruned
+// by the janitor.
+func (f *FileCache) Record(ctx context.Context, name, key string) error {
+	dir := f.pathDir(name)
+	if err := f.fs.MkdirAll(dir, 0700); err != nil { //nolint:gomnd
+		return err
+	}
+	return afero.WriteFile(f.fs, filepath.Join(dir, filepath.Base(f.getFileName(key))), nil, 0600) //nolint:gomnd
+}
+
+// DeletePath deletes the entries recorded for the file at name and for
+// all the files under it, along with their records.
+func (f *FileCache) DeletePath(ctx context.Context, name string) error {
+	dir := f.pathDir(name)
+	err := afero.Walk(f.fs, dir, func(record string, info os.FileInfo, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil { //nolint:govet
+			return err
+		}
+		fileName, ok := entryFileName(info)
+		if !ok {
+			return nil
+		}
+
+		unlock := f.lock(fileName)
+		defer unlock()
+		return f.remove(fileName)
+	})
+	if err != nil {
+		return err
+	}
+
+	return f.fs.RemoveAll(dir)
+}
+
+// pathDir returns the directory of the records of the file at name.
+func (f *FileCache) pathDir(name string) string {
+	return filepath.Join(pathsDir, name[len(filepath.VolumeName(name)):])
+}
+
+// Stats returns the statistics of the cache. The entries and bytes are
+// counted by the janitor, or by the first call if it didn't run yet,
+// and kept up to date between its runs. Stats doesn't modify the cache.
+func (f *FileCache) Stats() Stats {
+	if atomic.LoadInt32(&f.counted) == 0 {
+		if entries, err := f.list(context.Background()); err == nil {
+			f.count(entries)
+		} else {
+			log.Printf("cache stats: %v", err)
+		}
+	}
+
+	stats := Stats{
+		Entries: atomic.LoadInt64(&f.entries),
+		Bytes:   atomic.LoadInt64(&f.bytes),
+		Hits:    atomic.LoadInt64(&f.hits),
+		Misses:  atomic.LoadInt64(&f.misses),
+	}
+	if loads := stats.Hits + stats.Misses; loads > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(loads)
+	}
+	return stats
+}
+
+type cacheEntry struct {
+	name       string
+	size       int64
+	accessTime time.Time
+}
+
+// Evict removes the entries not accessed for longer than the maximum
+// age, then the least recently accessed ones until the entries fit in
+// the maximum size. It recounts the entries and saves the hits and
+// misses on the way, and returns the number of entries evicted.
+func (f *FileCache) Evict(ctx context.Context) (int, error) {
+	entries, err := f.list(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].accessTime.Before(entries[j].accessTime)
+	})
+
+	var size int64
+	for _, entry := range entries {
+		size += entry.size
+	}
+
+	evicted := 0
+	kept := entries[:0]
+	for i, entry := range entries {
+		expired := f.maxAge > 0 && time.Since(entry.accessTime) > f.maxAge
+		if !expired && (f.maxSize <= 0 || size <= f.maxSize) {
+			// the next ones were accessed later
+			kept = append(kept, entries[i:]...)
+			break
+		}
+
+		ok, err := f.evict(entry)
+		if err != nil {
+			return evicted, err
+		}
+		if ok {
+			evicted++
+			size -= entry.size
+		} else {
+			kept = append(kept, entry)
+		}
+	}
+
+	f.count(kept)
+
+	if err := f.prune(ctx); err != nil {
+		return evicted, err
+	}
+
+	return evicted, f.saveStats()
+}
+
+// prune removes the records of the entries that are gone, and the
+// directories of the paths left without records.
+func (f *FileCache) prune(ctx context.Context) error {
+	var dirs []string
+	err := afero.Walk(f.fs, pathsDir, func(record string, info os.FileInfo, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil { //nolint:govet
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, record)
+			return nil
+		}
+
+		fileName, ok := entryFileName(info)
+		if !ok {
+			return nil
+		}
+		if _, err := f.fs.Stat(fileName); !errors.Is(err, os.ErrNotExist) { //nolint:govet
+			return nil
+		}
+		if err := f.fs.Remove(record); err != nil && !errors.Is(err, os.ErrNotExist) { //nolint:govet
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the directories are listed before their children
+	for i := len(dirs) - 1; i > 0; i-- {
+		if infos, err := afero.ReadDir(f.fs, dirs[i]); err == nil && len(infos) == 0 {
+			_ = f.fs.Remove(dirs[i])
+		}
+	}
+
+	return nil
+}
+
+// list returns the entries of the cache.
+func (f *FileCache) list(ctx context.Context) ([]cacheEntry, error) {
+	var entries []cacheEntry
+	err := afero.Walk(f.fs, "/", func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil { //nolint:govet
+			return err
+		}
+		if info.IsDir() && name == pathsDir {
+			return filepath.SkipDir
+		}
+		// the root only holds the stats, the entries are in directories
+		if info.Mode().IsRegular() && filepath.Dir(name) != "/" {
+			entries = append(entries, cacheEntry{name: name, size: info.Size(), accessTime: info.ModTime()})
+		}
+		return nil
+	})
+
+	return entries, err
+}
+
+// count sets the entries and bytes of the cache to the ones of entries.
+func (f *FileCache) count(entries []cacheEntry) {
+	var size int64
+	for _, entry := range entries {
+		size += entry.size
+	}
+
+	atomic.StoreInt64(&f.entries, int64(len(entries)))
+	atomic.StoreInt64(&f.bytes, size)
+	atomic.StoreInt32(&f.counted, 1)
+}
+
+// evict removes entry unless it was accessed since it was listed.
+func (f *FileCache) evict(entry cacheEntry) (bool, error) {
+	unlock := f.lock(entry.name)
+	defer unlock()
+
+	info, err := f.fs.Stat(entry.name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	if info.ModTime().After(entry.accessTime) {
+		return false, nil
+	}
+
+	return true, f.remove(entry.name)
+}
+
+// RunJanitor evicts the entries every interval, or once if interval
+// isn't positive, until ctx is done.
+func (f *FileCache) RunJanitor(ctx context.Context, interval time.Duration) {
+	var ticks <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for {
+		if evicted, err := f.Evict(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("cache janitor: %v", err)
+		} else if evicted > 0 {
+			log.Printf("cache janitor: evicted %d entries", evicted)
+		}
+
+		if ticks == nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks:
+		}
+	}
+}
+
+func (f *FileCache) saveStats() error {
+	data, err := json.Marshal(f.Stats())
+	if err != nil {
+		return err
+	}
+	return afero.WriteFile(f.fs, statsFileName, data, 0600) //nolint:gomnd
+}
+
+// remove removes the file of an entry, which must be locked.
+func (f *FileCache) remove(fileName string) error {
+	info, err := f.fs.Stat(fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
 	if err := f.fs.Remove(fileName); err != nil && !errors.Is(err, os.ErrNotExist) {
 		return err
 	}
+	atomic.AddInt64(&f.entries, -1)
+	atomic.AddInt64(&f.bytes, -info.Size())
 	return nil
 }
 
@@ -87,24 +459,49 @@ func (f *FileCache) open(key string) (afero.File, bool, error) {
 	return file, true, nil
 }
 
-// getScopedLocks pull lock from the map if found or create a new one
-func (f *FileCache) getScopedLocks(key string) (lock sync.Locker) {
-	f.scopedLocks.Do(func() { f.scopedLocks.locks = map[string]sync.Locker{} })
+// lock locks the entry of the file until the returned function is
+// called.
+func (f *FileCache) lock(fileName string) (unlock func()) {
+	f.scopedLocks.Do(func() { f.scopedLocks.locks = map[string]*scopedLock{} })
 
 	f.scopedLocks.Lock()
-	lock, ok := f.scopedLocks.locks[key]
+	lock, ok := f.scopedLocks.locks[fileName]
 	if !ok {
-		lock = &sync.Mutex{}
-		f.scopedLocks.locks[key] = lock
+		lock = &scopedLock{}
+		f.scopedLocks.locks[fileName] = lock
 	}
+	lock.refs++
 	f.scopedLocks.Unlock()
 
-	return lock
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		f.scopedLocks.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(f.scopedLocks.locks, fileName)
+		}
+		f.scopedLocks.Unlock()
+	}
 }
 
+// getFileName returns the path of the entry of key, which is also the
+// name of its lock.
 func (f *FileCache) getFileName(key string) string {
 	hasher := sha1.New() //nolint:gosec
 	_, _ = hasher.Write([]byte(key))
-	hash := hex.EncodeToString(hasher.Sum(nil))
-	return fmt.Sprintf("%s/%s/%s", hash[:1], hash[1:3], hash)
+	return hashFileName(hex.EncodeToString(hasher.Sum(nil)))
+}
+
+func hashFileName(hash string) string {
+	return fmt.Sprintf("/%s/%s/%s", hash[:1], hash[1:3], hash)
+}
+
+// entryFileName returns the path of the entry of a record.
+func entryFileName(record os.FileInfo) (string, bool) {
+	if !record.Mode().IsRegular() || len(record.Name()) != sha1.Size*2 {
+		return "", false
+	}
+	return hashFileName(record.Name()), true
 }
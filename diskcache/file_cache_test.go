@@ -4,6 +4,7 @@ import (
 	"context"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
@@ -53,3 +54,150 @@ func checkValue(t *testing.T, ctx context.Context, fs afero.Fs, fileFullPath str
 	require.True(t, ok)
 	require.Equal(t, wantValue, string(b))
 }
+
+func TestFileCache_Evict(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	testCases := map[string]struct {
+		opts        []Option
+		wantEvicted []string
+	}{
+		"no limits": {},
+		"max age": {
+			opts:        []Option{WithMaxAge(150 * time.Minute)},
+			wantEvicted: []string{"a", "b"},
+		},
+		"max size": {
+			opts:        []Option{WithMaxSize(9)},
+			wantEvicted: []string{"a"},
+		},
+		"max size and age": {
+			opts:        []Option{WithMaxSize(3), WithMaxAge(150 * time.Minute)},
+			wantEvicted: []string{"a", "b", "c"},
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			cache := New(fs, "/cache", test.opts...)
+
+			// the entries are accessed an hour apart, "a" first
+			keys := []string{"a", "b", "c", "d"}
+			for i, key := range keys {
+				require.NoError(t, cache.Store(ctx, key, []byte("abc")))
+				accessTime := now.Add(-time.Duration(len(keys)-i) * time.Hour)
+				require.NoError(t, cache.fs.Chtimes(cache.getFileName(key), accessTime, accessTime))
+			}
+
+			evicted, err := cache.Evict(ctx)
+			require.NoError(t, err)
+			require.Equal(t, len(test.wantEvicted), evicted)
+
+			var gotEvicted []string
+			for _, key := range keys {
+				if _, ok, err := cache.Load(ctx, key); err == nil && !ok { //nolint:govet
+					gotEvicted = append(gotEvicted, key)
+				}
+			}
+			require.Equal(t, test.wantEvicted, gotEvicted)
+
+			stats := cache.Stats()
+			require.Equal(t, int64(len(keys)-evicted), stats.Entries)
+			require.Equal(t, int64(3*(len(keys)-evicted)), stats.Bytes)
+		})
+	}
+}
+
+func TestFileCache_Stats(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	cache := New(fs, "/cache")
+
+	require.NoError(t, cache.Store(ctx, "a", []byte("abc")))
+	require.NoError(t, cache.Store(ctx, "a", []byte("abcd")))
+	require.NoError(t, cache.Store(ctx, "b", []byte("ab")))
+	require.NoError(t, cache.Delete(ctx, "b"))
+	for _, key := range []string{"a", "a", "a", "b"} {
+		_, _, err := cache.Load(ctx, key)
+		require.NoError(t, err)
+	}
+
+	want := Stats{Entries: 1, Bytes: 4, Hits: 3, Misses: 1, HitRate: 0.75}
+	require.Equal(t, want, cache.Stats())
+	require.Empty(t, cache.scopedLocks.locks)
+
+	// the janitor saves the hits and misses for the next runs, and the
+	// entries are counted without it
+	_, err := cache.Evict(ctx)
+	require.NoError(t, err)
+	reopened := New(fs, "/cache")
+	require.Equal(t, want, reopened.Stats())
+
+	// counting the entries modifies nothing
+	info, err := fs.Stat("/cache/" + statsFileName)
+	require.NoError(t, err)
+	require.NoError(t, cache.Store(ctx, "c", []byte("c")))
+	require.Equal(t, int64(2), New(fs, "/cache").Stats().Entries)
+	after, err := fs.Stat("/cache/" + statsFileName)
+	require.NoError(t, err)
+	require.Equal(t, info.ModTime(), after.ModTime())
+}
+
+func TestFileCache_RunJanitorOnce(t *testing.T) {
+	ctx := context.Background()
+	cache := New(afero.NewMemMapFs(), "/cache", WithMaxSize(3))
+	require.NoError(t, cache.Store(ctx, "a", []byte("abc")))
+	require.NoError(t, cache.Store(ctx, "b", []byte("abc")))
+
+	// without interval, the janitor evicts the entries once and returns
+	cache.RunJanitor(ctx, 0)
+	require.Equal(t, int64(1), cache.Stats().Entries)
+}
+
+func TestFileCache_DeletePath(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	cache := New(fs, "/cache")
+
+	records := map[string]string{
+		"/a/photo.jpg":      "photo",
+		"/a/photo.jpg.bak":  "backup",
+		"/a/sub/nested.jpg": "nested",
+		"/ab/other.jpg":     "other",
+	}
+	for name, key := range records {
+		require.NoError(t, cache.Store(ctx, key, []byte("value")))
+		require.NoError(t, cache.Record(ctx, name, key))
+	}
+	exists := func(key string) bool {
+		_, ok, err := cache.Load(ctx, key)
+		require.NoError(t, err)
+		return ok
+	}
+
+	// the records aren't entries
+	require.Equal(t, int64(4), cache.Stats().Entries)
+
+	require.NoError(t, cache.DeletePath(ctx, "/a/photo.jpg"))
+	require.False(t, exists("photo"))
+	require.True(t, exists("backup"))
+
+	require.NoError(t, cache.DeletePath(ctx, "/a"))
+	require.False(t, exists("backup"))
+	require.False(t, exists("nested"))
+	require.True(t, exists("other"))
+	require.Equal(t, int64(1), cache.Stats().Entries)
+
+	// a path without records isn't an error
+	require.NoError(t, cache.DeletePath(ctx, "/missing"))
+
+	// the janitor prunes the records of the evicted entries
+	require.NoError(t, cache.Delete(ctx, "other"))
+	_, err := cache.Evict(ctx)
+	require.NoError(t, err)
+	infos, err := afero.ReadDir(fs, filepath.Join("/cache", pathsDir))
+	require.NoError(t, err)
+	require.Empty(t, infos)
+}
@@ -22,3 +22,11 @@ func (n *NoOp) Load(ctx context.Context, key string) (value []byte, exist bool,
 func (n *NoOp) Delete(ctx context.Context, key string) error {
 	return nil
 }
+
+func (n *NoOp) Record(ctx context.Context, name, key string) error {
+	return nil
+}
+
+func (n *NoOp) DeletePath(ctx context.Context, name string) error {
+	return nil
+}
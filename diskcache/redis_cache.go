@@ -0,0 +1,313 @@
+package diskcache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisKeyPrefix prefixes the keys of the entries in Redis.
+	redisKeyPrefix = "filebrowser:cache:"
+	// invalidationChannel is the channel the tiered caches broadcast
+	// their deletions to.
+	invalidationChannel = "filebrowser:cache:invalidate"
+	// redisPathsKey is the sorted set of the records of Record, whose
+	// members are the paths and the keys separated by a NUL. Their
+	// scores are all 0, so they are ordered by path.
+	redisPathsKey = "filebrowser:cache-paths"
+	// redisPruneBatch is the number of records checked at once by the
+	// janitor.
+	redisPruneBatch = 1000
+)
+
+// RedisCache keeps the entries in Redis, where all the instances share
+// them. The size of the cache is bounded by the maxmemory policy of
+// Redis.
+type RedisCache struct {
+	rdb *redis.Client
+	// ttl is the time after which the entries not accessed expire, 0
+	// for no expiration.
+	ttl time.Duration
+}
+
+// NewRedis creates a cache whose entries expire once they weren't
+// accessed for ttl, or never if ttl is 0. Renewing the expiration on
+// access takes GETEX, so a ttl requires Redis 6.2 or later.
+func NewRedis(rdb *redis.Client, ttl time.Duration) *RedisCache {
+	return &RedisCache{rdb: rdb, ttl: ttl}
+}
+
+func (c *RedisCache) Store(ctx context.Context, key string, value []byte) error {
+	return c.rdb.Set(ctx, redisKeyPrefix+key, value, c.ttl).Err()
+}
+
+func (c *RedisCache) Load(ctx context.Context, key string) (value []byte, exist bool, err error) {
+	var cmd *redis.StringCmd
+	if c.ttl > 0 {
+		// the expiration is renewed on every access
+		cmd = c.rdb.GetEx(ctx, redisKeyPrefix+key, c.ttl)
+	} else {
+		cmd = c.rdb.Get(ctx, redisKeyPrefix+key)
+	}
+
+	value, err = cmd.Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (c *RedisCache) Delete(ctx context.Context, key string) error {
+	return c.rdb.Del(ctx, redisKeyPrefix+key).Err()
+}
+
+// Record records key as the key of an entry about the file at name,
+// for DeletePath. The records of the entries that expired are pruned
+// by the janitor.
+func (c *RedisCache) Record(ctx context.Context, name, key string) error {
+	return c.rdb.ZAdd(ctx, redisPathsKey, redis.Z{Member: filepath.ToSlash(name) + "\x00" + key}).Err()
+}
+
+// DeletePath deletes the entries recorded for the file at name and for
+// all the files under it, along with their records.
+func (c *RedisCache) DeletePath(ctx context.Context, name string) error {
+	name = filepath.ToSlash(name)
+	dir := strings.TrimSuffix(name, "/")
+	ranges := []*redis.ZRangeBy{
+		// the records of name, then the ones of the files under it, as
+		// "0" follows "/"
+		{Min: "[" + name + "\x00", Max: "(" + name + "\x01"},
+		{Min: "[" + dir + "/", Max: "(" + dir + "0"},
+	}
+
+	var records []string
+	for _, r := range ranges {
+		found, err := c.rdb.ZRangeByLex(ctx, redisPathsKey, r).Result()
+		if err != nil {
+			return err
+		}
+		records = append(records, found...)
+	}
+
+	return c.deleteRecords(ctx, records, true)
+}
+
+// deleteRecords removes records, and their entries if entries is true.
+func (c *RedisCache) deleteRecords(ctx context.Context, records []string, entries bool) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	members := make([]interface{}, len(records))
+	keys := make([]string, len(records))
+	for i, record := range records {
+		members[i] = record
+		keys[i] = redisKeyPrefix + record[strings.IndexByte(record, 0)+1:]
+	}
+
+	_, err := c.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		if entries {
+			pipe.Del(ctx, keys...)
+		}
+		pipe.ZRem(ctx, redisPathsKey, members...)
+		return nil
+	})
+	return err
+}
+
+// Prune removes the records of the entries that expired, and returns
+// their number.
+func (c *RedisCache) Prune(ctx context.Context) (int, error) {
+	pruned := 0
+	min := "-"
+	for {
+		records, err := c.rdb.ZRangeByLex(ctx, redisPathsKey, &redis.ZRangeBy{
+			Min:   min,
+			Max:   "+",
+			Count: redisPruneBatch,
+		}).Result()
+		if err != nil {
+			return pruned, err
+		}
+		if len(records) == 0 {
+			return pruned, nil
+		}
+
+		exists := make([]*redis.IntCmd, len(records))
+		_, err = c.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, record := range records {
+				exists[i] = pipe.Exists(ctx, redisKeyPrefix+record[strings.IndexByte(record, 0)+1:])
+			}
+			return nil
+		})
+		if err != nil {
+			return pruned, err
+		}
+
+		var expired []string
+		for i, cmd := range exists {
+			if cmd.Val() == 0 {
+				expired = append(expired, records[i])
+			}
+		}
+		if err := c.deleteRecords(ctx, expired, false); err != nil {
+			return pruned, err
+		}
+		pruned += len(expired)
+
+		if len(records) < redisPruneBatch {
+			return pruned, nil
+		}
+		min = "(" + records[len(records)-1]
+	}
+}
+
+// RunJanitor prunes the records every interval, or once if interval
+// isn't positive, until ctx is done. The entries expire by themselves.
+func (c *RedisCache) RunJanitor(ctx context.Context, interval time.Duration) {
+	var ticks <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for {
+		if pruned, err := c.Prune(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("cache janitor: %v", err)
+		} else if pruned > 0 {
+			log.Printf("cache janitor: pruned %d records", pruned)
+		}
+
+		if ticks == nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks:
+		}
+	}
+}
+
+// TieredCache keeps the entries in a local cache, and broadcasts their
+// deletions through Redis to the other instances, which delete them
+// from their own local caches.
+type TieredCache struct {
+	local Interface
+	rdb   *redis.Client
+	// origin identifies the instance in the broadcasts, to skip its own.
+	origin string
+}
+
+// invalidation is the broadcast of a deletion, of an entry by Key or
+// of the entries recorded under a path by Path.
+type invalidation struct {
+	Origin string `json:"origin"`
+	Key    string `json:"key,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+// NewTiered creates a cache on top of local, which receives the
+// deletions of the other instances until ctx is done. The deletions
+// broadcast while Redis is unreachable are lost.
+func NewTiered(ctx context.Context, local Interface, rdb *redis.Client) (*TieredCache, error) {
+	origin := make([]byte, 16) //nolint:gomnd
+	if _, err := rand.Read(origin); err != nil {
+		return nil, err
+	}
+
+	c := &TieredCache{
+		local:  local,
+		rdb:    rdb,
+		origin: hex.EncodeToString(origin),
+	}
+	go c.listen(ctx)
+
+	return c, nil
+}
+
+func (c *TieredCache) Store(ctx context.Context, key string, value []byte) error {
+	return c.local.Store(ctx, key, value)
+}
+
+func (c *TieredCache) Load(ctx context.Context, key string) (value []byte, exist bool, err error) {
+	return c.local.Load(ctx, key)
+}
+
+func (c *TieredCache) Delete(ctx context.Context, key string) error {
+	if err := c.local.Delete(ctx, key); err != nil {
+		return err
+	}
+	return c.publish(ctx, invalidation{Origin: c.origin, Key: key})
+}
+
+func (c *TieredCache) Record(ctx context.Context, name, key string) error {
+	return c.local.Record(ctx, name, key)
+}
+
+// DeletePath deletes the entries recorded under name, and broadcasts the
+// path rather than the keys, as each instance recorded the entries it
+// made.
+func (c *TieredCache) DeletePath(ctx context.Context, name string) error {
+	if err := c.local.DeletePath(ctx, name); err != nil {
+		return err
+	}
+	return c.publish(ctx, invalidation{Origin: c.origin, Path: name})
+}
+
+func (c *TieredCache) publish(ctx context.Context, inv invalidation) error {
+	message, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	return c.rdb.Publish(ctx, invalidationChannel, message).Err()
+}
+
+func (c *TieredCache) listen(ctx context.Context) {
+	pubsub := c.rdb.Subscribe(ctx, invalidationChannel)
+	defer pubsub.Close()
+
+	// the channel is closed along with pubsub, and reconnects meanwhile
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			var inv invalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+				log.Printf("cache invalidation: %v", err)
+				continue
+			}
+			if inv.Origin == c.origin {
+				continue
+			}
+			if inv.Path != "" {
+				if err := c.local.DeletePath(ctx, inv.Path); err != nil {
+					log.Printf("cache invalidation of %s: %v", inv.Path, err)
+				}
+				continue
+			}
+			if err := c.local.Delete(ctx, inv.Key); err != nil {
+				log.Printf("cache invalidation of %s: %v", inv.Key, err)
+			}
+		}
+	}
+}
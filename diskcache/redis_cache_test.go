@@ -0,0 +1,464 @@
+package diskcache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis is a Redis server speaking RESP2 and knowing the few
+// commands the caches send.
+type fakeRedis struct {
+	ln net.Listener
+
+	mu          sync.Mutex
+	values      map[string]string
+	ttls        map[string]time.Duration
+	subscribers map[string][]*fakeRedisConn
+	// sets are the sorted sets, whose scores are all 0
+	sets map[string]map[string]bool
+}
+
+type fakeRedisConn struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+func newFakeRedis(t *testing.T) (*fakeRedis, *redis.Client) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeRedis{
+		ln:          ln,
+		values:      map[string]string{},
+		ttls:        map[string]time.Duration{},
+		subscribers: map[string][]*fakeRedisConn{},
+		sets:        map[string]map[string]bool{},
+	}
+	go s.serve()
+
+	rdb := redis.NewClient(&redis.Options{Addr: ln.Addr().String()})
+	t.Cleanup(func() {
+		rdb.Close()
+		ln.Close()
+	})
+	return s, rdb
+}
+
+func (s *fakeRedis) serve() {
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(nc)
+	}
+}
+
+func (s *fakeRedis) handle(nc net.Conn) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	conn := &fakeRedisConn{w: bufio.NewWriter(nc)}
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		conn.reply(s.exec(conn, args)...)
+	}
+}
+
+// readCommand reads a command, sent as an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2) //nolint:gomnd
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+// exec runs a command and returns its replies, already encoded.
+func (s *fakeRedis) exec(conn *fakeRedisConn, args []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return []string{"+PONG\r\n"}
+	case "SET":
+		s.values[args[1]] = args[2]
+		s.ttls[args[1]] = parseTTL(args[3:])
+		return []string{"+OK\r\n"}
+	case "GET", "GETEX":
+		value, ok := s.values[args[1]]
+		if !ok {
+			return []string{"$-1\r\n"}
+		}
+		if len(args) > 2 { //nolint:gomnd
+			s.ttls[args[1]] = parseTTL(args[2:])
+		}
+		return []string{bulk(value)}
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := s.values[key]; ok {
+				delete(s.values, key)
+				delete(s.ttls, key)
+				deleted++
+			}
+		}
+		return []string{fmt.Sprintf(":%d\r\n", deleted)}
+	case "EXISTS":
+		found := 0
+		for _, key := range args[1:] {
+			if _, ok := s.values[key]; ok {
+				found++
+			}
+		}
+		return []string{fmt.Sprintf(":%d\r\n", found)}
+	case "ZADD":
+		set, ok := s.sets[args[1]]
+		if !ok {
+			set = map[string]bool{}
+			s.sets[args[1]] = set
+		}
+		added := 0
+		for i := 3; i < len(args); i += 2 {
+			if !set[args[i]] {
+				set[args[i]] = true
+				added++
+			}
+		}
+		return []string{fmt.Sprintf(":%d\r\n", added)}
+	case "ZREM":
+		removed := 0
+		for _, member := range args[2:] {
+			if s.sets[args[1]][member] {
+				delete(s.sets[args[1]], member)
+				removed++
+			}
+		}
+		return []string{fmt.Sprintf(":%d\r\n", removed)}
+	case "ZRANGEBYLEX":
+		var members []string
+		for member := range s.sets[args[1]] {
+			if lexAbove(member, args[2]) && lexBelow(member, args[3]) {
+				members = append(members, member)
+			}
+		}
+		sort.Strings(members)
+		if len(args) == 7 && strings.EqualFold(args[4], "LIMIT") { //nolint:gomnd
+			offset, _ := strconv.Atoi(args[5])
+			count, _ := strconv.Atoi(args[6])
+			members = members[min(offset, len(members)):]
+			members = members[:min(count, len(members))]
+		}
+		reply := fmt.Sprintf("*%d\r\n", len(members))
+		for _, member := range members {
+			reply += bulk(member)
+		}
+		return []string{reply}
+	case "PUBLISH":
+		subscribers := s.subscribers[args[1]]
+		for _, sub := range subscribers {
+			sub.reply("*3\r\n" + bulk("message") + bulk(args[1]) + bulk(args[2]))
+		}
+		return []string{fmt.Sprintf(":%d\r\n", len(subscribers))}
+	case "SUBSCRIBE":
+		var replies []string
+		for i, channel := range args[1:] {
+			s.subscribers[channel] = append(s.subscribers[channel], conn)
+			replies = append(replies, fmt.Sprintf("*3\r\n%s%s:%d\r\n", bulk("subscribe"), bulk(channel), i+1))
+		}
+		return replies
+	default:
+		// HELLO among others, which makes the client speak RESP2
+		return []string{fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])}
+	}
+}
+
+func (c *fakeRedisConn) reply(replies ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, reply := range replies {
+		_, _ = c.w.WriteString(reply)
+	}
+	_ = c.w.Flush()
+}
+
+// parseTTL parses the EX or PX option of SET and GETEX.
+func parseTTL(args []string) time.Duration {
+	for i := 0; i+1 < len(args); i++ {
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil {
+			continue
+		}
+		switch strings.ToUpper(args[i]) {
+		case "EX":
+			return time.Duration(n) * time.Second
+		case "PX":
+			return time.Duration(n) * time.Millisecond
+		}
+	}
+	return 0
+}
+
+// lexAbove and lexBelow tell whether member is within the bounds of
+// ZRANGEBYLEX.
+func lexAbove(member, bound string) bool {
+	switch {
+	case bound == "-":
+		return true
+	case bound == "+":
+		return false
+	case bound[0] == '[':
+		return member >= bound[1:]
+	default:
+		return member > bound[1:]
+	}
+}
+
+func lexBelow(member, bound string) bool {
+	switch {
+	case bound == "+":
+		return true
+	case bound == "-":
+		return false
+	case bound[0] == '[':
+		return member <= bound[1:]
+	default:
+		return member < bound[1:]
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+func (s *fakeRedis) ttl(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ttls[key]
+}
+
+func (s *fakeRedis) setTTL(key string, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ttls[key] = ttl
+}
+
+func (s *fakeRedis) members(key string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var members []string
+	for member := range s.sets[key] {
+		members = append(members, member)
+	}
+	return members
+}
+
+func (s *fakeRedis) subscriberCount(channel string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subscribers[channel])
+}
+
+func TestRedisCache(t *testing.T) {
+	testCases := map[string]struct {
+		ttl     time.Duration
+		wantTTL time.Duration
+	}{
+		"expiring": {
+			ttl:     time.Hour,
+			wantTTL: time.Hour,
+		},
+		"not expiring": {},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			server, rdb := newFakeRedis(t)
+			cache := NewRedis(rdb, tc.ttl)
+
+			// a missing entry isn't an error
+			_, ok, err := cache.Load(ctx, "key")
+			require.NoError(t, err)
+			require.False(t, ok)
+
+			require.NoError(t, cache.Store(ctx, "key", []byte("value")))
+			require.Equal(t, tc.wantTTL, server.ttl(redisKeyPrefix+"key"))
+
+			// the loads renew the expiration
+			server.setTTL(redisKeyPrefix+"key", time.Minute)
+			value, ok, err := cache.Load(ctx, "key")
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Equal(t, "value", string(value))
+			if tc.ttl > 0 {
+				require.Equal(t, tc.wantTTL, server.ttl(redisKeyPrefix+"key"))
+			} else {
+				require.Equal(t, time.Minute, server.ttl(redisKeyPrefix+"key"))
+			}
+
+			require.NoError(t, cache.Delete(ctx, "key"))
+			_, ok, err = cache.Load(ctx, "key")
+			require.NoError(t, err)
+			require.False(t, ok)
+		})
+	}
+}
+
+func TestRedisCache_DeletePath(t *testing.T) {
+	ctx := context.Background()
+	server, rdb := newFakeRedis(t)
+	cache := NewRedis(rdb, 0)
+
+	records := map[string]string{
+		"/a/photo.jpg":      "photo",
+		"/a/photo.jpg.bak":  "backup",
+		"/a/sub/nested.jpg": "nested",
+		"/ab/other.jpg":     "other",
+	}
+	for name, key := range records {
+		require.NoError(t, cache.Store(ctx, key, []byte("value")))
+		require.NoError(t, cache.Record(ctx, name, key))
+	}
+	exists := func(key string) bool {
+		_, ok, err := cache.Load(ctx, key)
+		require.NoError(t, err)
+		return ok
+	}
+
+	// a file, and not its siblings sharing its name as a prefix
+	require.NoError(t, cache.DeletePath(ctx, "/a/photo.jpg"))
+	require.False(t, exists("photo"))
+	require.True(t, exists("backup"))
+
+	// a directory, and not its siblings sharing its name as a prefix
+	require.NoError(t, cache.DeletePath(ctx, "/a"))
+	require.False(t, exists("backup"))
+	require.False(t, exists("nested"))
+	require.True(t, exists("other"))
+	require.Len(t, server.members(redisPathsKey), 1)
+
+	// the records of the expired entries are pruned
+	require.NoError(t, rdb.Del(ctx, redisKeyPrefix+"other").Err())
+	pruned, err := cache.Prune(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, pruned)
+	require.Empty(t, server.members(redisPathsKey))
+}
+
+// countingCache counts the deletions of each key.
+type countingCache struct {
+	Interface
+
+	mu      sync.Mutex
+	deleted map[string]int
+}
+
+func (c *countingCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	c.deleted[key]++
+	c.mu.Unlock()
+	return c.Interface.Delete(ctx, key)
+}
+
+func (c *countingCache) deletions(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deleted[key]
+}
+
+func TestTieredCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server, rdb := newFakeRedis(t)
+
+	newLocal := func() *countingCache {
+		return &countingCache{Interface: New(afero.NewMemMapFs(), "/cache"), deleted: map[string]int{}}
+	}
+	localA, localB := newLocal(), newLocal()
+	a, err := NewTiered(ctx, localA, rdb)
+	require.NoError(t, err)
+	b, err := NewTiered(ctx, localB, rdb)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return server.subscriberCount(invalidationChannel) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	for _, cache := range []*TieredCache{a, b} {
+		require.NoError(t, cache.Store(ctx, "first", []byte("value")))
+		require.NoError(t, cache.Store(ctx, "second", []byte("value")))
+	}
+
+	// the deletions reach the other instances
+	require.NoError(t, a.Delete(ctx, "first"))
+	require.Eventually(t, func() bool {
+		_, ok, err := localB.Load(ctx, "first") //nolint:govet
+		return err == nil && !ok
+	}, time.Second, 10*time.Millisecond)
+	_, ok, err := a.Load(ctx, "first")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// but not the instance they come from: once the deletion of b has
+	// reached a, a has gone through its own deletion too
+	require.NoError(t, b.Delete(ctx, "second"))
+	require.Eventually(t, func() bool {
+		return localA.deletions("second") == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, localA.deletions("first"))
+	require.Equal(t, 1, localB.deletions("second"))
+
+	// the deletions by path reach the entries each instance recorded
+	require.NoError(t, b.Store(ctx, "photo", []byte("value")))
+	require.NoError(t, b.Record(ctx, "/a/photo.jpg", "photo"))
+	require.NoError(t, a.DeletePath(ctx, "/a"))
+	require.Eventually(t, func() bool {
+		_, ok, err := localB.Load(ctx, "photo") //nolint:govet
+		return err == nil && !ok
+	}, time.Second, 10*time.Millisecond)
+}
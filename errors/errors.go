@@ -18,4 +18,8 @@ var (
 	ErrInvalidRequestParams = errors.New("invalid request params")
 	ErrSourceIsParent       = errors.New("source is parent")
 	ErrRootUserDeletion     = errors.New("user with id 1 can't be deleted")
+	ErrUnsupportedArchive   = errors.New("unsupported archive format")
+	ErrInvalidArchiveEntry  = errors.New("archive entry escapes the destination")
+	ErrArchiveLimit         = errors.New("archive exceeds the extraction limits")
+	ErrUnsupportedSubtitles = errors.New("unsupported subtitles format")
 )
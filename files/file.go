@@ -1,6 +1,7 @@
 package files
 
 import (
+	"context"
 	"crypto/md5"  //nolint:gosec
 	"crypto/sha1" //nolint:gosec
 	"crypto/sha256"
@@ -9,37 +10,55 @@ import (
 	"hash"
 	"io"
 	"log"
-	"mime"
-	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/afero"
 
 	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/filetype"
 	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/subtitles"
+)
+
+const (
+	// maxTextSize is the size above which files aren't text.
+	maxTextSize = 10 * 1024 * 1024 // 10 MB
+	// listingWorkers is the number of files of a listing whose first
+	// bytes are read at once.
+	listingWorkers = 16
 )
 
 // FileInfo describes a file.
 type FileInfo struct {
 	*Listing
-	Fs        afero.Fs          `json:"-"`
-	Path      string            `json:"path"`
-	Name      string            `json:"name"`
-	Size      int64             `json:"size"`
-	Extension string            `json:"extension"`
-	ModTime   time.Time         `json:"modified"`
-	Mode      os.FileMode       `json:"mode"`
-	IsDir     bool              `json:"isDir"`
-	IsSymlink bool              `json:"isSymlink"`
-	Type      string            `json:"type"`
-	Subtitles []string          `json:"subtitles,omitempty"`
-	Content   string            `json:"content,omitempty"`
-	Checksums map[string]string `json:"checksums,omitempty"`
-	Token     string            `json:"token,omitempty"`
+	Fs        afero.Fs    `json:"-"`
+	Path      string      `json:"path"`
+	Name      string      `json:"name"`
+	Size      int64       `json:"size"`
+	Extension string      `json:"extension"`
+	ModTime   time.Time   `json:"modified"`
+	Mode      os.FileMode `json:"mode"`
+	IsDir     bool        `json:"isDir"`
+	IsSymlink bool        `json:"isSymlink"`
+	Type      string      `json:"type"`
+	// MimeType, Category and Language classify the file further than
+	// Type, see the filetype package.
+	MimeType string `json:"mimeType,omitempty"`
+	Category string `json:"category,omitempty"`
+	Language string `json:"language,omitempty"`
+	// PendingType is set on the entries of a listing whose type was
+	// detected from the extension only, see Listing.DetectTypes.
+	PendingType bool              `json:"pendingType,omitempty"`
+	Subtitles   []Subtitle        `json:"subtitles,omitempty"`
+	Content     string            `json:"content,omitempty"`
+	Checksums   map[string]string `json:"checksums,omitempty"`
+	Token       string            `json:"token,omitempty"`
 }
 
 // FileOptions are the options when getting a file info.
@@ -49,9 +68,15 @@ type FileOptions struct {
 	Modify     bool
 	Expand     bool
 	ReadHeader bool
-	Token      string
-	Checker    rules.Checker
-	Content    bool
+	// LazyType makes listings detect the types from the extensions
+	// only, without reading the first bytes of the files.
+	LazyType bool
+	Token    string
+	Checker  rules.Checker
+	Content  bool
+	// Context, if set, stops the detection of the types of a listing
+	// once done.
+	Context context.Context
 }
 
 // NewFileInfo creates a File object from a path and a given user. This File
@@ -69,7 +94,11 @@ func NewFileInfo(opts FileOptions) (*FileInfo, error) {
 
 	if opts.Expand {
 		if file.IsDir {
-			if err := file.readListing(opts.Checker, opts.ReadHeader); err != nil { //nolint:govet
+			ctx := opts.Context
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			if err := file.readListing(ctx, opts.Checker, opts.ReadHeader, opts.LazyType); err != nil { //nolint:govet
 				return nil, err
 			}
 			return file, nil
@@ -79,6 +108,9 @@ func NewFileInfo(opts FileOptions) (*FileInfo, error) {
 		if err != nil {
 			return nil, err
 		}
+		if file.Type == "video" {
+			file.detectSubtitles(opts.Checker)
+		}
 	}
 
 	return file, err
@@ -211,32 +243,32 @@ func (i *FileInfo) detectType(modify, saveContent, readHeader bool) error {
 	// of files couldn't be opened: we'd have immediately
 	// a 500 even though it doesn't matter. So we just log it.
 
-	mimetype := mime.TypeByExtension(i.Extension)
-
 	var buffer []byte
 	if readHeader {
 		buffer = i.readFirstBytes()
-
-		if mimetype == "" {
-			mimetype = http.DetectContentType(buffer)
-		}
 	}
 
+	ft := filetype.Detect(i.Name, buffer)
+	i.MimeType = ft.MIME
+	i.Category = string(ft.Category)
+	i.Language = ft.Language
+
+	isText := ft.Category == filetype.Text || ft.Category == filetype.Code || (!ft.Known() && !isBinary(buffer))
+
 	switch {
-	case strings.HasPrefix(mimetype, "video"):
+	case ft.Category == filetype.Video:
 		i.Type = "video"
-		i.detectSubtitles()
 		return nil
-	case strings.HasPrefix(mimetype, "audio"):
+	case ft.Category == filetype.Audio:
 		i.Type = "audio"
 		return nil
-	case strings.HasPrefix(mimetype, "image"):
+	case ft.Category == filetype.Image:
 		i.Type = "image"
 		return nil
-	case strings.HasSuffix(mimetype, "pdf"):
+	case ft.MIME == "application/pdf":
 		i.Type = "pdf"
 		return nil
-	case (strings.HasPrefix(mimetype, "text") || !isBinary(buffer)) && i.Size <= 10*1024*1024: // 10 MB
+	case isText && !isBinary(buffer) && i.Size <= maxTextSize:
 		i.Type = "text"
 
 		if !modify {
@@ -269,7 +301,7 @@ func (i *FileInfo) readFirstBytes() []byte {
 	}
 	defer reader.Close()
 
-	buffer := make([]byte, 512) //nolint:gomnd
+	buffer := make([]byte, filetype.HeaderLen)
 	n, err := reader.Read(buffer)
 	if err != nil && err != io.EOF {
 		log.Print(err)
@@ -280,34 +312,50 @@ func (i *FileInfo) readFirstBytes() []byte {
 	return buffer[:n]
 }
 
-func (i *FileInfo) detectSubtitles() {
-	if i.Type != "video" {
+// detectSubtitles finds the subtitle files named after the video, such
+// as movie.vtt, movie.en.srt or movie.pt-BR.forced.ass.
+func (i *FileInfo) detectSubtitles(checker rules.Checker) {
+	i.Subtitles = []Subtitle{}
+
+	parentDir := path.Dir(i.Path)
+	dir, err := afero.ReadDir(i.Fs, parentDir)
+	if err != nil {
 		return
 	}
 
-	i.Subtitles = []string{}
-	ext := filepath.Ext(i.Path)
+	base := strings.TrimSuffix(i.Name, filepath.Ext(i.Name))
+	for _, f := range dir {
+		ext := filepath.Ext(f.Name())
+		name := strings.TrimSuffix(f.Name(), ext)
+		if f.IsDir() || !subtitles.IsFormat(ext) {
+			continue
+		}
+		if name != base && !strings.HasPrefix(name, base+".") {
+			continue
+		}
 
-	// detect multiple languages. Base*.vtt
-	// TODO: give subtitles descriptive names (lang) and track attributes
-	parentDir := strings.TrimRight(i.Path, i.Name)
-	dir, err := afero.ReadDir(i.Fs, parentDir)
-	if err == nil {
-		base := strings.TrimSuffix(i.Name, ext)
-		for _, f := range dir {
-			if !f.IsDir() && strings.HasPrefix(f.Name(), base) && strings.HasSuffix(f.Name(), ".vtt") {
-				i.Subtitles = append(i.Subtitles, path.Join(parentDir, f.Name()))
-			}
+		fPath := path.Join(parentDir, f.Name())
+		if !checker.Check(fPath) {
+			continue
 		}
+
+		sub := Subtitle{Path: fPath, Format: strings.ToLower(strings.TrimPrefix(ext, "."))}
+		sub.Language, sub.Label = subtitleLanguage(strings.TrimPrefix(name, base))
+		i.Subtitles = append(i.Subtitles, sub)
 	}
 }
 
-func (i *FileInfo) readListing(checker rules.Checker, readHeader bool) error {
-	afs := &afero.Afero{Fs: i.Fs}
-	dir, err := afs.ReadDir(i.Path)
+func (i *FileInfo) readListing(ctx context.Context, checker rules.Checker, readHeader, lazyType bool) error {
+	dir, err := i.Fs.Open(i.Path)
+	if err != nil {
+		return err
+	}
+	names, err := dir.Readdirnames(-1)
+	dir.Close()
 	if err != nil {
 		return err
 	}
+	sort.Strings(names)
 
 	listing := &Listing{
 		Items:    []*FileInfo{},
@@ -315,57 +363,117 @@ func (i *FileInfo) readListing(checker rules.Checker, readHeader bool) error {
 		NumFiles: 0,
 	}
 
-	for _, f := range dir {
-		name := f.Name()
+	detect := []*FileInfo{}
+	for _, name := range names {
 		fPath := path.Join(i.Path, name)
 
 		if !checker.Check(fPath) {
 			continue
 		}
 
-		isSymlink, isInvalidLink := false, false
-		if IsSymlink(f.Mode()) {
-			isSymlink = true
-			// It's a symbolic link. We try to follow it. If it doesn't work,
-			// we stay with the link information instead of the target's.
-			info, err := i.Fs.Stat(fPath)
-			if err == nil {
-				f = info
+		// an entry that can't be read, or was removed in the meantime,
+		// is left out rather than failing the whole listing.
+		file, err := stat(FileOptions{Fs: i.Fs, Path: fPath}) //nolint:govet
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Print(err)
+			}
+			continue
+		}
+		file.Name = name
+		file.Extension = filepath.Ext(name)
+
+		// It's a symbolic link. We try to follow it. If it doesn't work,
+		// we stay with the link information instead of the target's.
+		isInvalidLink := false
+		if file.IsSymlink {
+			if info, err := i.Fs.Stat(fPath); err == nil { //nolint:govet
+				file.ModTime = info.ModTime()
+				file.Mode = info.Mode()
+				file.Size = info.Size()
+				file.IsDir = info.IsDir()
 			} else {
 				isInvalidLink = true
 			}
 		}
 
-		file := &FileInfo{
-			Fs:        i.Fs,
-			Name:      name,
-			Size:      f.Size(),
-			ModTime:   f.ModTime(),
-			Mode:      f.Mode(),
-			IsDir:     f.IsDir(),
-			IsSymlink: isSymlink,
-			Extension: filepath.Ext(name),
-			Path:      fPath,
-		}
-
 		if file.IsDir {
 			listing.NumDirs++
 		} else {
 			listing.NumFiles++
 
-			if isInvalidLink {
+			switch {
+			case isInvalidLink:
 				file.Type = "invalid_link"
-			} else {
-				err := file.detectType(true, false, readHeader)
-				if err != nil {
-					return err
-				}
+			case readHeader && !lazyType:
+				detect = append(detect, file)
+			default:
+				file.detectListingType(false)
+				file.PendingType = readHeader && file.needsHeader()
 			}
 		}
 
 		listing.Items = append(listing.Items, file)
 	}
 
+	forEachFile(ctx, detect, func(file *FileInfo) {
+		file.detectListingType(true)
+	})
+
 	i.Listing = listing
 	return nil
 }
+
+// detectListingType detects the type of an entry of a listing. Errors
+// are logged: an entry of a directory with thousands of files that
+// can't be read mustn't fail the whole listing.
+func (i *FileInfo) detectListingType(readHeader bool) {
+	if err := i.detectType(true, false, readHeader); err != nil {
+		log.Print(err)
+		i.Type = "blob"
+	}
+}
+
+// needsHeader returns whether the type detected from the extension
+// could change once the first bytes of the file are read.
+func (i *FileInfo) needsHeader() bool {
+	if IsNamedPipe(i.Mode) || i.Size > maxTextSize {
+		return false
+	}
+
+	return !filetype.ByName(i.Name).Known()
+}
+
+// forEachFile calls fn for each file with at most listingWorkers calls
+// at once. It stops early when ctx is done.
+func forEachFile(ctx context.Context, items []*FileInfo, fn func(file *FileInfo)) {
+	queue := make(chan *FileInfo)
+	wg := sync.WaitGroup{}
+
+	workers := listingWorkers
+	if len(items) < workers {
+		workers = len(items)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range queue {
+				fn(file)
+			}
+		}()
+	}
+
+	defer func() {
+		close(queue)
+		wg.Wait()
+	}()
+
+	for _, file := range items {
+		select {
+		case queue <- file:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+type allowAll struct{}
+
+func (allowAll) Check(string) bool { return true }
+
+// brokenFs fails to stat the files named "broken".
+type brokenFs struct {
+	afero.Fs
+}
+
+func (fs brokenFs) Stat(name string) (os.FileInfo, error) {
+	if path.Base(name) == "broken" {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: errors.New("i/o error")}
+	}
+	return fs.Fs.Stat(name)
+}
+
+func newListingFs(t *testing.T) afero.Fs {
+	t.Helper()
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/dir/sub", 0755))
+	require.NoError(t, afero.WriteFile(fs, "/dir/photo.jpg", []byte("not really"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/dir/notes", []byte("plain text"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/dir/data", []byte{0x00, 0x01, 0x02, 0x03}, 0644))
+	require.NoError(t, afero.WriteFile(fs, "/dir/broken", []byte("x"), 0644))
+	return brokenFs{fs}
+}
+
+func listingTypes(listing *Listing) map[string]string {
+	types := map[string]string{}
+	for _, item := range listing.Items {
+		types[item.Name] = item.Type
+	}
+	return types
+}
+
+func TestReadListing(t *testing.T) {
+	testCases := map[string]struct {
+		readHeader  bool
+		lazyType    bool
+		wantTypes   map[string]string
+		wantPending []string
+	}{
+		"by extension": {
+			wantTypes: map[string]string{"sub": "", "photo.jpg": "image", "notes": "text", "data": "text"},
+		},
+		"by header": {
+			readHeader: true,
+			wantTypes:  map[string]string{"sub": "", "photo.jpg": "image", "notes": "text", "data": "blob"},
+		},
+		"lazy": {
+			readHeader:  true,
+			lazyType:    true,
+			wantTypes:   map[string]string{"sub": "", "photo.jpg": "image", "notes": "text", "data": "text"},
+			wantPending: []string{"data", "notes"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			file, err := NewFileInfo(FileOptions{
+				Fs:         newListingFs(t),
+				Path:       "/dir",
+				Expand:     true,
+				ReadHeader: tc.readHeader,
+				LazyType:   tc.lazyType,
+				Checker:    allowAll{},
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.wantTypes, listingTypes(file.Listing))
+			require.Equal(t, 1, file.NumDirs)
+			require.Equal(t, 3, file.NumFiles)
+
+			pending := []string{}
+			for _, item := range file.Items {
+				if item.PendingType {
+					pending = append(pending, item.Name)
+				}
+			}
+			require.ElementsMatch(t, tc.wantPending, pending)
+
+			refined := map[string]string{}
+			err = file.Listing.DetectTypes(context.Background(), func(item *FileInfo) {
+				refined[item.Name] = item.Type
+			})
+			require.NoError(t, err)
+			if tc.lazyType {
+				require.Equal(t, map[string]string{"notes": "text", "data": "blob"}, refined)
+			} else {
+				require.Empty(t, refined)
+			}
+		})
+	}
+}
+
+func TestReadListing_Symlinks(t *testing.T) {
+	root := t.TempDir()
+	fs := afero.NewBasePathFs(afero.NewOsFs(), root)
+	require.NoError(t, fs.MkdirAll("/dir/target", 0755))
+	require.NoError(t, afero.WriteFile(fs, "/dir/file.txt", []byte("content"), 0600))
+	require.NoError(t, os.Symlink("file.txt", path.Join(root, "dir/file-link")))
+	require.NoError(t, os.Symlink("target", path.Join(root, "dir/dir-link")))
+	require.NoError(t, os.Symlink("missing", path.Join(root, "dir/broken-link")))
+
+	file, err := NewFileInfo(FileOptions{Fs: fs, Path: "/dir", Expand: true, Checker: allowAll{}})
+	require.NoError(t, err)
+
+	items := map[string]*FileInfo{}
+	for _, item := range file.Items {
+		items[item.Name] = item
+	}
+
+	// the links describe their targets
+	require.True(t, items["file-link"].IsSymlink)
+	require.Equal(t, os.FileMode(0600), items["file-link"].Mode)
+	require.Equal(t, int64(7), items["file-link"].Size)
+	require.True(t, items["dir-link"].IsDir)
+	require.True(t, items["dir-link"].Mode.IsDir())
+	require.Equal(t, "invalid_link", items["broken-link"].Type)
+	require.True(t, IsSymlink(items["broken-link"].Mode))
+}
+
+func TestDetectSubtitles(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, name := range []string{
+		"movie.mkv", "movie.vtt", "movie.en.srt", "movie.pt_BR.forced.ass",
+		"movie.sdh.SSA", "movie2.srt", "movie.nfo", "other.srt",
+	} {
+		require.NoError(t, afero.WriteFile(fs, "/videos/"+name, []byte("x"), 0644))
+	}
+
+	file, err := NewFileInfo(FileOptions{
+		Fs:      fs,
+		Path:    "/videos/movie.mkv",
+		Expand:  true,
+		Checker: allowAll{},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "video", file.Type)
+	require.ElementsMatch(t, []Subtitle{
+		{Path: "/videos/movie.vtt", Format: "vtt"},
+		{Path: "/videos/movie.en.srt", Format: "srt", Language: "en", Label: "English"},
+		{Path: "/videos/movie.pt_BR.forced.ass", Format: "ass", Language: "pt-BR", Label: "português (forced)"},
+		{Path: "/videos/movie.sdh.SSA", Format: "ssa", Label: "(sdh)"},
+	}, file.Subtitles)
+}
@@ -0,0 +1,128 @@
+package files
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestListing() *Listing {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &Listing{Items: []*FileInfo{
+		{Name: "b.txt", Extension: ".txt", Type: "text", Size: 30, ModTime: now},
+		{Name: "A.jpg", Extension: ".jpg", Type: "image", Size: 200, ModTime: now.Add(time.Hour)},
+		{Name: "docs", IsDir: true, Size: 4096, ModTime: now.Add(2 * time.Hour)},
+		{Name: "c.mp4", Extension: ".mp4", Type: "video", Size: 1000, ModTime: now.Add(3 * time.Hour)},
+		{Name: "a10.txt", Extension: ".TXT", Type: "text", Size: 10, ModTime: now},
+		{Name: "a2.txt", Extension: ".txt", Type: "text", Size: 10, ModTime: now},
+	}}
+}
+
+func itemNames(items []*FileInfo) []string {
+	names := []string{}
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+func TestListingApply(t *testing.T) {
+	after := time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		query     ListingQuery
+		want      []string
+		wantTotal int
+	}{
+		"name": {
+			query:     ListingQuery{Sorting: Sorting{By: "name", Asc: true}, DirsFirst: true},
+			want:      []string{"docs", "A.jpg", "a2.txt", "a10.txt", "b.txt", "c.mp4"},
+			wantTotal: 6,
+		},
+		"name descending": {
+			query:     ListingQuery{Sorting: Sorting{By: "name"}},
+			want:      []string{"docs", "c.mp4", "b.txt", "a10.txt", "a2.txt", "A.jpg"},
+			wantTotal: 6,
+		},
+		"read order": {
+			query:     ListingQuery{DirsFirst: true},
+			want:      []string{"b.txt", "A.jpg", "docs", "c.mp4", "a10.txt", "a2.txt"},
+			wantTotal: 6,
+		},
+		"size dirs mixed": {
+			query:     ListingQuery{Sorting: Sorting{By: "size", Asc: true}},
+			want:      []string{"a2.txt", "a10.txt", "b.txt", "A.jpg", "c.mp4", "docs"},
+			wantTotal: 6,
+		},
+		"type": {
+			query:     ListingQuery{Sorting: Sorting{By: "type", Asc: true}, DirsFirst: true},
+			want:      []string{"docs", "A.jpg", "a2.txt", "a10.txt", "b.txt", "c.mp4"},
+			wantTotal: 6,
+		},
+		"extension": {
+			query:     ListingQuery{Sorting: Sorting{By: "extension", Asc: true}},
+			want:      []string{"docs", "A.jpg", "c.mp4", "a2.txt", "a10.txt", "b.txt"},
+			wantTotal: 6,
+		},
+		"filters": {
+			query: ListingQuery{
+				Sorting:    Sorting{By: "name", Asc: true},
+				Extensions: []string{"txt", ".mp4"},
+				MinSize:    20,
+			},
+			want:      []string{"b.txt", "c.mp4", "docs"},
+			wantTotal: 3,
+		},
+		"types and time": {
+			query: ListingQuery{
+				Sorting:       Sorting{By: "modified", Asc: true},
+				Types:         []string{"directory", "image"},
+				ModifiedAfter: after,
+			},
+			want:      []string{"A.jpg", "docs"},
+			wantTotal: 2,
+		},
+		"page": {
+			query:     ListingQuery{Sorting: Sorting{By: "name", Asc: true}, Offset: 2, Limit: 2},
+			want:      []string{"a10.txt", "b.txt"},
+			wantTotal: 6,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			listing := newTestListing()
+			require.NoError(t, listing.Apply(tc.query))
+			require.Equal(t, tc.want, itemNames(listing.Items))
+			require.Equal(t, tc.wantTotal, listing.Total)
+			require.Equal(t, tc.wantTotal, listing.NumDirs+listing.NumFiles)
+		})
+	}
+}
+
+func TestListingCursor(t *testing.T) {
+	query := ListingQuery{Sorting: Sorting{By: "size", Asc: true}, DirsFirst: true, Limit: 2}
+
+	listing := newTestListing()
+	require.NoError(t, listing.Apply(query))
+	require.Equal(t, []string{"docs", "a2.txt"}, itemNames(listing.Items))
+	require.NotEmpty(t, listing.NextCursor)
+
+	// the last item of the page is removed before the next page
+	query.Cursor = listing.NextCursor
+	listing = newTestListing()
+	listing.Items = listing.Items[:5]
+	require.NoError(t, listing.Apply(query))
+	require.Equal(t, []string{"a10.txt", "b.txt"}, itemNames(listing.Items))
+	require.Equal(t, 1, listing.Offset)
+
+	query.Cursor = listing.NextCursor
+	listing = newTestListing()
+	require.NoError(t, listing.Apply(query))
+	require.Equal(t, []string{"A.jpg", "c.mp4"}, itemNames(listing.Items))
+	require.Empty(t, listing.NextCursor)
+
+	query.Cursor = "not a cursor"
+	require.Error(t, newTestListing().Apply(query))
+}
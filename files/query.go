@@ -0,0 +1,249 @@
+package files
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maruel/natural"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// ListingQuery selects, orders and pages the items of a listing.
+type ListingQuery struct {
+	Sorting
+	// DirsFirst puts the directories before the files, whatever the order.
+	DirsFirst bool
+	// Types keeps the items of one of the types. Directories have the
+	// type "directory".
+	Types []string
+	// Extensions keeps the files with one of the extensions, which are
+	// matched without the dot and regardless of the case.
+	Extensions []string
+	// MinSize and MaxSize keep the files within the range of sizes. A
+	// zero MaxSize means no maximum.
+	MinSize int64
+	MaxSize int64
+	// ModifiedAfter and ModifiedBefore keep the items modified within
+	// the range of times. Zero times mean no bound.
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+	// Offset is the position of the page, unless Cursor is set, which
+	// resumes after the last item of the previous page. A zero Limit
+	// returns all the items.
+	Offset int
+	Limit  int
+	Cursor string
+}
+
+// cursor identifies the last item of a page by its sort keys, so that
+// the next page starts at the right place even if it was removed.
+type cursor struct {
+	Name    string    `json:"n"`
+	IsDir   bool      `json:"d,omitempty"`
+	Size    int64     `json:"s,omitempty"`
+	ModTime time.Time `json:"m"`
+	Type    string    `json:"t,omitempty"`
+}
+
+// Apply filters, sorts and pages the items of the listing. The counts
+// of the listing are those of the items matching the filters.
+func (l *Listing) Apply(q ListingQuery) error {
+	items := []*FileInfo{}
+	numDirs, numFiles := 0, 0
+	for _, item := range l.Items {
+		if !q.match(item) {
+			continue
+		}
+		if item.IsDir {
+			numDirs++
+		} else {
+			numFiles++
+		}
+		items = append(items, item)
+	}
+
+	if !q.keepsOrder() {
+		sort.Slice(items, func(i, j int) bool {
+			return q.less(items[i], items[j])
+		})
+	}
+
+	offset := q.Offset
+	if q.Cursor != "" {
+		after, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return err
+		}
+		offset = sort.Search(len(items), func(i int) bool {
+			return q.less(after, items[i])
+		})
+	}
+	if offset < 0 {
+		return errors.ErrInvalidRequestParams
+	}
+	if offset > len(items) {
+		offset = len(items)
+	}
+
+	end := len(items)
+	if q.Limit > 0 && offset+q.Limit < end {
+		end = offset + q.Limit
+	}
+
+	l.Items = items[offset:end]
+	l.NumDirs = numDirs
+	l.NumFiles = numFiles
+	l.Sorting = q.Sorting
+	l.Total = len(items)
+	l.Offset = offset
+	l.NextCursor = ""
+	if end < len(items) {
+		l.NextCursor = encodeCursor(items[end-1])
+	}
+
+	return nil
+}
+
+func (q *ListingQuery) match(item *FileInfo) bool {
+	if len(q.Types) > 0 {
+		typ := item.Type
+		if item.IsDir {
+			typ = "directory"
+		}
+		if !containsFold(q.Types, typ) {
+			return false
+		}
+	}
+
+	if !q.ModifiedAfter.IsZero() && item.ModTime.Before(q.ModifiedAfter) {
+		return false
+	}
+	if !q.ModifiedBefore.IsZero() && item.ModTime.After(q.ModifiedBefore) {
+		return false
+	}
+
+	if item.IsDir {
+		return true
+	}
+
+	if len(q.Extensions) > 0 && !containsFold(q.Extensions, strings.TrimPrefix(item.Extension, ".")) {
+		return false
+	}
+
+	return item.Size >= q.MinSize && (q.MaxSize == 0 || item.Size <= q.MaxSize)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimPrefix(v, "."), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// keepsOrder tells whether the items keep the order they were read in,
+// which is that of their exact names, as they do without a sort key in
+// descending order.
+func (q *ListingQuery) keepsOrder() bool {
+	return q.By == "" && !q.Asc
+}
+
+// less orders the items by the sort key, then by name so that the
+// order is total, which the cursors rely on.
+func (q *ListingQuery) less(a, b *FileInfo) bool {
+	if q.keepsOrder() {
+		return a.Name < b.Name
+	}
+	if q.DirsFirst && a.IsDir != b.IsDir {
+		return a.IsDir
+	}
+
+	c := q.compare(a, b)
+	if c == 0 {
+		c = compareNames(a.Name, b.Name)
+	}
+	if !q.Asc {
+		c = -c
+	}
+
+	return c < 0
+}
+
+//nolint:goconst
+func (q *ListingQuery) compare(a, b *FileInfo) int {
+	switch q.By {
+	case "size":
+		return compareInts(a.Size, b.Size)
+	case "modified":
+		return compareInts(a.ModTime.UnixNano(), b.ModTime.UnixNano())
+	case "type":
+		return strings.Compare(a.Type, b.Type)
+	case "extension":
+		return strings.Compare(strings.ToLower(a.Extension), strings.ToLower(b.Extension))
+	default:
+		return 0
+	}
+}
+
+// compareNames treats upper and lower case equally, falling back to
+// the exact names for the names that only differ by case.
+func compareNames(a, b string) int {
+	la, lb := strings.ToLower(a), strings.ToLower(b)
+	switch {
+	case natural.Less(la, lb):
+		return -1
+	case natural.Less(lb, la):
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func encodeCursor(item *FileInfo) string {
+	data, _ := json.Marshal(cursor{
+		Name:    item.Name,
+		IsDir:   item.IsDir,
+		Size:    item.Size,
+		ModTime: item.ModTime,
+		Type:    item.Type,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*FileInfo, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.ErrInvalidRequestParams
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, errors.ErrInvalidRequestParams
+	}
+
+	return &FileInfo{
+		Name:      c.Name,
+		IsDir:     c.IsDir,
+		Size:      c.Size,
+		ModTime:   c.ModTime,
+		Type:      c.Type,
+		Extension: filepath.Ext(c.Name),
+	}, nil
+}
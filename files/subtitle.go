@@ -0,0 +1,67 @@
+package files
+
+import (
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// Subtitle is a subtitle file of a video.
+type Subtitle struct {
+	Path string `json:"path"`
+	// Format is the extension of the file, without the dot.
+	Format string `json:"format"`
+	// Language is the BCP 47 tag of the language, if known.
+	Language string `json:"language,omitempty"`
+	Label    string `json:"label,omitempty"`
+}
+
+// subtitleFlags are the tags of subtitle names that aren't languages.
+var subtitleFlags = map[string]bool{
+	"forced":  true,
+	"sdh":     true,
+	"cc":      true,
+	"default": true,
+}
+
+// subtitleLanguage parses the tags between the base name of the video
+// and the extension of a subtitle file, like ".en.forced", into the
+// language and a label describing the track.
+func subtitleLanguage(tags string) (lang, label string) {
+	flags := []string{}
+	for _, tag := range strings.Split(strings.Trim(tags, "."), ".") {
+		if tag == "" {
+			continue
+		}
+		if subtitleFlags[strings.ToLower(tag)] {
+			flags = append(flags, strings.ToLower(tag))
+			continue
+		}
+		if lang != "" || !looksLikeLanguage(tag) {
+			continue
+		}
+		if t, err := language.Parse(strings.ReplaceAll(tag, "_", "-")); err == nil {
+			lang = t.String()
+			label = display.Self.Name(t)
+		}
+	}
+
+	if label == "" {
+		label = lang
+	}
+	if len(flags) > 0 {
+		label = strings.TrimSpace(label + " (" + strings.Join(flags, ", ") + ")")
+	}
+	return lang, label
+}
+
+// looksLikeLanguage keeps the tags such as en, eng or pt-BR, as longer
+// words could be mistaken for rare languages.
+func looksLikeLanguage(tag string) bool {
+	primary := tag
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		primary = tag[:i]
+	}
+	return len(primary) == 2 || len(primary) == 3 //nolint:gomnd
+}
@@ -0,0 +1,233 @@
+package filetype
+
+// byExtension classifies the extensions whose type is either missing
+// from the system MIME database or needs a category or a language.
+var byExtension = map[string]Type{
+	// documents
+	".pdf":  {MIME: "application/pdf", Category: Document},
+	".doc":  {MIME: "application/msword", Category: Document},
+	".docx": {MIME: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", Category: Document},
+	".odt":  {MIME: "application/vnd.oasis.opendocument.text", Category: Document},
+	".rtf":  {MIME: "application/rtf", Category: Document},
+	".epub": {MIME: "application/epub+zip", Category: Document},
+	".djvu": {MIME: "image/vnd.djvu", Category: Document},
+	".ps":   {MIME: "application/postscript", Category: Document},
+	".xls":  {MIME: "application/vnd.ms-excel", Category: Spreadsheet},
+	".xlsx": {MIME: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Category: Spreadsheet},
+	".ods":  {MIME: "application/vnd.oasis.opendocument.spreadsheet", Category: Spreadsheet},
+	".csv":  {MIME: "text/csv", Category: Spreadsheet},
+	".tsv":  {MIME: "text/tab-separated-values", Category: Spreadsheet},
+	".ppt":  {MIME: "application/vnd.ms-powerpoint", Category: Presentation},
+	".pptx": {MIME: "application/vnd.openxmlformats-officedocument.presentationml.presentation", Category: Presentation},
+	".odp":  {MIME: "application/vnd.oasis.opendocument.presentation", Category: Presentation},
+
+	// archives
+	".zip":  {MIME: "application/zip", Category: Archive},
+	".rar":  {MIME: "application/vnd.rar", Category: Archive},
+	".7z":   {MIME: "application/x-7z-compressed", Category: Archive},
+	".tar":  {MIME: "application/x-tar", Category: Archive},
+	".gz":   {MIME: "application/gzip", Category: Archive},
+	".tgz":  {MIME: "application/gzip", Category: Archive},
+	".bz2":  {MIME: "application/x-bzip2", Category: Archive},
+	".tbz2": {MIME: "application/x-bzip2", Category: Archive},
+	".xz":   {MIME: "application/x-xz", Category: Archive},
+	".txz":  {MIME: "application/x-xz", Category: Archive},
+	".zst":  {MIME: "application/zstd", Category: Archive},
+	".lz4":  {MIME: "application/x-lz4", Category: Archive},
+	".sz":   {MIME: "application/x-snappy-framed", Category: Archive},
+	".cab":  {MIME: "application/vnd.ms-cab-compressed", Category: Archive},
+	".jar":  {MIME: "application/java-archive", Category: Archive},
+	".deb":  {MIME: "application/vnd.debian.binary-package", Category: Archive},
+	".rpm":  {MIME: "application/x-rpm", Category: Archive},
+
+	// fonts
+	".ttf":   {MIME: "font/ttf", Category: Font},
+	".otf":   {MIME: "font/otf", Category: Font},
+	".ttc":   {MIME: "font/collection", Category: Font},
+	".woff":  {MIME: "font/woff", Category: Font},
+	".woff2": {MIME: "font/woff2", Category: Font},
+
+	// disk images
+	".iso":   {MIME: "application/x-iso9660-image", Category: DiskImage},
+	".img":   {MIME: "application/x-raw-disk-image", Category: DiskImage},
+	".dmg":   {MIME: "application/x-apple-diskimage", Category: DiskImage},
+	".qcow2": {MIME: "application/x-qemu-disk", Category: DiskImage},
+	".vmdk":  {MIME: "application/x-vmdk", Category: DiskImage},
+	".vhd":   {MIME: "application/x-vhd", Category: DiskImage},
+	".vhdx":  {MIME: "application/x-vhdx", Category: DiskImage},
+	".vdi":   {MIME: "application/x-virtualbox-vdi", Category: DiskImage},
+
+	// executables
+	".exe":      {MIME: "application/vnd.microsoft.portable-executable", Category: Executable},
+	".dll":      {MIME: "application/vnd.microsoft.portable-executable", Category: Executable},
+	".msi":      {MIME: "application/x-msi", Category: Executable},
+	".so":       {MIME: "application/x-sharedlib", Category: Executable},
+	".apk":      {MIME: "application/vnd.android.package-archive", Category: Executable},
+	".appimage": {MIME: "application/x-executable", Category: Executable},
+	".wasm":     {MIME: "application/wasm", Category: Executable},
+
+	// media missing from some MIME databases
+	".mkv":  {MIME: "video/x-matroska", Category: Video},
+	".webm": {MIME: "video/webm", Category: Video},
+	".mp4":  {MIME: "video/mp4", Category: Video},
+	".m4v":  {MIME: "video/mp4", Category: Video},
+	".mov":  {MIME: "video/quicktime", Category: Video},
+	".avi":  {MIME: "video/x-msvideo", Category: Video},
+	".mp3":  {MIME: "audio/mpeg", Category: Audio},
+	".flac": {MIME: "audio/flac", Category: Audio},
+	".ogg":  {MIME: "audio/ogg", Category: Audio},
+	".opus": {MIME: "audio/ogg", Category: Audio},
+	".m4a":  {MIME: "audio/mp4", Category: Audio},
+	".wav":  {MIME: "audio/wav", Category: Audio},
+	".webp": {MIME: "image/webp", Category: Image},
+	".avif": {MIME: "image/avif", Category: Image},
+	".heic": {MIME: "image/heic", Category: Image},
+	".heif": {MIME: "image/heif", Category: Image},
+	".jxl":  {MIME: "image/jxl", Category: Image},
+	".svg":  {MIME: "image/svg+xml", Category: Image},
+
+	// camera RAW
+	".cr2": {MIME: "image/x-canon-cr2", Category: Image},
+	".cr3": {MIME: "image/x-canon-cr3", Category: Image},
+	".nef": {MIME: "image/x-nikon-nef", Category: Image},
+	".nrw": {MIME: "image/x-nikon-nrw", Category: Image},
+	".arw": {MIME: "image/x-sony-arw", Category: Image},
+	".srf": {MIME: "image/x-sony-srf", Category: Image},
+	".sr2": {MIME: "image/x-sony-sr2", Category: Image},
+	".dng": {MIME: "image/x-adobe-dng", Category: Image},
+	".orf": {MIME: "image/x-olympus-orf", Category: Image},
+	".rw2": {MIME: "image/x-panasonic-rw2", Category: Image},
+	".pef": {MIME: "image/x-pentax-pef", Category: Image},
+	".srw": {MIME: "image/x-samsung-srw", Category: Image},
+	".erf": {MIME: "image/x-epson-erf", Category: Image},
+	".3fr": {MIME: "image/x-hasselblad-3fr", Category: Image},
+
+	// text
+	".txt":  {MIME: "text/plain", Category: Text},
+	".log":  {MIME: "text/plain", Category: Text},
+	".md":   {MIME: "text/markdown", Category: Text, Language: "Markdown"},
+	".rst":  {MIME: "text/x-rst", Category: Text, Language: "reStructuredText"},
+	".srt":  {MIME: "application/x-subrip", Category: Text},
+	".vtt":  {MIME: "text/vtt", Category: Text},
+	".ass":  {MIME: "text/x-ssa", Category: Text},
+	".ssa":  {MIME: "text/x-ssa", Category: Text},
+	".ini":  {MIME: "text/plain", Category: Text, Language: "INI"},
+	".conf": {MIME: "text/plain", Category: Text},
+	".env":  {MIME: "text/plain", Category: Text},
+}
+
+// code maps the extensions of source files to their language.
+var code = map[string]string{
+	".go":      "Go",
+	".py":      "Python",
+	".js":      "JavaScript",
+	".mjs":     "JavaScript",
+	".cjs":     "JavaScript",
+	".jsx":     "JavaScript",
+	".ts":      "TypeScript",
+	".tsx":     "TypeScript",
+	".vue":     "Vue",
+	".svelte":  "Svelte",
+	".rs":      "Rust",
+	".c":       "C",
+	".h":       "C",
+	".cc":      "C++",
+	".cpp":     "C++",
+	".cxx":     "C++",
+	".hpp":     "C++",
+	".cs":      "C#",
+	".java":    "Java",
+	".kt":      "Kotlin",
+	".kts":     "Kotlin",
+	".scala":   "Scala",
+	".swift":   "Swift",
+	".m":       "Objective-C",
+	".rb":      "Ruby",
+	".php":     "PHP",
+	".pl":      "Perl",
+	".lua":     "Lua",
+	".r":       "R",
+	".dart":    "Dart",
+	".ex":      "Elixir",
+	".exs":     "Elixir",
+	".erl":     "Erlang",
+	".hs":      "Haskell",
+	".clj":     "Clojure",
+	".zig":     "Zig",
+	".nim":     "Nim",
+	".sh":      "Shell",
+	".bash":    "Shell",
+	".zsh":     "Shell",
+	".fish":    "Shell",
+	".ps1":     "PowerShell",
+	".bat":     "Batch",
+	".cmd":     "Batch",
+	".sql":     "SQL",
+	".html":    "HTML",
+	".htm":     "HTML",
+	".css":     "CSS",
+	".scss":    "SCSS",
+	".sass":    "Sass",
+	".less":    "Less",
+	".json":    "JSON",
+	".yaml":    "YAML",
+	".yml":     "YAML",
+	".toml":    "TOML",
+	".xml":     "XML",
+	".proto":   "Protocol Buffers",
+	".tf":      "HCL",
+	".graphql": "GraphQL",
+	".tex":     "TeX",
+}
+
+// byFilename classifies the files known by their whole name.
+var byFilename = map[string]Type{
+	"Makefile":       {MIME: "text/plain", Category: Code, Language: "Makefile"},
+	"GNUmakefile":    {MIME: "text/plain", Category: Code, Language: "Makefile"},
+	"Dockerfile":     {MIME: "text/plain", Category: Code, Language: "Dockerfile"},
+	"Containerfile":  {MIME: "text/plain", Category: Code, Language: "Dockerfile"},
+	"CMakeLists.txt": {MIME: "text/plain", Category: Code, Language: "CMake"},
+	"Jenkinsfile":    {MIME: "text/plain", Category: Code, Language: "Groovy"},
+	"Vagrantfile":    {MIME: "text/plain", Category: Code, Language: "Ruby"},
+	"Gemfile":        {MIME: "text/plain", Category: Code, Language: "Ruby"},
+	"Rakefile":       {MIME: "text/plain", Category: Code, Language: "Ruby"},
+	"go.mod":         {MIME: "text/plain", Category: Code, Language: "Go Module"},
+}
+
+// interpreters maps the interpreters of scripts to their language.
+var interpreters = map[string]string{
+	"sh":      "Shell",
+	"bash":    "Shell",
+	"zsh":     "Shell",
+	"dash":    "Shell",
+	"ksh":     "Shell",
+	"fish":    "Shell",
+	"python":  "Python",
+	"node":    "JavaScript",
+	"deno":    "TypeScript",
+	"ruby":    "Ruby",
+	"perl":    "Perl",
+	"php":     "PHP",
+	"lua":     "Lua",
+	"Rscript": "R",
+	"pwsh":    "PowerShell",
+}
+
+func init() {
+	for ext, language := range code {
+		mimetype := "text/plain"
+		switch ext {
+		case ".html", ".htm":
+			mimetype = "text/html"
+		case ".css":
+			mimetype = "text/css"
+		case ".js", ".mjs", ".cjs":
+			mimetype = "text/javascript"
+		case ".json":
+			mimetype = "application/json"
+		case ".xml":
+			mimetype = "text/xml"
+		}
+		byExtension[ext] = Type{MIME: mimetype, Category: Code, Language: language}
+	}
+}
@@ -0,0 +1,152 @@
+// Package filetype classifies files from their names and first bytes.
+package filetype
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Category is a broad kind of files.
+type Category string
+
+const (
+	Video        Category = "video"
+	Audio        Category = "audio"
+	Image        Category = "image"
+	Document     Category = "document"
+	Spreadsheet  Category = "spreadsheet"
+	Presentation Category = "presentation"
+	Archive      Category = "archive"
+	Code         Category = "code"
+	Text         Category = "text"
+	Font         Category = "font"
+	DiskImage    Category = "disk-image"
+	Executable   Category = "executable"
+	Other        Category = "other"
+)
+
+// Categories lists all the categories.
+var Categories = []Category{
+	Video, Audio, Image, Document, Spreadsheet, Presentation, Archive,
+	Code, Text, Font, DiskImage, Executable, Other,
+}
+
+// HeaderLen is the number of first bytes Detect makes use of.
+const HeaderLen = 512
+
+// Type is the type of a file.
+type Type struct {
+	MIME     string   `json:"mimeType"`
+	Category Category `json:"category"`
+	// Language is the programming or markup language of source code.
+	Language string `json:"language,omitempty"`
+}
+
+// Known returns whether the type was recognized.
+func (t Type) Known() bool {
+	return t.Category != "" && t.Category != Other
+}
+
+// ByName classifies a file from its name only.
+func ByName(name string) Type {
+	base := path.Base(name)
+	if t, ok := byFilename[base]; ok {
+		return t
+	}
+
+	ext := strings.ToLower(path.Ext(base))
+	if ext == "" {
+		return Type{}
+	}
+	if t, ok := byExtension[ext]; ok {
+		return t
+	}
+
+	mimetype := mime.TypeByExtension(ext)
+	if mimetype == "" {
+		return Type{}
+	}
+	return Type{MIME: mimetype, Category: categoryOf(mimetype)}
+}
+
+// Detect classifies a file from its name and its first bytes, which
+// may be empty if they couldn't be read. The signature of the content
+// wins over the extension, except for the generic containers that are
+// the base of several formats, such as zip for office documents.
+func Detect(name string, header []byte) Type {
+	byName := ByName(name)
+	if len(header) == 0 {
+		return byName
+	}
+
+	if sig, ok := matchSignature(header); ok {
+		if sig.container && byName.Known() && byName.Category != Text && byName.Category != Code {
+			return byName
+		}
+		return sig.Type
+	}
+
+	if byName.MIME != "" {
+		return byName
+	}
+
+	if lang := shebangLanguage(header); lang != "" {
+		return Type{MIME: "text/plain", Category: Code, Language: lang}
+	}
+
+	mimetype := http.DetectContentType(header)
+	if i := strings.IndexByte(mimetype, ';'); i >= 0 {
+		mimetype = mimetype[:i]
+	}
+	if mimetype == "application/octet-stream" {
+		return Type{MIME: mimetype, Category: Other}
+	}
+	return Type{MIME: mimetype, Category: categoryOf(mimetype)}
+}
+
+// categoryOf guesses the category of a MIME type that isn't part of
+// the known types.
+func categoryOf(mimetype string) Category {
+	switch {
+	case strings.HasPrefix(mimetype, "video/"):
+		return Video
+	case strings.HasPrefix(mimetype, "audio/"):
+		return Audio
+	case strings.HasPrefix(mimetype, "image/"):
+		return Image
+	case strings.HasPrefix(mimetype, "font/"):
+		return Font
+	case strings.HasPrefix(mimetype, "text/"):
+		return Text
+	default:
+		return Other
+	}
+}
+
+// shebangLanguage returns the language of a script from its
+// interpreter directive.
+func shebangLanguage(header []byte) string {
+	if !bytes.HasPrefix(header, []byte("#!")) {
+		return ""
+	}
+
+	line := header[2:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" && len(fields) > 1 {
+		interpreter = fields[1]
+	}
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+
+	return interpreters[interpreter]
+}
@@ -0,0 +1,103 @@
+package filetype
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	tar := make([]byte, 512)
+	copy(tar[257:], "ustar")
+
+	testCases := map[string]struct {
+		name   string
+		header []byte
+		want   Type
+	}{
+		"extension only": {
+			name: "photo.JPG",
+			want: Type{MIME: "image/jpeg", Category: Image},
+		},
+		"signature wins": {
+			name:   "photo.txt",
+			header: []byte("\x89PNG\r\n\x1A\n...."),
+			want:   Type{MIME: "image/png", Category: Image},
+		},
+		"office document in zip": {
+			name:   "report.docx",
+			header: []byte("PK\x03\x04[Content_Types].xml"),
+			want:   Type{MIME: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", Category: Document},
+		},
+		"zip without extension": {
+			name:   "bundle",
+			header: []byte("PK\x03\x04"),
+			want:   Type{MIME: "application/zip", Category: Archive},
+		},
+		"camera RAW in TIFF": {
+			name:   "DSC_0042.NEF",
+			header: []byte("MM\x00*\x00\x00\x00\x08"),
+			want:   Type{MIME: "image/x-nikon-nef", Category: Image},
+		},
+		"tar": {
+			name:   "backup",
+			header: tar,
+			want:   Type{MIME: "application/x-tar", Category: Archive},
+		},
+		"source code": {
+			name:   "main.go",
+			header: []byte("package main\n"),
+			want:   Type{MIME: "text/plain", Category: Code, Language: "Go"},
+		},
+		"file name": {
+			name: "/src/Dockerfile",
+			want: Type{MIME: "text/plain", Category: Code, Language: "Dockerfile"},
+		},
+		"shebang": {
+			name:   "deploy",
+			header: []byte("#!/usr/bin/env python3\nprint()\n"),
+			want:   Type{MIME: "text/plain", Category: Code, Language: "Python"},
+		},
+		"font": {
+			name:   "font.bin",
+			header: []byte("wOF2\x00\x01"),
+			want:   Type{MIME: "font/woff2", Category: Font},
+		},
+		"disk image": {
+			name:   "vm.disk",
+			header: []byte("QFI\xFB\x00\x00\x00\x03"),
+			want:   Type{MIME: "application/x-qemu-disk", Category: DiskImage},
+		},
+		"executable": {
+			name:   "tool",
+			header: []byte("\x7FELF\x02\x01\x01"),
+			want:   Type{MIME: "application/x-executable", Category: Executable},
+		},
+		"heic": {
+			name:   "IMG_0001.HEIC",
+			header: []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00"),
+			want:   Type{MIME: "image/heic", Category: Image},
+		},
+		"svg": {
+			name:   "drawing",
+			header: []byte("<?xml version=\"1.0\"?>\n<svg xmlns=\"http://www.w3.org/2000/svg\"/>"),
+			want:   Type{MIME: "image/svg+xml", Category: Image},
+		},
+		"plain text": {
+			name:   "README",
+			header: []byte("hello world\n"),
+			want:   Type{MIME: "text/plain", Category: Text},
+		},
+		"unknown binary": {
+			name:   "blob",
+			header: []byte{0x00, 0x01, 0x02, 0x03},
+			want:   Type{MIME: "application/octet-stream", Category: Other},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, Detect(tc.name, tc.header))
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package filetype
+
+import (
+	"bytes"
+)
+
+// signature identifies a format by the bytes found at an offset.
+type signature struct {
+	offset int
+	magic  []byte
+	Type
+	// container marks the formats other formats are built upon, for
+	// which a more specific extension is trusted.
+	container bool
+}
+
+func sig(offset int, magic, mimetype string, category Category) signature {
+	return signature{offset: offset, magic: []byte(magic), Type: Type{MIME: mimetype, Category: category}}
+}
+
+func containerSig(offset int, magic, mimetype string, category Category) signature {
+	s := sig(offset, magic, mimetype, category)
+	s.container = true
+	return s
+}
+
+// signatures are checked in order, the longer ones first when they
+// share a prefix.
+var signatures = []signature{
+	// images
+	sig(0, "\xFF\xD8\xFF", "image/jpeg", Image),
+	sig(0, "\x89PNG\r\n\x1A\n", "image/png", Image),
+	sig(0, "GIF87a", "image/gif", Image),
+	sig(0, "GIF89a", "image/gif", Image),
+	sig(0, "BM", "image/bmp", Image),
+	// camera RAW files are TIFF files
+	containerSig(0, "II*\x00", "image/tiff", Image),
+	containerSig(0, "MM\x00*", "image/tiff", Image),
+	sig(0, "\x00\x00\x01\x00", "image/vnd.microsoft.icon", Image),
+	sig(0, "8BPS", "image/vnd.adobe.photoshop", Image),
+	sig(0, "\xFF\x0A", "image/jxl", Image),
+	sig(0, "\x00\x00\x00\x0CJXL \r\n\x87\n", "image/jxl", Image),
+
+	// audio and video
+	sig(0, "ID3", "audio/mpeg", Audio),
+	sig(0, "fLaC", "audio/flac", Audio),
+	sig(0, "OggS", "audio/ogg", Audio),
+	sig(0, "MThd", "audio/midi", Audio),
+	sig(0, "#!AMR", "audio/amr", Audio),
+	sig(0, "\x1A\x45\xDF\xA3", "video/x-matroska", Video),
+	sig(0, "FLV", "video/x-flv", Video),
+	sig(0, "\x00\x00\x01\xBA", "video/mpeg", Video),
+	sig(0, "\x00\x00\x01\xB3", "video/mpeg", Video),
+	sig(0, "\x30\x26\xB2\x75\x8E\x66\xCF\x11", "video/x-ms-asf", Video),
+
+	// documents
+	sig(0, "%PDF-", "application/pdf", Document),
+	sig(0, "{\\rtf", "application/rtf", Document),
+	sig(0, "%!PS", "application/postscript", Document),
+	sig(0, "AT&TFORM", "image/vnd.djvu", Document),
+	sig(0, "SQLite format 3\x00", "application/vnd.sqlite3", Other),
+
+	// archives
+	containerSig(0, "PK\x03\x04", "application/zip", Archive),
+	containerSig(0, "PK\x05\x06", "application/zip", Archive),
+	containerSig(0, "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1", "application/x-ole-storage", Other),
+	sig(0, "Rar!\x1A\x07", "application/vnd.rar", Archive),
+	sig(0, "7z\xBC\xAF\x27\x1C", "application/x-7z-compressed", Archive),
+	sig(0, "\x1F\x8B", "application/gzip", Archive),
+	sig(0, "BZh", "application/x-bzip2", Archive),
+	sig(0, "\xFD7zXZ\x00", "application/x-xz", Archive),
+	sig(0, "\x28\xB5\x2F\xFD", "application/zstd", Archive),
+	sig(0, "\x04\x22\x4D\x18", "application/x-lz4", Archive),
+	sig(0, "MSCF", "application/vnd.ms-cab-compressed", Archive),
+	sig(0, "!<arch>\n", "application/x-archive", Archive),
+	sig(257, "ustar", "application/x-tar", Archive),
+
+	// fonts
+	sig(0, "wOFF", "font/woff", Font),
+	sig(0, "wOF2", "font/woff2", Font),
+	sig(0, "OTTO", "font/otf", Font),
+	sig(0, "ttcf", "font/collection", Font),
+	sig(0, "\x00\x01\x00\x00\x00", "font/ttf", Font),
+
+	// disk images
+	sig(0, "QFI\xFB", "application/x-qemu-disk", DiskImage),
+	sig(0, "KDMV", "application/x-vmdk", DiskImage),
+	sig(0, "conectix", "application/x-vhd", DiskImage),
+	sig(0, "vhdxfile", "application/x-vhdx", DiskImage),
+	sig(0, "<<< Oracle VM VirtualBox Disk Image >>>", "application/x-virtualbox-vdi", DiskImage),
+
+	// executables
+	sig(0, "\x7FELF", "application/x-executable", Executable),
+	sig(0, "MZ", "application/vnd.microsoft.portable-executable", Executable),
+	sig(0, "\xFE\xED\xFA\xCE", "application/x-mach-binary", Executable),
+	sig(0, "\xFE\xED\xFA\xCF", "application/x-mach-binary", Executable),
+	sig(0, "\xCE\xFA\xED\xFE", "application/x-mach-binary", Executable),
+	sig(0, "\xCF\xFA\xED\xFE", "application/x-mach-binary", Executable),
+	sig(0, "\x00asm", "application/wasm", Executable),
+	sig(0, "dex\n", "application/vnd.android.dex", Executable),
+}
+
+// ftypBrands maps the brands of ISO base media files to their types.
+var ftypBrands = map[string]Type{
+	"avif": {MIME: "image/avif", Category: Image},
+	"avis": {MIME: "image/avif", Category: Image},
+	"heic": {MIME: "image/heic", Category: Image},
+	"heix": {MIME: "image/heic", Category: Image},
+	"mif1": {MIME: "image/heif", Category: Image},
+	"msf1": {MIME: "image/heif", Category: Image},
+	"M4A ": {MIME: "audio/mp4", Category: Audio},
+	"M4B ": {MIME: "audio/mp4", Category: Audio},
+	"qt  ": {MIME: "video/quicktime", Category: Video},
+	"3gp4": {MIME: "video/3gpp", Category: Video},
+	"3gp5": {MIME: "video/3gpp", Category: Video},
+	"crx ": {MIME: "image/x-canon-cr3", Category: Image},
+}
+
+// riffTypes maps the form types of RIFF files to their types.
+var riffTypes = map[string]Type{
+	"WAVE": {MIME: "audio/wav", Category: Audio},
+	"AVI ": {MIME: "video/x-msvideo", Category: Video},
+	"WEBP": {MIME: "image/webp", Category: Image},
+}
+
+func matchSignature(header []byte) (signature, bool) {
+	if len(header) >= 12 && string(header[4:8]) == "ftyp" { //nolint:gomnd
+		t, ok := ftypBrands[string(header[8:12])]
+		if !ok {
+			t = Type{MIME: "video/mp4", Category: Video}
+		}
+		return signature{Type: t}, true
+	}
+
+	if len(header) >= 12 && string(header[0:4]) == "RIFF" { //nolint:gomnd
+		if t, ok := riffTypes[string(header[8:12])]; ok {
+			return signature{Type: t}, true
+		}
+	}
+
+	if mp3Frame(header) {
+		return signature{Type: Type{MIME: "audio/mpeg", Category: Audio}}, true
+	}
+
+	for _, s := range signatures {
+		end := s.offset + len(s.magic)
+		if len(header) >= end && bytes.Equal(header[s.offset:end], s.magic) {
+			return s, true
+		}
+	}
+
+	if xmlType, ok := matchXML(header); ok {
+		return signature{Type: xmlType}, true
+	}
+
+	return signature{}, false
+}
+
+// mp3Frame checks for an MPEG audio layer III frame header without
+// ID3 tag.
+func mp3Frame(header []byte) bool {
+	return len(header) >= 3 && header[0] == 0xFF && header[1]&0xE6 == 0xE2 && header[2]&0xF0 != 0xF0
+}
+
+// matchXML recognizes the XML based formats that aren't text.
+func matchXML(header []byte) (Type, bool) {
+	trimmed := bytes.TrimLeft(header, "\xEF\xBB\xBF \t\r\n")
+	if !bytes.HasPrefix(trimmed, []byte("<?xml")) && !bytes.HasPrefix(trimmed, []byte("<svg")) {
+		return Type{}, false
+	}
+
+	if bytes.Contains(trimmed, []byte("<svg")) {
+		return Type{MIME: "image/svg+xml", Category: Image}, true
+	}
+
+	return Type{}, false
+}
@@ -1,6 +1,7 @@
 package fileutils
 
 import (
+	"context"
 	"os"
 	"path"
 
@@ -9,6 +10,12 @@ import (
 
 // Copy copies a file or folder from one place to another.
 func Copy(fs afero.Fs, src, dst string) error {
+	return CopyContext(context.Background(), fs, src, dst, nil)
+}
+
+// CopyContext is like Copy but stops once ctx is canceled and
+// reports the copied bytes and files to progress, which may be nil.
+func CopyContext(ctx context.Context, fs afero.Fs, src, dst string, progress Progress) error {
 	if src = path.Clean("/" + src); src == "" {
 		return os.ErrNotExist
 	}
@@ -31,9 +38,10 @@ func Copy(fs afero.Fs, src, dst string) error {
 		return err
 	}
 
+	progress = orNop(progress)
 	if info.IsDir() {
-		return CopyDir(fs, src, dst)
+		return copyDir(ctx, fs, src, dst, progress)
 	}
 
-	return CopyFile(fs, src, dst)
+	return copyFile(ctx, fs, src, dst, progress)
 }
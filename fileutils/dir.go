@@ -1,7 +1,9 @@
 package fileutils
 
 import (
+	"context"
 	"errors"
+	"os"
 
 	"github.com/spf13/afero"
 )
@@ -10,6 +12,10 @@ import (
 // of its sub-directories. It doesn't stop if it finds an error
 // during the copy. Returns an error if any.
 func CopyDir(fs afero.Fs, source, dest string) error {
+	return copyDir(context.Background(), fs, source, dest, nopProgress{})
+}
+
+func copyDir(ctx context.Context, fs afero.Fs, source, dest string, progress Progress) error {
 	// Get properties of source.
 	srcinfo, err := fs.Stat(source)
 	if err != nil {
@@ -31,18 +37,22 @@ func CopyDir(fs afero.Fs, source, dest string) error {
 	var errs []error
 
 	for _, obj := range obs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		fsource := source + "/" + obj.Name()
 		fdest := dest + "/" + obj.Name()
 
 		if obj.IsDir() {
 			// Create sub-directories, recursively.
-			err = CopyDir(fs, fsource, fdest)
+			err = copyDir(ctx, fs, fsource, fdest, progress)
 			if err != nil {
 				errs = append(errs, err)
 			}
 		} else {
 			// Perform the file copy.
-			err = CopyFile(fs, fsource, fdest)
+			err = copyFile(ctx, fs, fsource, fdest, progress)
 			if err != nil {
 				errs = append(errs, err)
 			}
@@ -60,3 +70,23 @@ func CopyDir(fs afero.Fs, source, dest string) error {
 
 	return nil
 }
+
+// Count returns the total size and the number of regular files under
+// name. Symbolic links are not followed.
+func Count(ctx context.Context, fs afero.Fs, name string) (bytes, files int64, err error) {
+	err = afero.Walk(fs, name, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil { //nolint:govet
+			return err
+		}
+		if info.Mode().IsRegular() {
+			bytes += info.Size()
+			files++
+		}
+		return nil
+	})
+
+	return bytes, files, err
+}
@@ -0,0 +1,315 @@
+package fileutils
+
+import (
+	"container/list"
+	"context"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// DirUsage is the space taken by a directory tree.
+type DirUsage struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Files int64  `json:"files"`
+	Dirs  int64  `json:"dirs"`
+}
+
+// FileUsage is the space taken by a file.
+type FileUsage struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// DiskUsage is the space taken by a directory tree along with its
+// largest subdirectories and files.
+type DiskUsage struct {
+	DirUsage
+	TopDirs  []DirUsage  `json:"topDirs"`
+	TopFiles []FileUsage `json:"topFiles"`
+	// Skipped is the number of directories that couldn't be read.
+	Skipped int64 `json:"skipped,omitempty"`
+}
+
+// DiskUsageOptions configure a disk usage scan.
+type DiskUsageOptions struct {
+	// Top is the number of largest subdirectories and files returned.
+	Top int
+	// Check, if set, leaves out the paths it returns false for.
+	Check func(name string) bool
+	// Progress, if set, receives the scanned bytes and files.
+	Progress Progress
+}
+
+// duEntry is an entry of a cached directory.
+type duEntry struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+type duDir struct {
+	key     string
+	modTime time.Time
+	entries []duEntry
+}
+
+// DiskUsageCache keeps the entries of the directories scanned by
+// DiskUsage, so that only the directories modified since the previous
+// scan are read again. A directory is also read again when one of its
+// files changed size or modification time, as files modified in place
+// leave the modification time of their directory untouched.
+type DiskUsageCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	lru     *list.List
+	dirs    map[string]*list.Element
+	entries int
+}
+
+// NewDiskUsageCache creates a cache holding at most maxEntries
+// directory entries overall.
+func NewDiskUsageCache(maxEntries int) *DiskUsageCache {
+	return &DiskUsageCache{
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		dirs:       map[string]*list.Element{},
+	}
+}
+
+func (c *DiskUsageCache) get(key string, modTime time.Time) ([]duEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.dirs[key]
+	if !ok {
+		return nil, false
+	}
+	dir := elem.Value.(*duDir)
+	if !dir.modTime.Equal(modTime) {
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+	return dir.entries, true
+}
+
+func (c *DiskUsageCache) put(key string, modTime time.Time, entries []duEntry) {
+	if c == nil || len(entries) > c.maxEntries {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.dirs[key]; ok {
+		c.remove(elem)
+	}
+	c.dirs[key] = c.lru.PushFront(&duDir{key: key, modTime: modTime, entries: entries})
+	c.entries += len(entries)
+
+	for c.entries > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *DiskUsageCache) remove(elem *list.Element) {
+	dir := c.lru.Remove(elem).(*duDir)
+	delete(c.dirs, dir.key)
+	c.entries -= len(dir.entries)
+}
+
+// DiskUsage computes the space taken by the directory tree at name.
+// Symbolic links are not followed and the directories that can't be
+// read are skipped. The scan stops once ctx is canceled. c may be nil,
+// in which case every directory is read.
+func (c *DiskUsageCache) DiskUsage(ctx context.Context, fs afero.Fs, name string,
+	opts DiskUsageOptions) (*DiskUsage, error) {
+	s := &duScan{
+		ctx:      ctx,
+		fs:       fs,
+		cache:    c,
+		opts:     opts,
+		progress: orNop(opts.Progress),
+		topDirs:  []DirUsage{},
+		topFiles: []FileUsage{},
+	}
+	if realPathFs, ok := fs.(interface {
+		RealPath(name string) (string, error)
+	}); ok {
+		s.realPath = realPathFs.RealPath
+	}
+
+	root, err := s.dir(name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiskUsage{
+		DirUsage: root,
+		TopDirs:  s.topDirs,
+		TopFiles: s.topFiles,
+		Skipped:  s.skipped,
+	}, nil
+}
+
+type duScan struct {
+	ctx      context.Context
+	fs       afero.Fs
+	cache    *DiskUsageCache
+	opts     DiskUsageOptions
+	progress Progress
+	realPath func(name string) (string, error)
+
+	topDirs  []DirUsage
+	topFiles []FileUsage
+	skipped  int64
+}
+
+func (s *duScan) dir(name string, root bool) (DirUsage, error) {
+	usage := DirUsage{Path: name}
+	if err := s.ctx.Err(); err != nil {
+		return usage, err
+	}
+
+	entries, err := s.entries(name)
+	if err != nil {
+		if root {
+			return usage, err
+		}
+		s.skipped++
+		return usage, nil
+	}
+
+	for _, entry := range entries {
+		child := path.Join(name, entry.name)
+		if s.opts.Check != nil && !s.opts.Check(child) {
+			continue
+		}
+
+		if !entry.isDir {
+			usage.Size += entry.size
+			usage.Files++
+			s.progress.AddBytes(entry.size)
+			s.progress.AddFiles(1)
+			s.offerFile(FileUsage{Path: child, Size: entry.size})
+			continue
+		}
+
+		sub, err := s.dir(child, false)
+		if err != nil {
+			return usage, err
+		}
+		usage.Size += sub.Size
+		usage.Files += sub.Files
+		usage.Dirs += sub.Dirs + 1
+		s.offerDir(sub)
+	}
+
+	return usage, nil
+}
+
+// entries returns the regular files and the directories of name.
+func (s *duScan) entries(name string) ([]duEntry, error) {
+	info, err := s.fs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &os.PathError{Op: "du", Path: name, Err: os.ErrInvalid}
+	}
+
+	var key string
+	if s.realPath != nil && s.cache != nil {
+		if key, err = s.realPath(name); err == nil {
+			if entries, ok := s.cache.get(key, info.ModTime()); ok && s.unchanged(name, entries) {
+				return entries, nil
+			}
+		}
+	}
+
+	dir, err := s.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := dir.Readdir(-1)
+	dir.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]duEntry, 0, len(infos))
+	for _, info := range infos {
+		switch {
+		case info.IsDir():
+			entries = append(entries, duEntry{name: info.Name(), isDir: true})
+		case info.Mode().IsRegular():
+			entries = append(entries, duEntry{name: info.Name(), size: info.Size(), modTime: info.ModTime()})
+		}
+	}
+
+	if key != "" {
+		s.cache.put(key, info.ModTime(), entries)
+	}
+	return entries, nil
+}
+
+// unchanged reports whether the files among the cached entries of the
+// directory name still have the same size and modification time.
+func (s *duScan) unchanged(name string, entries []duEntry) bool {
+	for _, entry := range entries {
+		if entry.isDir {
+			continue
+		}
+		info, err := lstat(s.fs, path.Join(name, entry.name))
+		if err != nil || info.Size() != entry.size || !info.ModTime().Equal(entry.modTime) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// offerDir and offerFile keep the largest items in descending order.
+func (s *duScan) offerDir(usage DirUsage) {
+	if s.opts.Top <= 0 {
+		return
+	}
+	i := sort.Search(len(s.topDirs), func(i int) bool { return s.topDirs[i].Size < usage.Size })
+	if i >= s.opts.Top {
+		return
+	}
+	s.topDirs = append(s.topDirs, DirUsage{})
+	copy(s.topDirs[i+1:], s.topDirs[i:])
+	s.topDirs[i] = usage
+	if len(s.topDirs) > s.opts.Top {
+		s.topDirs = s.topDirs[:s.opts.Top]
+	}
+}
+
+func (s *duScan) offerFile(usage FileUsage) {
+	if s.opts.Top <= 0 {
+		return
+	}
+	i := sort.Search(len(s.topFiles), func(i int) bool { return s.topFiles[i].Size < usage.Size })
+	if i >= s.opts.Top {
+		return
+	}
+	s.topFiles = append(s.topFiles, FileUsage{})
+	copy(s.topFiles[i+1:], s.topFiles[i:])
+	s.topFiles[i] = usage
+	if len(s.topFiles) > s.opts.Top {
+		s.topFiles = s.topFiles[:s.opts.Top]
+	}
+}
@@ -0,0 +1,72 @@
+package fileutils
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskUsage(t *testing.T) {
+	fs := afero.NewBasePathFs(afero.NewOsFs(), t.TempDir())
+	for name, size := range map[string]int{
+		"/a.bin":             100,
+		"/big/b.bin":         1000,
+		"/big/sub/c.bin":     500,
+		"/small/d.bin":       10,
+		"/secret/e.bin":      5000,
+		"/small/empty/.keep": 0,
+	} {
+		require.NoError(t, fs.MkdirAll(path.Dir(name), 0755))
+		require.NoError(t, afero.WriteFile(fs, name, make([]byte, size), 0644))
+	}
+
+	cache := NewDiskUsageCache(100)
+	opts := DiskUsageOptions{
+		Top:   2,
+		Check: func(name string) bool { return !strings.HasPrefix(name, "/secret") },
+	}
+
+	usage, err := cache.DiskUsage(context.Background(), fs, "/", opts)
+	require.NoError(t, err)
+	require.Equal(t, DirUsage{Path: "/", Size: 1610, Files: 5, Dirs: 4}, usage.DirUsage)
+	require.Equal(t, []DirUsage{
+		{Path: "/big", Size: 1500, Files: 2, Dirs: 1},
+		{Path: "/big/sub", Size: 500, Files: 1},
+	}, usage.TopDirs)
+	require.Equal(t, []FileUsage{{Path: "/big/b.bin", Size: 1000}, {Path: "/big/sub/c.bin", Size: 500}}, usage.TopFiles)
+	require.Len(t, cache.dirs, 5)
+
+	// a new file changes the modification time of its directory
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, afero.WriteFile(fs, "/small/f.bin", make([]byte, 90), 0644))
+
+	usage, err = cache.DiskUsage(context.Background(), fs, "/small", opts)
+	require.NoError(t, err)
+	require.Equal(t, DirUsage{Path: "/small", Size: 100, Files: 3, Dirs: 1}, usage.DirUsage)
+
+	// a file growing in place leaves the one of its directory untouched
+	time.Sleep(10 * time.Millisecond)
+	file, err := fs.OpenFile("/small/d.bin", os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = file.Write(make([]byte, 90))
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	usage, err = cache.DiskUsage(context.Background(), fs, "/small", opts)
+	require.NoError(t, err)
+	require.Equal(t, DirUsage{Path: "/small", Size: 190, Files: 3, Dirs: 1}, usage.DirUsage)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = cache.DiskUsage(ctx, fs, "/", opts)
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = cache.DiskUsage(context.Background(), fs, "/a.bin", opts)
+	require.Error(t, err)
+}
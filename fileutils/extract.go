@@ -0,0 +1,461 @@
+package fileutils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	kzip "github.com/klauspost/compress/zip"
+	"github.com/mholt/archiver/v3"
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/rules"
+)
+
+// ExtractOptions are the options when extracting an archive.
+type ExtractOptions struct {
+	// MaxSize is the maximum number of uncompressed bytes. Zero means no limit.
+	MaxSize int64
+	// MaxEntries is the maximum number of entries. Zero means no limit.
+	MaxEntries int
+	// Checker, if set, filters the destination paths. Denied entries are skipped.
+	Checker rules.Checker
+	// Progress, if set, receives the extracted bytes and files.
+	Progress Progress
+	// OnReplace, if set, is called with the path of every existing file
+	// before it is replaced, to keep a copy of it for instance.
+	OnReplace func(name string) error
+}
+
+// IsArchive reports whether name has the extension of an archive
+// that can be extracted.
+func IsArchive(name string) bool {
+	_, err := archiveReader(name)
+	return err == nil
+}
+
+func archiveReader(name string) (archiver.Reader, error) {
+	format, err := archiver.ByExtension(strings.ToLower(name))
+	if err != nil {
+		return nil, errors.ErrUnsupportedArchive
+	}
+
+	switch r := format.(type) {
+	case *archiver.Zip:
+		return r, nil
+	case *archiver.Tar, *archiver.TarGz, *archiver.TarBz2, *archiver.TarXz,
+		*archiver.TarZstd, *archiver.TarLz4, *archiver.TarSz:
+		return r.(archiver.Reader), nil
+	default:
+		return nil, errors.ErrUnsupportedArchive
+	}
+}
+
+// Extract unpacks the archive at src into the directory dst. Entries
+// that would land outside of dst are rejected, links are never created
+// and the sizes are enforced on the actual decompressed data. The files
+// are written next to their destination and only moved into place once
+// the whole archive is extracted. If the extraction fails, the files
+// created so far are removed and the replaced ones are put back.
+func Extract(ctx context.Context, fs afero.Fs, src, dst string, opts ExtractOptions) (err error) {
+	reader, err := archiveReader(src)
+	if err != nil {
+		return err
+	}
+
+	file, err := fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := reader.(*archiver.Zip); ok {
+		err = checkZip(file, info.Size(), opts)
+	} else {
+		err = checkTar(ctx, fs, src, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = reader.Open(file, info.Size()); err != nil {
+		return fmt.Errorf("%v: %w", err, errors.ErrUnsupportedArchive)
+	}
+	defer reader.Close()
+
+	e := &extractor{
+		fs:       fs,
+		dst:      path.Clean("/" + dst),
+		opts:     opts,
+		progress: orNop(opts.Progress),
+		temps:    map[string]string{},
+	}
+	defer func() {
+		if err != nil {
+			e.rollback()
+		}
+	}()
+
+	if err = e.mkdir(e.dst); err != nil {
+		return err
+	}
+
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		entry, readErr := reader.Read()
+		if readErr == io.EOF {
+			return e.commit()
+		}
+		if readErr != nil {
+			return readErr
+		}
+
+		err = e.extract(ctx, entry)
+		entry.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// checkZip rejects zip archives whose central directory already
+// declares too much data and reports the expected totals.
+func checkZip(r io.ReaderAt, size int64, opts ExtractOptions) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, errors.ErrUnsupportedArchive)
+	}
+
+	if opts.MaxEntries > 0 && len(zr.File) > opts.MaxEntries {
+		return errors.ErrArchiveLimit
+	}
+
+	var bytes, files int64
+	for _, f := range zr.File {
+		if f.Mode().IsRegular() {
+			bytes += int64(f.UncompressedSize64)
+			files++
+		}
+	}
+
+	if opts.MaxSize > 0 && bytes > opts.MaxSize {
+		return errors.ErrArchiveLimit
+	}
+
+	if totaler, ok := opts.Progress.(ProgressTotaler); ok {
+		totaler.SetTotal(bytes, files)
+	}
+
+	return nil
+}
+
+// checkTar reads the headers of the tar archive at src, which have no
+// central directory, to reject the archives declaring too much data and
+// report the expected totals before extracting anything.
+func checkTar(ctx context.Context, fs afero.Fs, src string, opts ExtractOptions) error {
+	reader, err := archiveReader(src)
+	if err != nil {
+		return err
+	}
+
+	file, err := fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err = reader.Open(file, 0); err != nil {
+		return fmt.Errorf("%v: %w", err, errors.ErrUnsupportedArchive)
+	}
+	defer reader.Close()
+
+	var bytes, files int64
+	entries := 0
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		entry, readErr := reader.Read()
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+		entry.Close()
+
+		entries++
+		if opts.MaxEntries > 0 && entries > opts.MaxEntries {
+			return errors.ErrArchiveLimit
+		}
+		if _, kind, _ := entryHeader(entry); kind == entryFile {
+			bytes += entry.Size()
+			files++
+		}
+		if opts.MaxSize > 0 && bytes > opts.MaxSize {
+			return errors.ErrArchiveLimit
+		}
+	}
+
+	if totaler, ok := opts.Progress.(ProgressTotaler); ok {
+		totaler.SetTotal(bytes, files)
+	}
+
+	return nil
+}
+
+type extractor struct {
+	fs       afero.Fs
+	dst      string
+	opts     ExtractOptions
+	progress Progress
+
+	entries int
+	written int64
+	created []string
+	// temps are the temporary files of the extracted files, by their
+	// destination, which are listed in order in extracted.
+	temps     map[string]string
+	extracted []string
+	// replaced are the files replaced by the extraction, moved aside
+	// until it is done.
+	replaced []replacedFile
+}
+
+type replacedFile struct {
+	target string
+	backup string
+}
+
+func (e *extractor) extract(ctx context.Context, entry archiver.File) error {
+	e.entries++
+	if e.opts.MaxEntries > 0 && e.entries > e.opts.MaxEntries {
+		return errors.ErrArchiveLimit
+	}
+
+	name, kind, err := entryHeader(entry)
+	if err != nil {
+		return err
+	}
+
+	target, err := e.target(name)
+	if err != nil {
+		return err
+	}
+	if target == e.dst {
+		return nil
+	}
+
+	if e.opts.Checker != nil && !e.opts.Checker.Check(target) {
+		return nil
+	}
+
+	switch kind {
+	case entryDir:
+		return e.mkdir(target)
+	case entryFile:
+		return e.writeFile(ctx, target, entry)
+	default:
+		log.Printf("Skipping link or special file %s in archive", name)
+		return nil
+	}
+}
+
+// target resolves the destination of an entry and makes sure it
+// doesn't escape dst, neither lexically nor through existing links.
+func (e *extractor) target(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") {
+		return "", errors.ErrInvalidArchiveEntry
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if elem == ".." {
+			return "", errors.ErrInvalidArchiveEntry
+		}
+	}
+
+	target := path.Join(e.dst, name)
+	if target != e.dst && !strings.HasPrefix(target, e.dst+"/") {
+		return "", errors.ErrInvalidArchiveEntry
+	}
+
+	// refuse to write through links that already exist in the destination
+	for p := path.Dir(target); p != e.dst && strings.HasPrefix(p, e.dst); p = path.Dir(p) {
+		info, err := lstat(e.fs, p)
+		if err != nil {
+			continue
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", errors.ErrInvalidArchiveEntry
+		}
+	}
+
+	return target, nil
+}
+
+func (e *extractor) mkdir(dir string) error {
+	if _, err := e.fs.Stat(dir); err == nil {
+		return nil
+	}
+
+	if err := e.mkdir(path.Dir(dir)); err != nil {
+		return err
+	}
+
+	if err := e.fs.Mkdir(dir, 0775); err != nil && !os.IsExist(err) { //nolint:gomnd
+		return err
+	}
+
+	e.created = append(e.created, dir)
+	return nil
+}
+
+func (e *extractor) writeFile(ctx context.Context, target string, in io.Reader) error {
+	info, err := lstat(e.fs, target)
+	if err == nil && !info.Mode().IsRegular() {
+		return errors.ErrInvalidArchiveEntry
+	}
+
+	if err := e.mkdir(path.Dir(target)); err != nil {
+		return err
+	}
+
+	// an entry found twice in the archive replaces the first one
+	temp, ok := e.temps[target]
+	if !ok {
+		temp = path.Join(path.Dir(target), fmt.Sprintf(".%s.extract-%d", path.Base(target), len(e.temps)))
+		e.temps[target] = temp
+		e.extracted = append(e.extracted, target)
+	}
+
+	out, err := e.fs.OpenFile(temp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0664) //nolint:gomnd
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if e.opts.MaxSize > 0 {
+		// read one byte past the budget to detect archives that lie
+		// about their uncompressed size
+		in = io.LimitReader(in, e.opts.MaxSize-e.written+1)
+	}
+
+	n, err := io.Copy(NewProgressWriter(ctx, out, e.progress), in)
+	e.written += n
+	if err != nil {
+		return err
+	}
+	if e.opts.MaxSize > 0 && e.written > e.opts.MaxSize {
+		return errors.ErrArchiveLimit
+	}
+
+	e.progress.AddFiles(1)
+	return nil
+}
+
+// commit moves the extracted files from their temporary files into
+// place. The files they replace are moved aside, and only removed once
+// all of them are in place.
+func (e *extractor) commit() error {
+	for i, target := range e.extracted {
+		info, err := lstat(e.fs, target)
+		if err == nil && !info.Mode().IsRegular() {
+			return errors.ErrInvalidArchiveEntry
+		}
+		existed := err == nil
+
+		if existed {
+			if e.opts.OnReplace != nil {
+				if err := e.opts.OnReplace(target); err != nil {
+					return err
+				}
+			}
+
+			backup := path.Join(path.Dir(target), fmt.Sprintf(".%s.replaced-%d", path.Base(target), i))
+			if err := e.fs.Rename(target, backup); err != nil {
+				return err
+			}
+			e.replaced = append(e.replaced, replacedFile{target: target, backup: backup})
+		}
+
+		if err := e.fs.Rename(e.temps[target], target); err != nil {
+			return err
+		}
+		delete(e.temps, target)
+		if !existed {
+			e.created = append(e.created, target)
+		}
+	}
+
+	for _, file := range e.replaced {
+		if err := e.fs.Remove(file.backup); err != nil {
+			log.Printf("failed to remove %s: %v", file.backup, err)
+		}
+	}
+	e.replaced = nil
+
+	return nil
+}
+
+// rollback removes everything created by the extraction and the
+// temporary files, and puts the replaced files back.
+func (e *extractor) rollback() {
+	for _, temp := range e.temps {
+		_ = e.fs.Remove(temp)
+	}
+	for _, file := range e.replaced {
+		_ = e.fs.Rename(file.backup, file.target)
+	}
+	for i := len(e.created) - 1; i >= 0; i-- {
+		_ = e.fs.Remove(e.created[i])
+	}
+}
+
+type entryKind int
+
+const (
+	entryFile entryKind = iota
+	entryDir
+	entryOther
+)
+
+func entryHeader(entry archiver.File) (string, entryKind, error) {
+	switch h := entry.Header.(type) {
+	case kzip.FileHeader:
+		switch mode := h.Mode(); {
+		case mode.IsDir() || strings.HasSuffix(h.Name, "/"):
+			return h.Name, entryDir, nil
+		case mode.IsRegular():
+			return h.Name, entryFile, nil
+		default:
+			return h.Name, entryOther, nil
+		}
+	case *tar.Header:
+		switch h.Typeflag {
+		case tar.TypeDir:
+			return h.Name, entryDir, nil
+		case tar.TypeReg, tar.TypeRegA: //nolint:staticcheck
+			return h.Name, entryFile, nil
+		default:
+			return h.Name, entryOther, nil
+		}
+	default:
+		return "", entryOther, errors.ErrUnsupportedArchive
+	}
+}
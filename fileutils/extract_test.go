@@ -0,0 +1,210 @@
+package fileutils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+)
+
+func writeZip(t *testing.T, fs afero.Fs, name string, entries map[string]string) {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for entry, content := range entries {
+		w, err := zw.Create(entry)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, afero.WriteFile(fs, name, buf.Bytes(), 0644))
+}
+
+func writeTarGz(t *testing.T, fs afero.Fs, name string, entries map[string]string) {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for entry, content := range entries {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     entry,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	require.NoError(t, afero.WriteFile(fs, name, buf.Bytes(), 0644))
+}
+
+type totals struct {
+	bytes, files, totalBytes, totalFiles int64
+}
+
+func (t *totals) AddBytes(n int64)            { t.bytes += n }
+func (t *totals) AddFiles(n int64)            { t.files += n }
+func (t *totals) SetTotal(bytes, files int64) { t.totalBytes, t.totalFiles = bytes, files }
+
+func TestExtract(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := map[string]struct {
+		entries map[string]string
+		opts    ExtractOptions
+		wantErr error
+		want    map[string]string
+	}{
+		"regular archive": {
+			entries: map[string]string{"a.txt": "a", "dir/b.txt": "bb", "dir/": ""},
+			want:    map[string]string{"/out/a.txt": "a", "/out/dir/b.txt": "bb"},
+		},
+		"path traversal": {
+			entries: map[string]string{"../evil.txt": "x"},
+			wantErr: errors.ErrInvalidArchiveEntry,
+		},
+		"absolute path": {
+			entries: map[string]string{"/evil.txt": "x"},
+			wantErr: errors.ErrInvalidArchiveEntry,
+		},
+		"too large": {
+			entries: map[string]string{"a.txt": "0123456789"},
+			opts:    ExtractOptions{MaxSize: 5},
+			wantErr: errors.ErrArchiveLimit,
+		},
+		"too many entries": {
+			entries: map[string]string{"a.txt": "a", "b.txt": "b"},
+			opts:    ExtractOptions{MaxEntries: 1},
+			wantErr: errors.ErrArchiveLimit,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			writeZip(t, fs, "/archive.zip", tc.entries)
+
+			err := Extract(ctx, fs, "/archive.zip", "/out", tc.opts)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				exists, _ := afero.Exists(fs, "/out")
+				require.False(t, exists, "failed extraction must be rolled back")
+				return
+			}
+
+			require.NoError(t, err)
+			for p, content := range tc.want {
+				got, err := afero.ReadFile(fs, p)
+				require.NoError(t, err)
+				require.Equal(t, content, string(got))
+			}
+		})
+	}
+}
+
+func TestExtract_Replace(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := map[string]struct {
+		entries      map[string]string
+		opts         ExtractOptions
+		failReplace  bool
+		wantErr      error
+		want         map[string]string
+		wantReplaced []string
+	}{
+		"replaced files": {
+			entries:      map[string]string{"a.txt": "new a", "b.txt": "new b"},
+			want:         map[string]string{"/out/a.txt": "new a", "/out/b.txt": "new b", "/out/c.txt": "old c"},
+			wantReplaced: []string{"/out/a.txt"},
+		},
+		"failed extraction": {
+			entries: map[string]string{"a.txt": "new a", "b.txt": "0123456789"},
+			opts:    ExtractOptions{MaxSize: 8},
+			wantErr: errors.ErrArchiveLimit,
+			want:    map[string]string{"/out/a.txt": "old a", "/out/c.txt": "old c"},
+		},
+		"failed replacement": {
+			entries:     map[string]string{"a.txt": "new a", "b.txt": "new b", "c.txt": "new c"},
+			failReplace: true,
+			wantErr:     errors.ErrInvalidRequestParams,
+			want:        map[string]string{"/out/a.txt": "old a", "/out/c.txt": "old c"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			writeZip(t, fs, "/archive.zip", tc.entries)
+			old := map[string]string{"/out/a.txt": "old a", "/out/c.txt": "old c"}
+			for p, content := range old {
+				require.NoError(t, afero.WriteFile(fs, p, []byte(content), 0644))
+			}
+
+			replaced := []string{}
+			tc.opts.OnReplace = func(name string) error {
+				content, err := afero.ReadFile(fs, name)
+				require.NoError(t, err)
+				require.Equal(t, old[name], string(content), "files must be replaced after OnReplace")
+				replaced = append(replaced, name)
+				if tc.failReplace && len(replaced) == 2 {
+					return errors.ErrInvalidRequestParams
+				}
+				return nil
+			}
+
+			err := Extract(ctx, fs, "/archive.zip", "/out", tc.opts)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.wantReplaced, replaced)
+			}
+
+			infos, err := afero.ReadDir(fs, "/out")
+			require.NoError(t, err)
+			require.Len(t, infos, len(tc.want), "temporary files must be removed")
+			for p, content := range tc.want {
+				got, err := afero.ReadFile(fs, p)
+				require.NoError(t, err)
+				require.Equal(t, content, string(got))
+			}
+		})
+	}
+}
+
+func TestExtract_Totals(t *testing.T) {
+	ctx := context.Background()
+	entries := map[string]string{"a.txt": "0123456789", "dir/b.txt": "01234567890123456789"}
+
+	testCases := map[string]struct {
+		archive string
+		write   func(t *testing.T, fs afero.Fs, name string, entries map[string]string)
+	}{
+		"zip":    {archive: "/archive.zip", write: writeZip},
+		"tar.gz": {archive: "/archive.tar.gz", write: writeTarGz},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			tc.write(t, fs, tc.archive, entries)
+
+			progress := &totals{}
+			require.NoError(t, Extract(ctx, fs, tc.archive, "/out", ExtractOptions{Progress: progress}))
+			require.Equal(t, &totals{bytes: 30, files: 2, totalBytes: 30, totalFiles: 2}, progress)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package fileutils
 
 import (
+	"context"
 	"io"
 	"os"
 	"path"
@@ -31,6 +32,10 @@ func MoveFile(fs afero.Fs, src, dst string) error {
 // CopyFile copies a file from source to dest and returns
 // an error if any.
 func CopyFile(fs afero.Fs, source, dest string) error {
+	return copyFile(context.Background(), fs, source, dest, nopProgress{})
+}
+
+func copyFile(ctx context.Context, fs afero.Fs, source, dest string, progress Progress) error {
 	// Open the source file.
 	src, err := fs.Open(source)
 	if err != nil {
@@ -53,10 +58,11 @@ func CopyFile(fs afero.Fs, source, dest string) error {
 	defer dst.Close()
 
 	// Copy the contents of the file.
-	_, err = io.Copy(dst, src)
+	_, err = io.Copy(NewProgressWriter(ctx, dst, progress), src)
 	if err != nil {
 		return err
 	}
+	progress.AddFiles(1)
 
 	// Copy the mode
 	info, err := fs.Stat(source)
@@ -0,0 +1,77 @@
+package fileutils
+
+import (
+	"context"
+	"io"
+)
+
+// Progress receives updates about the work done by long
+// running file operations.
+type Progress interface {
+	AddBytes(n int64)
+	AddFiles(n int64)
+}
+
+// ProgressTotaler is implemented by the progress receivers that
+// can make use of the expected amount of work.
+type ProgressTotaler interface {
+	SetTotal(bytes, files int64)
+}
+
+type nopProgress struct{}
+
+func (nopProgress) AddBytes(int64) {}
+func (nopProgress) AddFiles(int64) {}
+
+func orNop(progress Progress) Progress {
+	if progress == nil {
+		return nopProgress{}
+	}
+	return progress
+}
+
+// ProgressWriter wraps an io.Writer reporting the written bytes to
+// a Progress. Writes fail once ctx is canceled.
+type ProgressWriter struct {
+	ctx      context.Context
+	w        io.Writer
+	progress Progress
+}
+
+// NewProgressWriter creates a ProgressWriter.
+func NewProgressWriter(ctx context.Context, w io.Writer, progress Progress) *ProgressWriter {
+	return &ProgressWriter{ctx: ctx, w: w, progress: orNop(progress)}
+}
+
+func (p *ProgressWriter) Write(b []byte) (int, error) {
+	if err := p.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := p.w.Write(b)
+	p.progress.AddBytes(int64(n))
+	return n, err
+}
+
+// ProgressReader wraps an io.Reader reporting the read bytes to
+// a Progress. Reads fail once ctx is canceled.
+type ProgressReader struct {
+	ctx      context.Context
+	r        io.Reader
+	progress Progress
+}
+
+// NewProgressReader creates a ProgressReader.
+func NewProgressReader(ctx context.Context, r io.Reader, progress Progress) *ProgressReader {
+	return &ProgressReader{ctx: ctx, r: r, progress: orNop(progress)}
+}
+
+func (p *ProgressReader) Read(b []byte) (int, error) {
+	if err := p.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := p.r.Read(b)
+	p.progress.AddBytes(int64(n))
+	return n, err
+}
@@ -0,0 +1,67 @@
+package fileutils
+
+import (
+	"context"
+	"os"
+	"path"
+
+	"github.com/spf13/afero"
+)
+
+// RemoveAllContext removes path and any children it contains, like
+// afero.Fs.RemoveAll, but stops once ctx is canceled and reports the
+// removed bytes and files to progress, which may be nil.
+func RemoveAllContext(ctx context.Context, fs afero.Fs, name string, progress Progress) error {
+	return removeAll(ctx, fs, name, orNop(progress))
+}
+
+func removeAll(ctx context.Context, fs afero.Fs, name string, progress Progress) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	info, err := lstat(fs, name)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		dir, err := fs.Open(name) //nolint:govet
+		if err != nil {
+			return err
+		}
+		names, err := dir.Readdirnames(-1)
+		dir.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, child := range names {
+			if err := removeAll(ctx, fs, path.Join(name, child), progress); err != nil { //nolint:govet
+				return err
+			}
+		}
+	}
+
+	if err := fs.Remove(name); err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		progress.AddBytes(info.Size())
+		progress.AddFiles(1)
+	}
+
+	return nil
+}
+
+// lstat stats name without following symbolic links when the
+// filesystem allows it.
+func lstat(fs afero.Fs, name string) (os.FileInfo, error) {
+	if lstater, ok := fs.(afero.Lstater); ok {
+		info, _, err := lstater.LstatIfPossible(name)
+		return info, err
+	}
+
+	return fs.Stat(name)
+}
@@ -0,0 +1,402 @@
+package fileutils
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+)
+
+const (
+	// textSniffLen is the number of bytes used to detect the encoding.
+	textSniffLen = 4096
+	// tailChunkLen is the size of the blocks read backwards by TailOffset.
+	tailChunkLen = 64 << 10
+)
+
+// TextEncoding describes how the lines of a text file are encoded.
+type TextEncoding struct {
+	// Name is the name of the encoding, as understood by browsers.
+	Name string
+	// BOM is the length of the byte order mark the file starts with.
+	BOM int64
+
+	encoding encoding.Encoding
+	// unit is the size of the code units, 2 for UTF-16.
+	unit      int64
+	bigEndian bool
+}
+
+// DetectTextEncoding guesses the encoding of a text file from its
+// byte order mark or its first bytes. Files that aren't valid UTF-8
+// are assumed to be Windows-1252, which can decode any byte.
+func DetectTextEncoding(r io.ReaderAt) (*TextEncoding, error) {
+	buf := make([]byte, textSniffLen)
+	n, err := r.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	buf = buf[:n]
+
+	switch {
+	case bytes.HasPrefix(buf, []byte{0xEF, 0xBB, 0xBF}):
+		return &TextEncoding{Name: "utf-8", BOM: 3, encoding: unicode.UTF8, unit: 1}, nil //nolint:gomnd
+	case bytes.HasPrefix(buf, []byte{0xFF, 0xFE}):
+		return utf16Encoding(false, 2), nil //nolint:gomnd
+	case bytes.HasPrefix(buf, []byte{0xFE, 0xFF}):
+		return utf16Encoding(true, 2), nil //nolint:gomnd
+	}
+
+	if validUTF8Prefix(buf) {
+		return &TextEncoding{Name: "utf-8", encoding: unicode.UTF8, unit: 1}, nil
+	}
+
+	// UTF-16 without a byte order mark: mostly ASCII text has a zero
+	// in the high byte of nearly every code unit.
+	var evenZeros, oddZeros int
+	for i, b := range buf {
+		if b == 0 {
+			if i%2 == 0 {
+				evenZeros++
+			} else {
+				oddZeros++
+			}
+		}
+	}
+	switch pairs := len(buf) / 2; {
+	case pairs > 0 && oddZeros*2 > pairs && evenZeros*10 < pairs:
+		return utf16Encoding(false, 0), nil
+	case pairs > 0 && evenZeros*2 > pairs && oddZeros*10 < pairs:
+		return utf16Encoding(true, 0), nil
+	}
+
+	return &TextEncoding{Name: "windows-1252", encoding: charmap.Windows1252, unit: 1}, nil
+}
+
+// Decode converts the content of a whole file to UTF-8, without the
+// byte order mark.
+func (e *TextEncoding) Decode(content []byte) ([]byte, error) {
+	if int64(len(content)) >= e.BOM {
+		content = content[e.BOM:]
+	}
+	return e.encoding.NewDecoder().Bytes(content)
+}
+
+// TextEncodingByName returns the encoding with the given name. The
+// byte order mark of the file, if any, must be passed in bom.
+func TextEncodingByName(name string, bom int64) (*TextEncoding, error) {
+	switch strings.ToLower(name) {
+	case "utf-16le":
+		return utf16Encoding(false, bom), nil
+	case "utf-16be":
+		return utf16Encoding(true, bom), nil
+	}
+
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, errors.ErrInvalidOption
+	}
+	canonical, err := htmlindex.Name(enc)
+	if err != nil {
+		return nil, errors.ErrInvalidOption
+	}
+	if canonical == "utf-16le" || canonical == "utf-16be" {
+		return utf16Encoding(canonical == "utf-16be", bom), nil
+	}
+
+	return &TextEncoding{Name: canonical, BOM: bom, encoding: enc, unit: 1}, nil
+}
+
+func utf16Encoding(bigEndian bool, bom int64) *TextEncoding {
+	if bigEndian {
+		return &TextEncoding{
+			Name:      "utf-16be",
+			BOM:       bom,
+			encoding:  unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+			unit:      2, //nolint:gomnd
+			bigEndian: true,
+		}
+	}
+
+	return &TextEncoding{
+		Name:     "utf-16le",
+		BOM:      bom,
+		encoding: unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+		unit:     2, //nolint:gomnd
+	}
+}
+
+// validUTF8Prefix is like utf8.Valid but accepts a rune cut at the end.
+func validUTF8Prefix(b []byte) bool {
+	for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+		if utf8.Valid(b) {
+			return true
+		}
+		b = b[:len(b)-1]
+	}
+	return utf8.Valid(b)
+}
+
+// TextRange is a range of lines of a text file.
+type TextRange struct {
+	Lines []string `json:"lines"`
+	// From is the number of the first line, starting at 1, if known.
+	From int64 `json:"from,omitempty"`
+	// Offset is the position of the first line in the file.
+	Offset int64 `json:"offset"`
+	// NextOffset is the position of the line following the range.
+	NextOffset int64  `json:"nextOffset"`
+	Size       int64  `json:"size"`
+	Encoding   string `json:"encoding"`
+	EOF        bool   `json:"eof"`
+}
+
+// TextOptions limit the lines returned by ReadLines.
+type TextOptions struct {
+	// Skip is the number of lines to skip before the range.
+	Skip int64
+	// MaxLines is the maximum number of lines of the range.
+	MaxLines int
+	// MaxBytes is the maximum size of the lines of the range. At least
+	// one line is always returned.
+	MaxBytes int
+	// MaxLineBytes is the length after which lines are cut.
+	MaxLineBytes int
+	// Partial makes a last line without terminator part of the range.
+	// Otherwise it is left for later, as it may still be being written.
+	Partial bool
+}
+
+// ReadLines reads the lines of r, which is size bytes long, starting
+// at offset, which must be the beginning of a line.
+func (e *TextEncoding) ReadLines(r io.ReaderAt, size, offset int64, opts TextOptions) (*TextRange, error) {
+	if offset < e.BOM {
+		offset = e.BOM
+	}
+	if offset > size {
+		offset = size
+	}
+
+	br := bufio.NewReader(io.NewSectionReader(r, offset, size-offset))
+	pos := offset
+
+	for i := int64(0); i < opts.Skip; i++ {
+		_, n, terminated, err := e.readLine(br, 0)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if !terminated {
+			if opts.Partial {
+				pos += n
+			}
+			break
+		}
+		pos += n
+	}
+
+	rng := &TextRange{
+		Lines:    []string{},
+		Offset:   pos,
+		Size:     size,
+		Encoding: e.Name,
+	}
+
+	var total int
+	for len(rng.Lines) < opts.MaxLines && (len(rng.Lines) == 0 || total < opts.MaxBytes) {
+		line, n, terminated, err := e.readLine(br, opts.MaxLineBytes)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if n == 0 || (!terminated && !opts.Partial) {
+			break
+		}
+
+		rng.Lines = append(rng.Lines, e.decode(line))
+		pos += n
+		total += len(line)
+
+		if !terminated {
+			break
+		}
+	}
+
+	rng.EOF = pos == size
+	rng.NextOffset = pos
+	return rng, nil
+}
+
+// readLine reads a line including its terminator. The bytes past maxLen
+// are read but not returned. n is the number of bytes read.
+func (e *TextEncoding) readLine(br *bufio.Reader, maxLen int) (line []byte, n int64, terminated bool, err error) {
+	var prev byte
+	for {
+		chunk, readErr := br.ReadSlice('\n')
+		if room := maxLen - len(line); room > 0 {
+			if room > len(chunk) {
+				room = len(chunk)
+			}
+			line = append(line, chunk[:room]...)
+		}
+		if len(chunk) > 1 {
+			prev = chunk[len(chunk)-2]
+		}
+		n += int64(len(chunk))
+
+		switch {
+		case readErr == bufio.ErrBufferFull:
+			if len(chunk) > 0 {
+				prev = chunk[len(chunk)-1]
+			}
+			continue
+		case readErr != nil:
+			return line, n, false, readErr
+		case e.unit == 1:
+			return line, n, true, nil
+		case e.bigEndian:
+			// the terminator is 0x00 0x0A on a code unit boundary
+			if (n-1)%2 == 1 && prev == 0 {
+				return line, n, true, nil
+			}
+		case (n-1)%2 == 0:
+			// the terminator is 0x0A 0x00 on a code unit boundary
+			b, readErr := br.ReadByte()
+			if readErr != nil {
+				return line, n, false, readErr
+			}
+			n++
+			if len(line) < maxLen {
+				line = append(line, b)
+			}
+			if b == 0 {
+				return line, n, true, nil
+			}
+		}
+
+		if len(chunk) > 0 {
+			prev = chunk[len(chunk)-1]
+		}
+	}
+}
+
+// terminators returns the line feed and carriage return of e.
+func (e *TextEncoding) terminators() (lf, cr []byte) {
+	switch {
+	case e.unit == 1:
+		return []byte{'\n'}, []byte{'\r'}
+	case e.bigEndian:
+		return []byte{0, '\n'}, []byte{0, '\r'}
+	default:
+		return []byte{'\n', 0}, []byte{'\r', 0}
+	}
+}
+
+func (e *TextEncoding) decode(line []byte) string {
+	lf, cr := e.terminators()
+	if bytes.HasSuffix(line, lf) {
+		line = bytes.TrimSuffix(line[:len(line)-len(lf)], cr)
+	}
+
+	decoded, err := e.encoding.NewDecoder().Bytes(line)
+	if err != nil {
+		return string(line)
+	}
+	return string(decoded)
+}
+
+// TailOffset returns the position of the first of the last n lines
+// of r, which is size bytes long.
+func (e *TextEncoding) TailOffset(r io.ReaderAt, size int64, n int) (int64, error) {
+	if n <= 0 {
+		return size, nil
+	}
+
+	lf, _ := e.terminators()
+	end := size
+
+	// a terminator at the very end doesn't start another line
+	if size-int64(len(lf)) >= e.BOM {
+		last := make([]byte, len(lf))
+		if _, err := r.ReadAt(last, size-int64(len(lf))); err != nil && err != io.EOF {
+			return 0, err
+		}
+		if bytes.Equal(last, lf) {
+			end = size - int64(len(lf))
+		}
+	}
+
+	buf := make([]byte, tailChunkLen+1)
+	found := 0
+	for end > e.BOM {
+		start := end - tailChunkLen
+		if start < e.BOM {
+			start = e.BOM
+		}
+		// one more byte to see the second half of a UTF-16LE terminator
+		readEnd := end + 1
+		if readEnd > size {
+			readEnd = size
+		}
+
+		b := buf[:readEnd-start]
+		if _, err := r.ReadAt(b, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		for i := end - start - 1; i >= 0; i-- {
+			if b[i] != '\n' {
+				continue
+			}
+
+			p := start + i
+			next, ok := e.lineStartAfter(r, b, i, p)
+			if !ok {
+				continue
+			}
+
+			found++
+			if found == n {
+				return next, nil
+			}
+		}
+
+		end = start
+	}
+
+	return e.BOM, nil
+}
+
+// lineStartAfter checks whether the 0x0A byte at b[i], which is at
+// position p of the file, is part of a terminator and returns the
+// position of the line that follows it.
+func (e *TextEncoding) lineStartAfter(r io.ReaderAt, b []byte, i, p int64) (int64, bool) {
+	switch {
+	case e.unit == 1:
+		return p + 1, true
+	case e.bigEndian:
+		if (p-e.BOM)%2 != 1 {
+			return 0, false
+		}
+		prev := byte(1)
+		if i > 0 {
+			prev = b[i-1]
+		} else {
+			one := []byte{1}
+			_, _ = r.ReadAt(one, p-1)
+			prev = one[0]
+		}
+		return p + 1, prev == 0
+	default:
+		if (p-e.BOM)%2 != 0 || i+1 >= int64(len(b)) {
+			return 0, false
+		}
+		return p + 2, b[i+1] == 0 //nolint:gomnd
+	}
+}
@@ -0,0 +1,114 @@
+package fileutils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func utf16LE(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(s))
+	require.NoError(t, err)
+	return b
+}
+
+func TestDetectTextEncoding(t *testing.T) {
+	testCases := map[string]struct {
+		content []byte
+		want    string
+		bom     int64
+	}{
+		"utf-8":        {content: []byte("héllo\n"), want: "utf-8"},
+		"utf-8 bom":    {content: []byte("\xEF\xBB\xBFhello\n"), want: "utf-8", bom: 3},
+		"utf-16le bom": {content: utf16LE(t, "hello\n"), want: "utf-16le", bom: 2},
+		"latin-1":      {content: []byte("h\xe9llo\n"), want: "windows-1252"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			enc, err := DetectTextEncoding(bytes.NewReader(tc.content))
+			require.NoError(t, err)
+			require.Equal(t, tc.want, enc.Name)
+			require.Equal(t, tc.bom, enc.BOM)
+		})
+	}
+}
+
+func TestReadLines(t *testing.T) {
+	opts := TextOptions{MaxLines: 100, MaxBytes: 1 << 20, MaxLineBytes: 1 << 10}
+
+	testCases := map[string]struct {
+		content []byte
+		skip    int64
+		tail    int
+		partial bool
+		want    []string
+		wantEOF bool
+	}{
+		"range": {
+			content: []byte("one\r\ntwo\nthree\nfour\n"),
+			skip:    1,
+			want:    []string{"two", "three", "four"},
+			wantEOF: true,
+		},
+		"unterminated last line is left for later": {
+			content: []byte("one\ntwo"),
+			want:    []string{"one"},
+		},
+		"unterminated last line": {
+			content: []byte("one\ntwo"),
+			partial: true,
+			want:    []string{"one", "two"},
+			wantEOF: true,
+		},
+		"tail": {
+			content: []byte("one\ntwo\nthree\nfour\n"),
+			tail:    2,
+			partial: true,
+			want:    []string{"three", "four"},
+			wantEOF: true,
+		},
+		"tail longer than the file": {
+			content: []byte("one\ntwo"),
+			tail:    5,
+			partial: true,
+			want:    []string{"one", "two"},
+			wantEOF: true,
+		},
+		"tail utf-16le": {
+			content: utf16LE(t, "one\nt਀o\nthree\n"),
+			tail:    2,
+			partial: true,
+			want:    []string{"t਀o", "three"},
+			wantEOF: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := bytes.NewReader(tc.content)
+			size := int64(len(tc.content))
+
+			enc, err := DetectTextEncoding(r)
+			require.NoError(t, err)
+
+			offset := int64(0)
+			if tc.tail > 0 {
+				offset, err = enc.TailOffset(r, size, tc.tail)
+				require.NoError(t, err)
+			}
+
+			o := opts
+			o.Skip = tc.skip
+			o.Partial = tc.partial
+			rng, err := enc.ReadLines(r, size, offset, o)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, rng.Lines)
+			require.Equal(t, tc.wantEOF, rng.EOF)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package fileutils
+
+import (
+	"time"
+)
+
+// Sizes of the zip records, see the APPNOTE of the format.
+const (
+	zipFileHeaderLen       = 30
+	zipDirectoryHeaderLen  = 46
+	zipDirectoryEndLen     = 22
+	zipDataDescriptorLen   = 16
+	zipDataDescriptor64Len = 24
+	zipDirectory64EndLen   = 56
+	zipDirectory64LocLen   = 20
+	zipZip64ExtraLen       = 28
+	zipExtTimeExtraLen     = 9
+	zipUint16Max           = 1<<16 - 1
+	zipUint32Max           = 1<<32 - 1
+)
+
+// ZipSizer computes the exact size of a zip archive whose entries are
+// stored without compression, as written by klauspost/compress/zip, so
+// that it can be announced before the archive is produced.
+type ZipSizer struct {
+	size    int64 // size of the entries
+	dirSize int64 // size of the central directory
+	entries int64
+}
+
+// Add accounts for an entry. Directory names must not have the
+// trailing slash, it is added as the archive writer does.
+func (z *ZipSizer) Add(name string, size int64, modTime time.Time, isDir bool) {
+	var extra int64
+	if !modTime.IsZero() {
+		extra = zipExtTimeExtraLen
+	}
+	if isDir {
+		name += "/"
+	}
+
+	offset := z.size
+	zip64 := false
+	z.size += zipFileHeaderLen + int64(len(name)) + extra
+	if !isDir {
+		z.size += size
+		if size >= zipUint32Max {
+			zip64 = true
+			z.size += zipDataDescriptor64Len
+		} else {
+			z.size += zipDataDescriptorLen
+		}
+	}
+
+	z.dirSize += zipDirectoryHeaderLen + int64(len(name)) + extra
+	if zip64 || offset >= zipUint32Max {
+		z.dirSize += zipZip64ExtraLen
+	}
+
+	z.entries++
+}
+
+// Size returns the size of the archive with the entries added so far.
+func (z *ZipSizer) Size() int64 {
+	size := z.size + z.dirSize + zipDirectoryEndLen
+	if z.entries >= zipUint16Max || z.dirSize >= zipUint32Max || z.size >= zipUint32Max {
+		size += zipDirectory64EndLen + zipDirectory64LocLen
+	}
+
+	return size
+}
@@ -0,0 +1,72 @@
+package fileutils
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mholt/archiver/v3"
+	"github.com/stretchr/testify/require"
+)
+
+type zipEntry struct {
+	name    string
+	content string
+	modTime time.Time
+	isDir   bool
+}
+
+func (e zipEntry) Name() string       { return e.name }
+func (e zipEntry) Size() int64        { return int64(len(e.content)) }
+func (e zipEntry) ModTime() time.Time { return e.modTime }
+func (e zipEntry) IsDir() bool        { return e.isDir }
+func (e zipEntry) Sys() interface{}   { return nil }
+
+func (e zipEntry) Mode() os.FileMode {
+	if e.isDir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+func TestZipSizer(t *testing.T) {
+	modTime := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := map[string][]zipEntry{
+		"empty": {},
+		"files and directories": {
+			{name: "dir", isDir: true, modTime: modTime},
+			{name: "dir/a.txt", content: "hello", modTime: modTime},
+			{name: "dir/ünïcode.bin", content: strings.Repeat("x", 4096), modTime: modTime},
+			{name: "empty.txt", modTime: modTime},
+		},
+		"zero modification time": {
+			{name: "a.txt", content: "a"},
+		},
+	}
+
+	for name, entries := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ar := archiver.NewZip()
+			ar.FileMethod = archiver.Store
+
+			buf := &bytes.Buffer{}
+			require.NoError(t, ar.Create(buf))
+
+			sizer := &ZipSizer{}
+			for _, e := range entries {
+				require.NoError(t, ar.Write(archiver.File{
+					FileInfo:   e,
+					ReadCloser: io.NopCloser(strings.NewReader(e.content)),
+				}))
+				sizer.Add(e.name, e.Size(), e.modTime, e.isDir)
+			}
+			require.NoError(t, ar.Close())
+
+			require.Equal(t, int64(buf.Len()), sizer.Size())
+		})
+	}
+}
@@ -166,6 +166,11 @@ export function getPreviewURL(file, size) {
     key: Date.parse(file.modified),
   };
 
+  // the presets ending with @2x fall back to their base size if missing
+  if (window.devicePixelRatio > 1) {
+    size += "@2x";
+  }
+
   return createURL("api/preview/" + size + file.path, params);
 }
 
@@ -176,7 +181,11 @@ export function getSubtitlesURL(file) {
 
   const subtitles = [];
   for (const sub of file.subtitles) {
-    subtitles.push(createURL("api/raw" + sub, params));
+    subtitles.push({
+      src: createURL("api/subtitles" + sub.path, params),
+      label: sub.label,
+      language: sub.language,
+    });
   }
 
   return subtitles;
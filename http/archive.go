@@ -0,0 +1,43 @@
+package http
+
+import (
+	"os"
+
+	"github.com/filebrowser/filebrowser/v2/archivefs"
+)
+
+// archiveData returns a copy of d whose filesystem is the read-only
+// view of the archive that p goes through. If p isn't inside an
+// archive, d is returned unchanged.
+func archiveData(d *data, p string) (*data, error) {
+	archive, ok := archivefs.Split(d.token.Fs, p)
+	if !ok {
+		return d, nil
+	}
+
+	if !d.Check(archive) {
+		return nil, os.ErrPermission
+	}
+
+	fs, err := archivefs.New(d.token.Fs, archive)
+	if err != nil {
+		return nil, err
+	}
+
+	token := *d.token
+	token.Fs = fs
+	token.Perm.Create = false
+	token.Perm.Rename = false
+	token.Perm.Modify = false
+	token.Perm.Delete = false
+
+	// reading the headers would scan compressed tar archives once
+	// per entry, so types are detected by extension only
+	server := *d.server
+	server.TypeDetectionByHeader = false
+
+	archived := *d
+	archived.token = &token
+	archived.server = &server
+	return &archived, nil
+}
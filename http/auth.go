@@ -2,6 +2,8 @@ package http
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -173,6 +175,7 @@ func withUser(fn handleFunc) handleFunc {
 			HideDotfiles:         tk.User.HideDotfiles,
 			EncryptedCredentials: tk.User.EncryptedCredentials,
 			Raw:                  token.Raw,
+			Session:              sessionOwner(token.Raw, sessionId),
 		}
 
 		d.token = tokenPayload
@@ -180,6 +183,13 @@ func withUser(fn handleFunc) handleFunc {
 	}
 }
 
+// sessionOwner identifies a session by its token and session id, so
+// that the users sharing a scope don't see each other's jobs.
+func sessionOwner(rawToken, sessionID string) string {
+	sum := sha256.Sum256([]byte(rawToken + "\x00" + sessionID))
+	return hex.EncodeToString(sum[:])
+}
+
 var checkTokenHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
 	return http.StatusOK, nil
 })
@@ -11,7 +11,9 @@ import (
 	"github.com/filebrowser/filebrowser/v2/runner"
 	"github.com/filebrowser/filebrowser/v2/settings"
 	"github.com/filebrowser/filebrowser/v2/storage"
+	"github.com/filebrowser/filebrowser/v2/trash"
 	"github.com/filebrowser/filebrowser/v2/users"
+	"github.com/filebrowser/filebrowser/v2/versions"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -28,8 +30,17 @@ type data struct {
 	redis    *redis.Client
 }
 
+// versions returns the version store of the current user.
+func (d *data) versions() *versions.Store {
+	return versions.New(d.token.Fs, d.settings.Versions.MaxCount, d.settings.Versions.MaxAge)
+}
+
 // Check implements rules.Checker.
 func (d *data) Check(path string) bool {
+	if trash.IsTrashPath(path) || versions.IsVersionsPath(path) {
+		return false
+	}
+
 	if d.token.HideDotfiles && rules.MatchHidden(path) {
 		return false
 	}
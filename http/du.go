@@ -0,0 +1,69 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+)
+
+const (
+	defaultDiskUsageTop = 20
+	maxDiskUsageTop     = 1000
+	// diskUsageCacheEntries is the number of directory entries kept
+	// between scans.
+	diskUsageCacheEntries = 1 << 20
+)
+
+// diskUsageHandler returns the recursive size of a directory, with its
+// largest subdirectories and files (top=N). With async=true, the scan
+// runs as a job whose result is the disk usage.
+func diskUsageHandler(cache *fileutils.DiskUsageCache, jobMgr *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		file, err := files.NewFileInfo(files.FileOptions{
+			Fs:      d.token.Fs,
+			Path:    r.URL.Path,
+			Modify:  d.token.Perm.Modify,
+			Expand:  false,
+			Checker: d,
+		})
+		if err != nil {
+			return errToStatus(err), err
+		}
+		if !file.IsDir {
+			return http.StatusBadRequest, errors.ErrInvalidRequestParams
+		}
+
+		query := intQuery{r: r}
+		top := query.int("top", defaultDiskUsageTop)
+		if query.err != nil || top < 0 || top > maxDiskUsageTop {
+			return http.StatusBadRequest, errors.ErrInvalidRequestParams
+		}
+
+		opts := fileutils.DiskUsageOptions{Top: int(top), Check: d.Check}
+
+		if isAsync(r) {
+			job := jobMgr.Start(d.token.Session, "du", func(ctx context.Context, job *jobs.Job) error {
+				opts.Progress = job
+				usage, scanErr := cache.DiskUsage(ctx, d.token.Fs, file.Path, opts)
+				if scanErr != nil {
+					return scanErr
+				}
+				job.SetResult(usage)
+				return nil
+			})
+
+			return renderJSON(w, r, job.Info())
+		}
+
+		usage, err := cache.DiskUsage(r.Context(), d.token.Fs, file.Path, opts)
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		return renderJSON(w, r, usage)
+	})
+}
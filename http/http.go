@@ -6,8 +6,13 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+	"github.com/filebrowser/filebrowser/v2/preview"
 	"github.com/filebrowser/filebrowser/v2/settings"
 	"github.com/filebrowser/filebrowser/v2/storage"
+	"github.com/filebrowser/filebrowser/v2/trash"
+	"github.com/filebrowser/filebrowser/v2/watch"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -19,6 +24,10 @@ import (
 func NewHandler(
 	imgSvc ImgService,
 	fileCache FileCache,
+	previewGroup *preview.Group,
+	jobMgr *jobs.Manager,
+	trashPurger *trash.Purger,
+	watchHub *watch.Hub,
 	store *storage.Storage,
 	server *settings.Server,
 	assetsFs fs.FS,
@@ -34,6 +43,8 @@ func NewHandler(
 		})
 	})
 	index, static := getStaticHandlers(store, server, assetsFs, rdb)
+	duCache := fileutils.NewDiskUsageCache(diskUsageCacheEntries)
+	previewRunner := preview.NewRunner()
 
 	// NOTE: This fixes the issue where it would redirect if people did not put a
 	// trailing slash in the end. I hate this decision since this allows some awful
@@ -45,6 +56,7 @@ func NewHandler(
 	}
 
 	r.HandleFunc("/health", healthHandler)
+	r.PathPrefix(davPrefix).Handler(monkey(davHandler(fileCache, trashPurger), ""))
 	r.PathPrefix("/static").Handler(static)
 	r.NotFoundHandler = index
 
@@ -62,10 +74,10 @@ func NewHandler(
 	// users.Handle("/{id:[0-9]+}", monkey(userDeleteHandler, "")).Methods("DELETE")
 
 	api.PathPrefix("/resources").Handler(monkey(resourceGetHandler, "/api/resources")).Methods("GET")
-	api.PathPrefix("/resources").Handler(monkey(resourceDeleteHandler(fileCache), "/api/resources")).Methods("DELETE")
+	api.PathPrefix("/resources").Handler(monkey(resourceDeleteHandler(fileCache, jobMgr, trashPurger), "/api/resources")).Methods("DELETE")
 	api.PathPrefix("/resources").Handler(monkey(resourcePostHandler(fileCache), "/api/resources")).Methods("POST")
-	api.PathPrefix("/resources").Handler(monkey(resourcePutHandler, "/api/resources")).Methods("PUT")
-	api.PathPrefix("/resources").Handler(monkey(resourcePatchHandler(fileCache), "/api/resources")).Methods("PATCH")
+	api.PathPrefix("/resources").Handler(monkey(resourcePutHandler(fileCache), "/api/resources")).Methods("PUT")
+	api.PathPrefix("/resources").Handler(monkey(resourcePatchHandler(fileCache, jobMgr), "/api/resources")).Methods("PATCH")
 
 	// api.PathPrefix("/usage").Handler(monkey(diskUsage, "/api/usage")).Methods("GET")
 
@@ -74,12 +86,33 @@ func NewHandler(
 	// api.PathPrefix("/share").Handler(monkey(sharePostHandler, "/api/share")).Methods("POST")
 	// api.PathPrefix("/share").Handler(monkey(shareDeleteHandler, "/api/share")).Methods("DELETE")
 
+	jobsRouter := api.PathPrefix("/jobs").Subrouter()
+	jobsRouter.Handle("", monkey(jobsListHandler(jobMgr), "")).Methods("GET")
+	jobsRouter.Handle("/{id}", monkey(jobGetHandler(jobMgr), "")).Methods("GET")
+	jobsRouter.Handle("/{id}", monkey(jobCancelHandler(jobMgr), "")).Methods("DELETE")
+	jobsRouter.Handle("/{id}/events", monkey(jobEventsHandler(jobMgr), "")).Methods("GET")
+	jobsRouter.Handle("/{id}/download", monkey(jobDownloadHandler(jobMgr), "")).Methods("GET")
+
+	api.PathPrefix("/versions").Handler(monkey(versionsGetHandler, "/api/versions")).Methods("GET")
+	api.PathPrefix("/versions").Handler(monkey(versionRestoreHandler(fileCache), "/api/versions")).Methods("POST")
+
+	trashRouter := api.PathPrefix("/trash").Subrouter()
+	trashRouter.Handle("", monkey(trashListHandler(trashPurger), "")).Methods("GET")
+	trashRouter.Handle("", monkey(trashEmptyHandler(jobMgr, trashPurger), "")).Methods("DELETE")
+	trashRouter.Handle("/{id}", monkey(trashRestoreHandler(trashPurger), "")).Methods("POST")
+	trashRouter.Handle("/{id}", monkey(trashDeleteHandler(trashPurger), "")).Methods("DELETE")
+
 	// api.Handle("/settings", monkey(settingsGetHandler, "")).Methods("GET")
 	// api.Handle("/settings", monkey(settingsPutHandler, "")).Methods("PUT")
 
-	api.PathPrefix("/raw").Handler(monkey(rawHandler, "/api/raw")).Methods("GET")
+	api.PathPrefix("/raw").Handler(monkey(rawHandler(jobMgr), "/api/raw")).Methods("GET")
+	api.PathPrefix("/subtitles").Handler(monkey(subtitlesHandler, "/api/subtitles")).Methods("GET")
+	api.PathPrefix("/text").Handler(monkey(textHandler, "/api/text")).Methods("GET")
+	api.PathPrefix("/du").Handler(monkey(diskUsageHandler(duCache, jobMgr), "/api/du")).Methods("GET")
+	api.PathPrefix("/types").Handler(monkey(typesHandler, "/api/types")).Methods("GET")
+	api.PathPrefix("/watch").Handler(monkey(watchHandler(watchHub), "/api/watch")).Methods("GET")
 	api.PathPrefix("/preview/{size}/{path:.*}").
-		Handler(monkey(previewHandler(imgSvc, fileCache, server.EnableThumbnails, server.ResizePreview), "/api/preview")).Methods("GET")
+		Handler(monkey(previewHandler(imgSvc, fileCache, previewGroup, previewRunner, server.EnableThumbnails, server.ResizePreview), "/api/preview")).Methods("GET")
 	// api.PathPrefix("/command").Handler(monkey(commandsHandler, "/api/command")).Methods("GET")
 	api.PathPrefix("/search").Handler(monkey(searchHandler, "/api/search")).Methods("GET")
 
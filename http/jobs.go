@@ -0,0 +1,119 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/filebrowser/filebrowser/v2/jobs"
+)
+
+const jobEventsInterval = 500 * time.Millisecond
+
+func isAsync(r *http.Request) bool {
+	return r.URL.Query().Get("async") == "true"
+}
+
+func jobsListHandler(jobMgr *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		return renderJSON(w, r, jobMgr.List(d.token.Session))
+	})
+}
+
+func jobGetHandler(jobMgr *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		job, err := jobMgr.Get(d.token.Session, mux.Vars(r)["id"])
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		return renderJSON(w, r, job.Info())
+	})
+}
+
+func jobCancelHandler(jobMgr *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		err := jobMgr.Cancel(d.token.Session, mux.Vars(r)["id"])
+		return errToStatus(err), err
+	})
+}
+
+// jobEventsHandler streams the progress of a job as Server-Sent Events
+// until it finishes or the client goes away.
+func jobEventsHandler(jobMgr *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		job, err := jobMgr.Get(d.token.Session, mux.Vars(r)["id"])
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		stream, err := newEventStream(w)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		ticker := time.NewTicker(jobEventsInterval)
+		defer ticker.Stop()
+
+		var last jobs.Info
+		for {
+			select {
+			case <-r.Context().Done():
+				return 0, nil
+			case <-job.Done():
+				return 0, stream.send("done", job.Info())
+			case <-ticker.C:
+				info := job.Info()
+				if info.BytesDone == last.BytesDone && info.FilesDone == last.FilesDone {
+					continue
+				}
+				last = info
+				if err := stream.send("progress", info); err != nil {
+					return 0, nil
+				}
+			}
+		}
+	})
+}
+
+// jobDownloadHandler serves the file produced by a finished job.
+func jobDownloadHandler(jobMgr *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.token.Perm.Download {
+			return http.StatusAccepted, nil
+		}
+
+		job, err := jobMgr.Get(d.token.Session, mux.Vars(r)["id"])
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		if job.Info().Status != jobs.StatusDone {
+			return http.StatusConflict, nil
+		}
+
+		artifact, name := job.Artifact()
+		if artifact == "" {
+			return http.StatusNotFound, nil
+		}
+
+		fd, err := os.Open(artifact)
+		if err != nil {
+			return errToStatus(err), err
+		}
+		defer fd.Close()
+
+		info, err := fd.Stat()
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		w.Header().Set("Content-Disposition", "attachment; filename*=utf-8''"+url.PathEscape(name))
+		w.Header().Set("Cache-Control", "private")
+		http.ServeContent(w, r, name, info.ModTime(), fd)
+		return 0, nil
+	})
+}
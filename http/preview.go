@@ -1,4 +1,3 @@
-//go:generate go-enum --sql --marshal --names --file $GOFILE
 package http
 
 import (
@@ -7,42 +6,57 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 
 	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/filetype"
 	"github.com/filebrowser/filebrowser/v2/img"
+	"github.com/filebrowser/filebrowser/v2/preview"
+	"github.com/filebrowser/filebrowser/v2/settings"
 )
 
-/*
-ENUM(
-thumb
-big
-)
-*/
-type PreviewSize int
+// thumbnailPriority is the priority of the resizes of the thumbnails.
+const thumbnailPriority = 1
 
 type ImgService interface {
 	FormatFromExtension(ext string) (img.Format, error)
+	CanEncode(format img.Format) bool
 	Resize(ctx context.Context, in io.Reader, width, height int, out io.Writer, options ...img.Option) error
+	ResizeRaw(ctx context.Context, in io.ReaderAt, size int64, width, height int, out io.Writer, options ...img.Option) error
+	ResizeAnimated(ctx context.Context, in io.Reader, width, height int, out io.Writer, options ...img.Option) error
 }
 
+// resizeFunc resizes an image to width and height.
+type resizeFunc func(ctx context.Context, width, height int, out io.Writer, options ...img.Option) error
+
 type FileCache interface {
 	Store(ctx context.Context, key string, value []byte) error
 	Load(ctx context.Context, key string) ([]byte, bool, error)
 	Delete(ctx context.Context, key string) error
+	Record(ctx context.Context, name, key string) error
+	DeletePath(ctx context.Context, name string) error
 }
 
-func previewHandler(imgSvc ImgService, fileCache FileCache, enableThumbnails, resizePreview bool) handleFunc {
+func previewHandler(imgSvc ImgService, fileCache FileCache, previewGroup *preview.Group, previewRunner *preview.Runner,
+	enableThumbnails, resizePreview bool) handleFunc {
 	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
 		if !d.token.Perm.Download {
 			return http.StatusAccepted, nil
 		}
 		vars := mux.Vars(r)
 
-		previewSize, err := ParsePreviewSize(vars["size"])
+		preset, ok := d.settings.Previews.Preset(vars["size"])
+		if !ok {
+			return http.StatusBadRequest, fmt.Errorf("unknown preview size %s", vars["size"])
+		}
+
+		d, err := archiveData(d, "/"+vars["path"])
 		if err != nil {
-			return http.StatusBadRequest, err
+			return errToStatus(err), err
 		}
 
 		file, err := files.NewFileInfo(files.FileOptions{
@@ -59,12 +73,18 @@ func previewHandler(imgSvc ImgService, fileCache FileCache, enableThumbnails, re
 
 		setContentDisposition(w, r, file)
 
-		switch file.Type {
-		case "image":
-			return handleImagePreview(w, r, imgSvc, fileCache, file, previewSize, enableThumbnails, resizePreview)
-		default:
-			return http.StatusNotImplemented, fmt.Errorf("can't create preview for %s type", file.Type)
+		if filetype.Category(file.Category) == filetype.Image {
+			return handleImagePreview(w, r, imgSvc, fileCache, previewGroup, &d.settings.Previews,
+				file, preset, enableThumbnails, resizePreview)
+		}
+
+		// other types are previewed by the external generators, which
+		// are commands just like the hooks of the command runner.
+		if gen, ok := d.settings.Previews.Generator(file.MimeType); ok && d.server.EnableExec {
+			return handleGeneratedPreview(w, r, d, imgSvc, fileCache, previewRunner, previewGroup, file, gen, preset)
 		}
+
+		return http.StatusNotImplemented, fmt.Errorf("can't create preview for %s type", file.MimeType)
 	})
 }
 
@@ -73,84 +93,330 @@ func handleImagePreview(
 	r *http.Request,
 	imgSvc ImgService,
 	fileCache FileCache,
+	previewGroup *preview.Group,
+	previews *settings.Previews,
 	file *files.FileInfo,
-	previewSize PreviewSize,
+	preset settings.PreviewPreset,
 	enableThumbnails, resizePreview bool,
 ) (int, error) {
-	if (previewSize == PreviewSizeBig && !resizePreview) ||
-		(previewSize == PreviewSizeThumb && !enableThumbnails) {
+	format, resize, err := imagePreviewSource(imgSvc, file, preset, enableThumbnails, resizePreview)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	if !resize {
 		return rawFileHandler(w, r, file)
 	}
 
-	format, err := imgSvc.FormatFromExtension(file.Extension)
-	// Unsupported extensions directly return the raw data
-	if err == img.ErrUnsupportedFormat || format == img.FormatGif {
-		return rawFileHandler(w, r, file)
+	previewFormat := negotiatePreviewFormat(r, imgSvc, format, preset)
+	// the thumbnails of GIFs show their first frame, unless the small
+	// animated variant is asked for. The previews of APNGs always show
+	// their first frame, their default image.
+	if format == img.FormatGif && r.URL.Query().Get("animated") == "true" {
+		previewFormat = img.FormatGif
 	}
+	cacheKey, err := previewKey(r.Context(), fileCache, previews, file, preset, previewFormat)
 	if err != nil {
 		return errToStatus(err), err
 	}
-
-	cacheKey := previewCacheKey(file, previewSize)
 	resizedImage, ok, err := fileCache.Load(r.Context(), cacheKey)
 	if err != nil {
 		return errToStatus(err), err
 	}
 	if !ok {
-		resizedImage, err = createPreview(imgSvc, fileCache, file, previewSize)
+		// the concurrent requests of the preview wait for the same resize
+		resizedImage, err = previewGroup.Do(r.Context(), cacheKey, func(ctx context.Context) ([]byte, error) {
+			resized, err := createPreview(ctx, imgSvc, file, preset, previewFormat, presetPriority(preset)) //nolint:govet
+			if err == nil {
+				go storePreview(fileCache, previews, file, cacheKey, resized)
+			}
+			return resized, err
+		})
 		if err != nil {
 			return errToStatus(err), err
 		}
 	}
 
+	// the preview may have another format than the image
 	w.Header().Set("Cache-Control", "private")
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", http.DetectContentType(resizedImage))
 	http.ServeContent(w, r, file.Name, file.ModTime, bytes.NewReader(resizedImage))
 
 	return 0, nil
 }
 
-func createPreview(imgSvc ImgService, fileCache FileCache,
-	file *files.FileInfo, previewSize PreviewSize) ([]byte, error) {
+// imagePreviewSource returns the format of the image file and whether
+// its preview is resized. Otherwise the file itself is the preview.
+func imagePreviewSource(imgSvc ImgService, file *files.FileInfo, preset settings.PreviewPreset,
+	enableThumbnails, resizePreview bool) (img.Format, bool, error) {
+	// camera RAW files are always previewed with their embedded JPEG,
+	// browsers can't show them.
+	if img.IsRaw(file.Extension) {
+		return img.FormatJpeg, true, nil
+	}
+	if (!preset.Thumbnail && !resizePreview) || (preset.Thumbnail && !enableThumbnails) {
+		return 0, false, nil
+	}
+
+	format, err := imgSvc.FormatFromExtension(file.Extension)
+	// Unsupported extensions directly return the raw data, and so do
+	// the GIFs but for their thumbnails, to keep their animation.
+	if err == img.ErrUnsupportedFormat || (format == img.FormatGif && !preset.Thumbnail) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return format, true, nil
+}
+
+func createPreview(ctx context.Context, imgSvc ImgService, file *files.FileInfo,
+	preset settings.PreviewPreset, format img.Format, priority int) ([]byte, error) {
 	fd, err := file.Fs.Open(file.Path)
 	if err != nil {
 		return nil, err
 	}
 	defer fd.Close()
 
-	var (
-		width   int
-		height  int
-		options []img.Option
-	)
-
+	resize := func(ctx context.Context, width, height int, out io.Writer, options ...img.Option) error {
+		return imgSvc.Resize(ctx, fd, width, height, out, options...)
+	}
 	switch {
-	case previewSize == PreviewSizeBig:
-		width = 1080
-		height = 1080
-		options = append(options, img.WithMode(img.ResizeModeFit), img.WithQuality(img.QualityMedium))
-	case previewSize == PreviewSizeThumb:
-		width = 256
-		height = 256
-		options = append(options, img.WithMode(img.ResizeModeFill), img.WithQuality(img.QualityLow), img.WithFormat(img.FormatJpeg))
-	default:
-		return nil, img.ErrUnsupportedFormat
+	case img.IsRaw(file.Extension):
+		resize = func(ctx context.Context, width, height int, out io.Writer, options ...img.Option) error {
+			return imgSvc.ResizeRaw(ctx, fd, file.Size, width, height, out, options...)
+		}
+	case format == img.FormatGif:
+		// the only gif previews are the animated ones
+		resize = func(ctx context.Context, width, height int, out io.Writer, options ...img.Option) error {
+			return imgSvc.ResizeAnimated(ctx, fd, width, height, out, options...)
+		}
+	}
+
+	return resizePreviewImage(ctx, resize, preset, format, priority)
+}
+
+// resizePreviewImage resizes an image to preset and encodes it to format.
+func resizePreviewImage(ctx context.Context, resize resizeFunc, preset settings.PreviewPreset,
+	format img.Format, priority int) ([]byte, error) {
+	options := []img.Option{
+		img.WithMode(preset.Mode),
+		img.WithQuality(preset.Quality),
+		img.WithFormat(format),
+		img.WithPriority(priority),
 	}
 
 	buf := &bytes.Buffer{}
-	if err := imgSvc.Resize(context.Background(), fd, width, height, buf, options...); err != nil {
+	if err := resize(ctx, preset.Width, preset.Height, buf, options...); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// presetPriority returns the priority of the resizes of the previews
+// requested at preset: the thumbnails of the listings overtake the
+// bigger previews.
+func presetPriority(preset settings.PreviewPreset) int {
+	if preset.Thumbnail {
+		return thumbnailPriority
+	}
+	return 0
+}
+
+// storePreview caches a preview in the background of the request.
+func storePreview(fileCache FileCache, previews *settings.Previews, file *files.FileInfo, cacheKey string, preview []byte) {
+	if err := cachePreview(context.Background(), fileCache, previews, file, cacheKey, preview); err != nil {
+		fmt.Printf("failed to cache resized image: %v", err)
+	}
+}
+
+// cachePreview stores the preview of file, and records its key under
+// the path of file for delThumbs. The previews keyed by content may be
+// shared by identical files, so only the hash of the file is recorded
+// then, by the index.
+func cachePreview(ctx context.Context, fileCache FileCache, previews *settings.Previews, file *files.FileInfo,
+	cacheKey string, preview []byte) error {
+	if err := fileCache.Store(ctx, cacheKey, preview); err != nil {
+		return err
+	}
+	if previews.ContentKeys {
+		return nil
+	}
+	return fileCache.Record(ctx, file.RealPath(), cacheKey)
+}
+
+func handleGeneratedPreview(
+	w http.ResponseWriter,
+	r *http.Request,
+	d *data,
+	imgSvc ImgService,
+	fileCache FileCache,
+	previewRunner *preview.Runner,
+	previewGroup *preview.Group,
+	file *files.FileInfo,
+	gen *settings.PreviewGenerator,
+	preset settings.PreviewPreset,
+) (int, error) {
+	previewFormat := negotiatePreviewFormat(r, imgSvc, img.FormatJpeg, preset)
+	cacheKey, err := previewKey(r.Context(), fileCache, &d.settings.Previews, file, preset, previewFormat)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	previewImage, ok, err := fileCache.Load(r.Context(), cacheKey)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	if !ok {
+		previewImage, err = previewGroup.Do(r.Context(), cacheKey, func(ctx context.Context) ([]byte, error) {
+			generated, err := generatePreview(ctx, &d.settings.Previews, imgSvc, previewRunner, //nolint:govet
+				file, gen, preset, previewFormat, presetPriority(preset))
+			if err == nil {
+				go storePreview(fileCache, &d.settings.Previews, file, cacheKey, generated)
+			}
+			return generated, err
+		})
+		if err != nil {
+			return errToStatus(err), err
+		}
+	}
+
+	w.Header().Set("Cache-Control", "private")
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", http.DetectContentType(previewImage))
+	http.ServeContent(w, r, file.Name, file.ModTime, bytes.NewReader(previewImage))
+
+	return 0, nil
+}
+
+func generatePreview(ctx context.Context, previews *settings.Previews, imgSvc ImgService,
+	previewRunner *preview.Runner, file *files.FileInfo, gen *settings.PreviewGenerator,
+	preset settings.PreviewPreset, format img.Format, priority int) ([]byte, error) {
+	input, cleanup, err := previewInput(file)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	size := preset.Width
+	if preset.Height > size {
+		size = preset.Height
+	}
+
+	out, err := previewRunner.Run(ctx, gen.Command, preview.Options{
+		Input:         input,
+		Size:          size,
+		Timeout:       previews.Timeout,
+		MaxConcurrent: previews.MaxConcurrent,
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	go func() {
-		cacheKey := previewCacheKey(file, previewSize)
-		if err := fileCache.Store(context.Background(), cacheKey, buf.Bytes()); err != nil {
-			fmt.Printf("failed to cache resized image: %v", err)
+	resize := func(ctx context.Context, width, height int, w io.Writer, options ...img.Option) error {
+		return imgSvc.Resize(ctx, bytes.NewReader(out), width, height, w, options...)
+	}
+	return resizePreviewImage(ctx, resize, preset, format, priority)
+}
+
+// previewInput returns the path of file on the host. The files that
+// aren't plain host files, such as archive entries, are copied to a
+// temporary file first.
+func previewInput(file *files.FileInfo) (name string, cleanup func(), err error) {
+	realPath := file.RealPath()
+	if info, err := os.Stat(realPath); err == nil && info.Mode().IsRegular() && info.Size() == file.Size { //nolint:govet
+		return realPath, func() {}, nil
+	}
+
+	in, err := file.Fs.Open(file.Path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp("", "filebrowser-preview-*"+file.Extension)
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.Remove(tmp.Name()) }
+
+	_, err = io.Copy(tmp, in)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return tmp.Name(), cleanup, nil
+}
+
+// negotiatePreviewFormat returns webp if the client accepts it and it
+// can be encoded. Otherwise the previews of png images are png, to keep
+// their transparency, except for the thumbnails, and the others jpeg.
+func negotiatePreviewFormat(r *http.Request, imgSvc ImgService,
+	source img.Format, preset settings.PreviewPreset) img.Format {
+	return previewFormat(imgSvc, source, preset, accepts(r, "image/webp"))
+}
+
+// previewFormat returns the format of the previews of source images
+// sent to the clients accepting webp or not.
+func previewFormat(imgSvc ImgService, source img.Format, preset settings.PreviewPreset, acceptsWebp bool) img.Format {
+	switch {
+	case imgSvc.CanEncode(img.FormatWebp) && acceptsWebp:
+		return img.FormatWebp
+	case source == img.FormatPng && !preset.Thumbnail:
+		return img.FormatPng
+	default:
+		return img.FormatJpeg
+	}
+}
+
+// accepts returns whether the Accept header of r explicitly lists the
+// media type with a non-zero quality.
+func accepts(r *http.Request, mediaType string) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, item := range strings.Split(accept, ",") {
+			params := strings.Split(item, ";")
+			if !strings.EqualFold(strings.TrimSpace(params[0]), mediaType) {
+				continue
+			}
+
+			for _, param := range params[1:] {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if q, err := strconv.ParseFloat(value, 64); key == "q" && err == nil && q == 0 {
+					return false
+				}
+			}
+			return true
 		}
-	}()
+	}
+	return false
+}
 
-	return buf.Bytes(), nil
+// previewKey returns the cache key of the preview of file at preset,
+// made from the hash of its contents if the previews are keyed by
+// content. The hashes are indexed in fileCache.
+func previewKey(ctx context.Context, fileCache FileCache, previews *settings.Previews,
+	f *files.FileInfo, preset settings.PreviewPreset, format img.Format) (string, error) {
+	if !previews.ContentKeys {
+		return previewCacheKey(f, preset, format), nil
+	}
+
+	hash, err := preview.NewIndex(fileCache).Hash(ctx, f.RealPath(), f.Size, f.ModTime, func() (io.ReadCloser, error) {
+		return f.Fs.Open(f.Path)
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%x", hash, previewVariant(preset, format)), nil
+}
+
+func previewCacheKey(f *files.FileInfo, preset settings.PreviewPreset, format img.Format) string {
+	return fmt.Sprintf("%x%x%x", f.RealPath(), f.ModTime.Unix(), previewVariant(preset, format))
 }
 
-func previewCacheKey(f *files.FileInfo, previewSize PreviewSize) string {
-	return fmt.Sprintf("%x%x%x", f.RealPath(), f.ModTime.Unix(), previewSize)
+func previewVariant(preset settings.PreviewPreset, format img.Format) string {
+	return fmt.Sprintf("%s:%dx%d:%s:%s:%s", preset.Name, preset.Width, preset.Height, preset.Mode, preset.Quality, format)
 }
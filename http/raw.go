@@ -1,18 +1,29 @@
 package http
 
 import (
+	"compress/flate"
+	"compress/gzip"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	gopath "path"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/mholt/archiver/v3"
+	"github.com/spf13/afero"
 
+	libErrors "github.com/filebrowser/filebrowser/v2/errors"
 	"github.com/filebrowser/filebrowser/v2/files"
 	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/jobs"
 )
 
 func slashClean(name string) string {
@@ -43,29 +54,253 @@ func parseQueryFiles(r *http.Request, f *files.FileInfo) ([]string, error) {
 	return fileSlice, nil
 }
 
+// parseQueryAlgorithm returns the extension and the writer of the
+// archive format requested with the algo parameter. The level
+// parameter sets the compression level of the formats that have one.
+// Zip archives are deflated, which every zip tool including 7-Zip can
+// read, and the zipstore format stores the entries uncompressed.
+//
 // nolint: goconst,nolintlint
 func parseQueryAlgorithm(r *http.Request) (string, archiver.Writer, error) {
+	level, hasLevel, err := parseQueryLevel(r)
+	if err != nil {
+		return "", nil, err
+	}
+
 	// TODO: use enum
 	switch r.URL.Query().Get("algo") {
 	case "zip", "true", "":
-		return ".zip", archiver.NewZip(), nil
+		ar := archiver.NewZip()
+		if hasLevel {
+			if level < flate.HuffmanOnly || level > flate.BestCompression {
+				return "", nil, errInvalidLevel
+			}
+			ar.CompressionLevel = level
+		}
+		return ".zip", ar, nil
+	case "zipstore":
+		ar := archiver.NewZip()
+		ar.FileMethod = archiver.Store
+		return ".zip", ar, nil
 	case "tar":
 		return ".tar", archiver.NewTar(), nil
 	case "targz":
-		return ".tar.gz", archiver.NewTarGz(), nil
+		ar := archiver.NewTarGz()
+		if hasLevel {
+			if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+				return "", nil, errInvalidLevel
+			}
+			ar.CompressionLevel = level
+		}
+		return ".tar.gz", ar, nil
 	case "tarbz2":
-		return ".tar.bz2", archiver.NewTarBz2(), nil
+		ar := archiver.NewTarBz2()
+		if hasLevel {
+			if level < 1 || level > 9 {
+				return "", nil, errInvalidLevel
+			}
+			ar.CompressionLevel = level
+		}
+		return ".tar.bz2", ar, nil
 	case "tarxz":
 		return ".tar.xz", archiver.NewTarXz(), nil
 	case "tarlz4":
-		return ".tar.lz4", archiver.NewTarLz4(), nil
+		ar := archiver.NewTarLz4()
+		if hasLevel {
+			if level < 0 || level > 9 {
+				return "", nil, errInvalidLevel
+			}
+			ar.CompressionLevel = level
+		}
+		return ".tar.lz4", ar, nil
 	case "tarsz":
 		return ".tar.sz", archiver.NewTarSz(), nil
+	case "tarzst":
+		ar := &tarZstd{Tar: archiver.NewTar(), level: zstd.SpeedDefault}
+		if hasLevel {
+			if level < 1 || level > 22 {
+				return "", nil, errInvalidLevel
+			}
+			ar.level = zstd.EncoderLevelFromZstd(level)
+		}
+		return ".tar.zst", ar, nil
 	default:
-		return "", nil, errors.New("format not implemented")
+		return "", nil, fmt.Errorf("format not implemented: %w", libErrors.ErrInvalidRequestParams)
 	}
 }
 
+var errInvalidLevel = fmt.Errorf("compression level out of range: %w", libErrors.ErrInvalidRequestParams)
+
+func parseQueryLevel(r *http.Request) (level int, ok bool, err error) {
+	value := r.URL.Query().Get("level")
+	if value == "" {
+		return 0, false, nil
+	}
+
+	level, err = strconv.Atoi(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid compression level: %w", libErrors.ErrInvalidRequestParams)
+	}
+
+	return level, true, nil
+}
+
+// parseQueryExclude returns the patterns of the exclude parameter. They
+// are matched against the names and the paths relative to the archive
+// root of the files, on top of the user rules.
+func parseQueryExclude(r *http.Request) ([]string, error) {
+	value := r.URL.Query().Get("exclude")
+	if value == "" {
+		return nil, nil
+	}
+
+	patterns := strings.Split(value, ",")
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", pattern, libErrors.ErrInvalidRequestParams)
+		}
+	}
+
+	return patterns, nil
+}
+
+func isExcluded(exclude []string, path, commonPath string) bool {
+	rel := strings.TrimPrefix(strings.TrimPrefix(path, commonPath), string(filepath.Separator))
+	for _, pattern := range exclude {
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tarZstd writes tar.zst archives with a configurable compression
+// level, which archiver.TarZstd doesn't expose.
+type tarZstd struct {
+	*archiver.Tar
+	level zstd.EncoderLevel
+	zw    *zstd.Encoder
+}
+
+func (t *tarZstd) Create(out io.Writer) error {
+	zw, err := zstd.NewWriter(out, zstd.WithEncoderLevel(t.level))
+	if err != nil {
+		return err
+	}
+	t.zw = zw
+
+	return t.Tar.Create(zw)
+}
+
+func (t *tarZstd) Close() error {
+	err := t.Tar.Close()
+	if closeErr := t.zw.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// zipSnapshot is an archiver.Writer that doesn't write anything and
+// records the entries of an archive, computing the size of their zip
+// archive of stored entries, to write them later as they were.
+type zipSnapshot struct {
+	fileutils.ZipSizer
+	entries []archiver.FileInfo
+}
+
+func (z *zipSnapshot) Create(io.Writer) error {
+	return nil
+}
+
+func (z *zipSnapshot) Write(f archiver.File) error {
+	z.Add(f.Name(), f.Size(), f.ModTime(), f.IsDir())
+	z.entries = append(z.entries, archiver.FileInfo{FileInfo: f.FileInfo, CustomName: f.Name()})
+	return nil
+}
+
+func (z *zipSnapshot) Close() error {
+	return nil
+}
+
+// errArchiveChanged aborts the archives whose files changed after
+// their size was announced.
+var errArchiveChanged = errors.New("file changed while being archived")
+
+// writeTo writes the recorded entries of src to ar, and fails if a file
+// no longer has its recorded size.
+func (z *zipSnapshot) writeTo(ctx context.Context, ar archiver.Writer, d *data, src *archiveSource) error {
+	for _, entry := range z.entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		file, err := d.token.Fs.Open(filepath.Join(src.commonDir, entry.Name()))
+		if err != nil {
+			return err
+		}
+
+		var r io.Reader = file
+		if !entry.IsDir() {
+			r = &exactReader{r: file, left: entry.Size()}
+		}
+		err = ar.Write(archiver.File{
+			FileInfo: entry,
+			ReadCloser: struct {
+				io.Reader
+				io.Closer
+			}{r, file},
+		})
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exactReader reads exactly left bytes from r, and fails if r has more
+// or less of them.
+type exactReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (e *exactReader) Read(b []byte) (int, error) {
+	if e.left <= 0 {
+		// r must be done too
+		if n, _ := e.r.Read(make([]byte, 1)); n > 0 {
+			return 0, errArchiveChanged
+		}
+		return 0, io.EOF
+	}
+
+	if int64(len(b)) > e.left {
+		b = b[:e.left]
+	}
+	n, err := e.r.Read(b)
+	e.left -= int64(n)
+	if err == io.EOF && e.left > 0 {
+		return n, errArchiveChanged
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// isZipStore reports whether ar writes zip archives without
+// compression, whose size can be known in advance.
+func isZipStore(ar archiver.Writer) bool {
+	z, ok := ar.(*archiver.Zip)
+	return ok && z.FileMethod == archiver.Store
+}
+
 func setContentDisposition(w http.ResponseWriter, r *http.Request, file *files.FileInfo) {
 	if r.URL.Query().Get("inline") == "true" {
 		w.Header().Set("Content-Disposition", "inline")
@@ -75,37 +310,76 @@ func setContentDisposition(w http.ResponseWriter, r *http.Request, file *files.F
 	}
 }
 
-var rawHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
-	if !d.token.Perm.Download {
-		return http.StatusAccepted, nil
-	}
+func rawHandler(jobMgr *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.token.Perm.Download {
+			return http.StatusAccepted, nil
+		}
+
+		d, err := archiveData(d, r.URL.Path)
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		file, err := files.NewFileInfo(files.FileOptions{
+			Fs:         d.token.Fs,
+			Path:       r.URL.Path,
+			Modify:     d.token.Perm.Modify,
+			Expand:     false,
+			ReadHeader: d.server.TypeDetectionByHeader,
+			Checker:    d,
+		})
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		if files.IsNamedPipe(file.Mode) {
+			setContentDisposition(w, r, file)
+			return 0, nil
+		}
+
+		if !file.IsDir {
+			return rawFileHandler(w, r, file)
+		}
 
-	file, err := files.NewFileInfo(files.FileOptions{
-		Fs:         d.token.Fs,
-		Path:       r.URL.Path,
-		Modify:     d.token.Perm.Modify,
-		Expand:     false,
-		ReadHeader: d.server.TypeDetectionByHeader,
-		Checker:    d,
+		return rawDirHandler(w, r, d, file, jobMgr)
 	})
+}
+
+// archiveSource describes the files to put in an archive.
+type archiveSource struct {
+	filenames []string
+	commonDir string
+	exclude   []string
+	// skip is the file the archive is built in, if among the files.
+	skip string
+}
+
+func parseQueryArchiveSource(r *http.Request, file *files.FileInfo) (*archiveSource, error) {
+	filenames, err := parseQueryFiles(r, file)
 	if err != nil {
-		return errToStatus(err), err
+		return nil, err
 	}
 
-	if files.IsNamedPipe(file.Mode) {
-		setContentDisposition(w, r, file)
-		return 0, nil
+	exclude, err := parseQueryExclude(r)
+	if err != nil {
+		return nil, err
 	}
 
-	if !file.IsDir {
-		return rawFileHandler(w, r, file)
-	}
+	return &archiveSource{
+		filenames: filenames,
+		commonDir: fileutils.CommonPrefix(filepath.Separator, filenames...),
+		exclude:   exclude,
+	}, nil
+}
 
-	return rawDirHandler(w, r, d, file)
-})
+func addFile(ctx context.Context, ar archiver.Writer, d *data, path string, src *archiveSource, progress fileutils.Progress) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-func addFile(ar archiver.Writer, d *data, path, commonPath string) error {
-	if !d.Check(path) {
+	commonPath := src.commonDir
+	if path == src.skip || !d.Check(path) || isExcluded(src.exclude, path, commonPath) {
 		return nil
 	}
 
@@ -132,11 +406,17 @@ func addFile(ar archiver.Writer, d *data, path, commonPath string) error {
 				FileInfo:   info,
 				CustomName: filename,
 			},
-			ReadCloser: file,
+			ReadCloser: struct {
+				io.Reader
+				io.Closer
+			}{fileutils.NewProgressReader(ctx, file, progress), file},
 		})
 		if err != nil {
 			return err
 		}
+		if progress != nil && !info.IsDir() {
+			progress.AddFiles(1)
+		}
 	}
 
 	if info.IsDir() {
@@ -147,7 +427,10 @@ func addFile(ar archiver.Writer, d *data, path, commonPath string) error {
 
 		for _, name := range names {
 			fPath := filepath.Join(path, name)
-			err = addFile(ar, d, fPath, commonPath)
+			err = addFile(ctx, ar, d, fPath, src, progress)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
 			if err != nil {
 				log.Printf("Failed to archive %s: %v", fPath, err)
 			}
@@ -157,39 +440,76 @@ func addFile(ar archiver.Writer, d *data, path, commonPath string) error {
 	return nil
 }
 
-func rawDirHandler(w http.ResponseWriter, r *http.Request, d *data, file *files.FileInfo) (int, error) {
-	filenames, err := parseQueryFiles(r, file)
+func rawDirHandler(w http.ResponseWriter, r *http.Request, d *data, file *files.FileInfo, jobMgr *jobs.Manager) (int, error) {
+	src, err := parseQueryArchiveSource(r, file)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return errToStatus(err), err
 	}
 
 	extension, ar, err := parseQueryAlgorithm(r)
 	if err != nil {
-		return http.StatusInternalServerError, err
-	}
-
-	err = ar.Create(w)
-	if err != nil {
-		return http.StatusInternalServerError, err
+		return errToStatus(err), err
 	}
-	defer ar.Close()
 
-	commonDir := fileutils.CommonPrefix(filepath.Separator, filenames...)
-
-	name := filepath.Base(commonDir)
+	name := filepath.Base(src.commonDir)
 	if name == "." || name == "" || name == string(filepath.Separator) {
 		name = file.Name
 	}
 	// Prefix used to distinguish a filelist generated
 	// archive from the full directory archive
-	if len(filenames) > 1 {
+	if len(src.filenames) > 1 {
 		name = "_" + name
 	}
 	name += extension
+
+	if isAsync(r) {
+		job := jobMgr.Start(d.token.Session, "archive", func(ctx context.Context, job *jobs.Job) error {
+			reportArchiveTotal(ctx, d, src, job)
+
+			tmpPath, err := archiveToTempFile(ctx, ar, d, src, extension, job) //nolint:govet
+			if err != nil {
+				return err
+			}
+
+			job.SetArtifact(tmpPath, name)
+			return nil
+		})
+
+		return renderJSON(w, r, job.Info())
+	}
+
+	// Stored zip archives have a predictable size, which lets
+	// browsers show the real progress of the download. The files are
+	// listed once, so that the archive has the size announced.
+	var snapshot *zipSnapshot
+	if isZipStore(ar) {
+		snapshot = &zipSnapshot{}
+		if err = writeArchive(r.Context(), snapshot, nil, d, src, nil); err != nil {
+			return errToStatus(err), err
+		}
+		w.Header().Set("Content-Length", strconv.FormatInt(snapshot.Size(), 10))
+	}
+
+	err = ar.Create(w)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer ar.Close()
+
 	w.Header().Set("Content-Disposition", "attachment; filename*=utf-8''"+url.PathEscape(name))
 
-	for _, fname := range filenames {
-		err = addFile(ar, d, fname, commonDir)
+	if snapshot != nil {
+		if err = snapshot.writeTo(r.Context(), ar, d, src); err != nil {
+			// the connection is closed, so that the client doesn't take
+			// the archive for complete
+			log.Printf("Failed to archive %s: %v", src.commonDir, err)
+			panic(http.ErrAbortHandler)
+		}
+		return 0, nil
+	}
+
+	for _, fname := range src.filenames {
+		err = addFile(r.Context(), ar, d, fname, src, nil)
 		if err != nil {
 			log.Printf("Failed to archive %s: %v", fname, err)
 		}
@@ -198,6 +518,116 @@ func rawDirHandler(w http.ResponseWriter, r *http.Request, d *data, file *files.
 	return 0, nil
 }
 
+// reportArchiveTotal counts the work needed to archive src if
+// progress can make use of it.
+func reportArchiveTotal(ctx context.Context, d *data, src *archiveSource, progress fileutils.Progress) {
+	totaler, ok := progress.(fileutils.ProgressTotaler)
+	if !ok {
+		return
+	}
+
+	var totalBytes, totalFiles int64
+	for _, fname := range src.filenames {
+		if bytes, count, err := fileutils.Count(ctx, d.token.Fs, fname); err == nil {
+			totalBytes += bytes
+			totalFiles += count
+		}
+	}
+	totaler.SetTotal(totalBytes, totalFiles)
+}
+
+// compressFiles archives src into dst inside the user scope. The
+// archive is built in a temporary file next to dst and renamed into
+// place, so that a failed or canceled run never leaves a truncated
+// archive behind, nor copies the archive once built.
+func compressFiles(ctx context.Context, ar archiver.Writer, d *data, fileCache FileCache, src *archiveSource,
+	dst string, progress fileutils.Progress) error {
+	reportArchiveTotal(ctx, d, src, progress)
+
+	dir, base := gopath.Split(dst)
+	if err := d.token.Fs.MkdirAll(dir, 0775); err != nil { //nolint:gomnd
+		return err
+	}
+	fd, err := afero.TempFile(d.token.Fs, dir, "."+base+".*")
+	if err != nil {
+		return err
+	}
+	tmpPath := fd.Name()
+	defer func() {
+		if err != nil {
+			_ = d.token.Fs.Remove(tmpPath)
+		}
+	}()
+
+	// the archive may be built in the directory being compressed
+	src.skip = filepath.Clean(tmpPath)
+	err = writeArchive(ctx, ar, fd, d, src, progress)
+	if closeErr := fd.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err = d.token.Fs.Chmod(tmpPath, 0775); err != nil { //nolint:gomnd
+		return err
+	}
+
+	// an overwritten archive is kept as a previous version
+	if _, err = d.token.Fs.Stat(dst); err == nil {
+		if _, err = d.versions().Save(dst); err != nil {
+			return err
+		}
+	}
+
+	if err = d.token.Fs.Rename(tmpPath, dst); err != nil {
+		return err
+	}
+	go delThumbs(context.Background(), fileCache, d.token.Fs, dst)
+
+	return nil
+}
+
+// archiveToTempFile writes the archive to a temporary file and returns
+// its path. The caller is responsible for removing the file.
+func archiveToTempFile(ctx context.Context, ar archiver.Writer, d *data, src *archiveSource,
+	extension string, progress fileutils.Progress) (string, error) {
+	fd, err := os.CreateTemp("", "filebrowser-*"+extension)
+	if err != nil {
+		return "", err
+	}
+
+	err = writeArchive(ctx, ar, fd, d, src, progress)
+	if closeErr := fd.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(fd.Name())
+		return "", err
+	}
+
+	return fd.Name(), nil
+}
+
+func writeArchive(ctx context.Context, ar archiver.Writer, out io.Writer, d *data, src *archiveSource,
+	progress fileutils.Progress) error {
+	if err := ar.Create(out); err != nil {
+		return err
+	}
+
+	for _, fname := range src.filenames {
+		err := addFile(ctx, ar, d, fname, src, progress)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			_ = ar.Close()
+			return ctxErr
+		}
+		if err != nil {
+			log.Printf("Failed to archive %s: %v", fname, err)
+		}
+	}
+
+	return ar.Close()
+}
+
 func rawFileHandler(w http.ResponseWriter, r *http.Request, file *files.FileInfo) (int, error) {
 	fd, err := file.Fs.Open(file.Path)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -11,12 +12,16 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/mholt/archiver/v3"
 	"github.com/spf13/afero"
 
 	"github.com/filebrowser/filebrowser/v2/errors"
 	"github.com/filebrowser/filebrowser/v2/files"
 	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+	"github.com/filebrowser/filebrowser/v2/trash"
 )
 
 var resourceGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
@@ -30,22 +35,35 @@ var resourceGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d
 		return http.StatusBadRequest, nil
 	}
 
+	d, err = archiveData(d, r.URL.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
 	file, err := files.NewFileInfo(files.FileOptions{
 		Fs:         d.token.Fs,
 		Path:       r.URL.Path,
 		Modify:     d.token.Perm.Modify,
 		Expand:     true,
 		ReadHeader: d.server.TypeDetectionByHeader,
+		LazyType:   r.URL.Query().Get("types") == "lazy",
 		Checker:    d,
 		Content:    true,
+		Context:    r.Context(),
 	})
 	if err != nil {
 		return errToStatus(err), err
 	}
 
 	if file.IsDir {
-		file.Listing.Sorting = files.Sorting{By: sortBy, Asc: isAsc}
-		file.Listing.ApplySort()
+		query, err := parseListingQuery(r) //nolint:govet
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		query.Sorting = files.Sorting{By: sortBy, Asc: isAsc}
+		if err := file.Listing.Apply(query); err != nil {
+			return errToStatus(err), err
+		}
 		return renderJSON(w, r, file)
 	}
 
@@ -64,13 +82,62 @@ var resourceGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d
 	return renderJSON(w, r, file)
 })
 
-func resourceDeleteHandler(fileCache FileCache) handleFunc {
+// parseListingQuery parses the filters and the page of a listing:
+// type and ext are comma separated lists, minSize and maxSize are in
+// bytes, after and before are RFC 3339 times, and either offset or the
+// cursor returned with the previous page select the page of limit items.
+// Directories come first unless dirsFirst is false.
+func parseListingQuery(r *http.Request) (files.ListingQuery, error) {
+	values := r.URL.Query()
+	query := files.ListingQuery{
+		DirsFirst:  values.Get("dirsFirst") != "false",
+		Types:      splitList(values.Get("type")),
+		Extensions: splitList(values.Get("ext")),
+		Cursor:     values.Get("cursor"),
+	}
+
+	ints := intQuery{r: r}
+	query.MinSize = ints.int("minSize", 0)
+	query.MaxSize = ints.int("maxSize", 0)
+	query.Offset = int(ints.int("offset", 0))
+	query.Limit = int(ints.int("limit", 0))
+	if ints.err != nil {
+		return query, ints.err
+	}
+	if query.MinSize < 0 || query.MaxSize < 0 || query.Offset < 0 || query.Limit < 0 {
+		return query, errors.ErrInvalidRequestParams
+	}
+
+	for key, t := range map[string]*time.Time{"after": &query.ModifiedAfter, "before": &query.ModifiedBefore} {
+		if value := values.Get(key); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return query, errors.ErrInvalidRequestParams
+			}
+			*t = parsed
+		}
+	}
+
+	return query, nil
+}
+
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func resourceDeleteHandler(fileCache FileCache, jobMgr *jobs.Manager, trashPurger *trash.Purger) handleFunc {
 	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
 		if r.URL.Path == "/" || !d.token.Perm.Delete {
 			return http.StatusForbidden, nil
 		}
 
-		file, err := files.NewFileInfo(files.FileOptions{
+		_, err := files.NewFileInfo(files.FileOptions{
 			Fs:         d.token.Fs,
 			Path:       r.URL.Path,
 			Modify:     d.token.Perm.Modify,
@@ -82,14 +149,44 @@ func resourceDeleteHandler(fileCache FileCache) handleFunc {
 			return errToStatus(err), err
 		}
 
-		// delete thumbnails
-		err = delThumbs(r.Context(), fileCache, file)
-		if err != nil {
+		if !d.settings.Trash.Disabled && r.URL.Query().Get("permanent") != "true" {
+			bin := userTrash(d, trashPurger)
+			err = d.RunHook(func() error {
+				if _, moveErr := bin.Move(r.URL.Path); moveErr != nil {
+					return moveErr
+				}
+				go delThumbs(context.Background(), fileCache, d.token.Fs, r.URL.Path)
+				return nil
+			}, "delete", r.URL.Path, "", d.token)
+
 			return errToStatus(err), err
 		}
 
+		if isAsync(r) {
+			target := r.URL.Path
+			job := jobMgr.Start(d.token.Session, "delete", func(ctx context.Context, job *jobs.Job) error {
+				if bytes, count, err := fileutils.Count(ctx, d.token.Fs, target); err == nil {
+					job.SetTotal(bytes, count)
+				}
+
+				return d.RunHook(func() error {
+					if err := fileutils.RemoveAllContext(ctx, d.token.Fs, target, job); err != nil {
+						return err
+					}
+					delThumbs(context.Background(), fileCache, d.token.Fs, target)
+					return d.versions().Remove(target)
+				}, "delete", target, "", d.token)
+			})
+
+			return renderJSON(w, r, job.Info())
+		}
+
 		err = d.RunHook(func() error {
-			return d.token.Fs.RemoveAll(r.URL.Path)
+			if err := d.token.Fs.RemoveAll(r.URL.Path); err != nil { //nolint:govet
+				return err
+			}
+			go delThumbs(context.Background(), fileCache, d.token.Fs, r.URL.Path)
+			return d.versions().Remove(r.URL.Path)
 		}, "delete", r.URL.Path, "", d.token)
 
 		if err != nil {
@@ -130,9 +227,10 @@ func resourcePostHandler(fileCache FileCache) handleFunc {
 				return http.StatusForbidden, nil
 			}
 
-			err = delThumbs(r.Context(), fileCache, file)
-			if err != nil {
-				return errToStatus(err), err
+			if !file.IsDir {
+				if _, err = d.versions().Save(r.URL.Path); err != nil {
+					return errToStatus(err), err
+				}
 			}
 		}
 
@@ -141,6 +239,7 @@ func resourcePostHandler(fileCache FileCache) handleFunc {
 			if writeErr != nil {
 				return writeErr
 			}
+			go delThumbs(context.Background(), fileCache, d.token.Fs, r.URL.Path)
 
 			etag := fmt.Sprintf(`"%x%x"`, info.ModTime().UnixNano(), info.Size())
 			w.Header().Set("ETag", etag)
@@ -155,39 +254,46 @@ func resourcePostHandler(fileCache FileCache) handleFunc {
 	})
 }
 
-var resourcePutHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
-	if !d.token.Perm.Modify || !d.Check(r.URL.Path) {
-		return http.StatusForbidden, nil
-	}
-
-	// Only allow PUT for files.
-	if strings.HasSuffix(r.URL.Path, "/") {
-		return http.StatusMethodNotAllowed, nil
-	}
+func resourcePutHandler(fileCache FileCache) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.token.Perm.Modify || !d.Check(r.URL.Path) {
+			return http.StatusForbidden, nil
+		}
 
-	exists, err := afero.Exists(d.token.Fs, r.URL.Path)
-	if err != nil {
-		return http.StatusInternalServerError, err
-	}
-	if !exists {
-		return http.StatusNotFound, nil
-	}
+		// Only allow PUT for files.
+		if strings.HasSuffix(r.URL.Path, "/") {
+			return http.StatusMethodNotAllowed, nil
+		}
 
-	err = d.RunHook(func() error {
-		info, writeErr := writeFile(d.token.Fs, r.URL.Path, r.Body)
-		if writeErr != nil {
-			return writeErr
+		exists, err := afero.Exists(d.token.Fs, r.URL.Path)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if !exists {
+			return http.StatusNotFound, nil
 		}
 
-		etag := fmt.Sprintf(`"%x%x"`, info.ModTime().UnixNano(), info.Size())
-		w.Header().Set("ETag", etag)
-		return nil
-	}, "save", r.URL.Path, "", d.token)
+		err = d.RunHook(func() error {
+			if _, saveErr := d.versions().Save(r.URL.Path); saveErr != nil {
+				return saveErr
+			}
 
-	return errToStatus(err), err
-})
+			info, writeErr := writeFile(d.token.Fs, r.URL.Path, r.Body)
+			if writeErr != nil {
+				return writeErr
+			}
+			go delThumbs(context.Background(), fileCache, d.token.Fs, r.URL.Path)
+
+			etag := fmt.Sprintf(`"%x%x"`, info.ModTime().UnixNano(), info.Size())
+			w.Header().Set("ETag", etag)
+			return nil
+		}, "save", r.URL.Path, "", d.token)
+
+		return errToStatus(err), err
+	})
+}
 
-func resourcePatchHandler(fileCache FileCache) handleFunc {
+func resourcePatchHandler(fileCache FileCache, jobMgr *jobs.Manager) handleFunc {
 	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
 		src := r.URL.Path
 		dst := r.URL.Query().Get("destination")
@@ -203,8 +309,37 @@ func resourcePatchHandler(fileCache FileCache) handleFunc {
 			return http.StatusForbidden, nil
 		}
 
-		err = checkParent(src, dst)
-		if err != nil {
+		run := func(ctx context.Context, progress fileutils.Progress) error {
+			return patchAction(ctx, action, src, dst, d, fileCache, progress)
+		}
+
+		if action == "compress" {
+			// The archive is built in a temporary file left out of it,
+			// so it may be placed inside the directory being compressed.
+			if !d.token.Perm.Create {
+				return http.StatusForbidden, nil
+			}
+
+			var ar archiver.Writer
+			var source *archiveSource
+			var extension string
+			if source, err = parseQueryArchiveSource(r, &files.FileInfo{Path: src}); err != nil {
+				return errToStatus(err), err
+			}
+			if extension, ar, err = parseQueryAlgorithm(r); err != nil {
+				return errToStatus(err), err
+			}
+			if !strings.HasSuffix(dst, extension) {
+				dst += extension
+			}
+			if !d.Check(dst) {
+				return http.StatusForbidden, nil
+			}
+
+			run = func(ctx context.Context, progress fileutils.Progress) error {
+				return compressFiles(ctx, ar, d, fileCache, source, dst, progress)
+			}
+		} else if err = checkParent(src, dst); err != nil {
 			return http.StatusBadRequest, err
 		}
 
@@ -225,8 +360,26 @@ func resourcePatchHandler(fileCache FileCache) handleFunc {
 			return http.StatusForbidden, nil
 		}
 
+		if isAsync(r) {
+			job := jobMgr.Start(d.token.Session, action, func(ctx context.Context, job *jobs.Job) error {
+				// The extraction reports the totals of the archive entries
+				// itself, the size of the archive would be the wrong one.
+				if action != "extract" {
+					if bytes, count, err := fileutils.Count(ctx, d.token.Fs, src); err == nil {
+						job.SetTotal(bytes, count)
+					}
+				}
+
+				return d.RunHook(func() error {
+					return run(ctx, job)
+				}, action, src, dst, d.token)
+			})
+
+			return renderJSON(w, r, job.Info())
+		}
+
 		err = d.RunHook(func() error {
-			return patchAction(r.Context(), action, src, dst, d, fileCache)
+			return run(r.Context(), nil)
 		}, action, src, dst, d.token)
 
 		return errToStatus(err), err
@@ -292,18 +445,19 @@ func writeFile(fs afero.Fs, dst string, in io.Reader) (os.FileInfo, error) {
 	return info, nil
 }
 
-func delThumbs(ctx context.Context, fileCache FileCache, file *files.FileInfo) error {
-	for _, previewSizeName := range PreviewSizeNames() {
-		size, _ := ParsePreviewSize(previewSizeName)
-		if err := fileCache.Delete(ctx, previewCacheKey(file, size)); err != nil {
-			return err
-		}
+// delThumbs deletes the previews of the file at name, or of all the files
+// under it if it is a directory, whose keys were recorded in fileCache.
+// It runs once the file is gone or changed, so it logs the errors
+// instead of failing the request.
+func delThumbs(ctx context.Context, fileCache FileCache, fs afero.Fs, name string) {
+	realPath := (&files.FileInfo{Fs: fs, Path: name}).RealPath()
+	if err := fileCache.DeletePath(ctx, realPath); err != nil {
+		log.Printf("failed to delete previews of %s: %v", name, err)
 	}
-
-	return nil
 }
 
-func patchAction(ctx context.Context, action, src, dst string, d *data, fileCache FileCache) error {
+func patchAction(ctx context.Context, action, src, dst string, d *data,
+	fileCache FileCache, progress fileutils.Progress) error {
 	switch action {
 	// TODO: use enum
 	case "copy":
@@ -311,7 +465,12 @@ func patchAction(ctx context.Context, action, src, dst string, d *data, fileCach
 			return errors.ErrPermissionDenied
 		}
 
-		return fileutils.Copy(d.token.Fs, src, dst)
+		if err := fileutils.CopyContext(ctx, d.token.Fs, src, dst, progress); err != nil {
+			return err
+		}
+		// the previews of the files replaced by an override
+		go delThumbs(context.Background(), fileCache, d.token.Fs, dst)
+		return nil
 	case "rename":
 		if !d.token.Perm.Rename {
 			return errors.ErrPermissionDenied
@@ -319,7 +478,7 @@ func patchAction(ctx context.Context, action, src, dst string, d *data, fileCach
 		src = path.Clean("/" + src)
 		dst = path.Clean("/" + dst)
 
-		file, err := files.NewFileInfo(files.FileOptions{
+		_, err := files.NewFileInfo(files.FileOptions{
 			Fs:         d.token.Fs,
 			Path:       src,
 			Modify:     d.token.Perm.Modify,
@@ -331,13 +490,37 @@ func patchAction(ctx context.Context, action, src, dst string, d *data, fileCach
 			return err
 		}
 
-		// delete thumbnails
-		err = delThumbs(ctx, fileCache, file)
+		// the file replaced by an override is kept as a version
+		if _, err = d.versions().Save(dst); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		err = fileutils.MoveFile(d.token.Fs, src, dst)
 		if err != nil {
 			return err
 		}
+		go delThumbs(context.Background(), fileCache, d.token.Fs, src)
+		// the previews of the file replaced by an override
+		go delThumbs(context.Background(), fileCache, d.token.Fs, dst)
 
-		return fileutils.MoveFile(d.token.Fs, src, dst)
+		// previous versions follow the file
+		return d.versions().Move(src, dst)
+	case "extract":
+		if !d.token.Perm.Create {
+			return errors.ErrPermissionDenied
+		}
+
+		return fileutils.Extract(ctx, d.token.Fs, src, dst, fileutils.ExtractOptions{
+			MaxSize:    d.server.ExtractMaxSize,
+			MaxEntries: d.server.ExtractMaxEntries,
+			Checker:    d,
+			Progress:   progress,
+			// the files replaced are kept as versions
+			OnReplace: func(name string) error {
+				_, err := d.versions().Save(name)
+				return err
+			},
+		})
 	default:
 		return fmt.Errorf("unsupported action %s: %w", action, errors.ErrInvalidRequestParams)
 	}
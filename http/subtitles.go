@@ -0,0 +1,80 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path/filepath"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/subtitles"
+)
+
+// maxSubtitlesSize is the size of the largest subtitle file converted.
+const maxSubtitlesSize = 16 << 20
+
+// subtitlesHandler serves a subtitle file as WebVTT, converting SRT,
+// ASS and SSA files and their encoding to UTF-8 on the fly.
+var subtitlesHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.token.Perm.Download {
+		return http.StatusAccepted, nil
+	}
+
+	d, err := archiveData(d, r.URL.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	file, err := files.NewFileInfo(files.FileOptions{
+		Fs:      d.token.Fs,
+		Path:    r.URL.Path,
+		Modify:  d.token.Perm.Modify,
+		Expand:  false,
+		Checker: d,
+	})
+	if err != nil {
+		return errToStatus(err), err
+	}
+	if file.IsDir {
+		return http.StatusBadRequest, errors.ErrIsDirectory
+	}
+	if !subtitles.IsFormat(file.Extension) {
+		return http.StatusBadRequest, errors.ErrUnsupportedSubtitles
+	}
+	if file.Size > maxSubtitlesSize {
+		return http.StatusRequestEntityTooLarge, nil
+	}
+
+	fd, err := d.token.Fs.Open(file.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	defer fd.Close()
+
+	content, err := io.ReadAll(io.LimitReader(fd, maxSubtitlesSize))
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	enc, err := fileutils.DetectTextEncoding(bytes.NewReader(content))
+	if err != nil {
+		return errToStatus(err), err
+	}
+	if content, err = enc.Decode(content); err != nil {
+		return errToStatus(err), err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := subtitles.ToVTT(buf, content, filepath.Ext(file.Name)); err != nil {
+		return errToStatus(err), err
+	}
+
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	w.Header().Set("Cache-Control", "private")
+	if _, err := io.Copy(w, buf); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return 0, nil
+})
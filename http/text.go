@@ -0,0 +1,194 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+)
+
+const (
+	defaultTextLines   = 1000
+	maxTextLines       = 10000
+	maxTextBytes       = 8 << 20
+	maxTextLineBytes   = 1 << 20
+	textFollowInterval = time.Second
+)
+
+// textHandler returns a range of lines of a text file, without loading
+// the whole file. The range is either the last lines (tail=N), the
+// lines from a line number (from=N, to=M) or the lines from a byte
+// offset returned by a previous request (offset=B, lines=N). With
+// follow=true, the lines appended after offset are streamed as
+// Server-Sent Events.
+var textHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.token.Perm.Download {
+		return http.StatusAccepted, nil
+	}
+
+	d, err := archiveData(d, r.URL.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	file, err := files.NewFileInfo(files.FileOptions{
+		Fs:      d.token.Fs,
+		Path:    r.URL.Path,
+		Modify:  d.token.Perm.Modify,
+		Expand:  false,
+		Checker: d,
+	})
+	if err != nil {
+		return errToStatus(err), err
+	}
+	if file.IsDir {
+		return http.StatusBadRequest, errors.ErrIsDirectory
+	}
+
+	fd, err := d.token.Fs.Open(file.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	defer fd.Close()
+
+	enc, err := fileutils.DetectTextEncoding(fd)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	if name := r.URL.Query().Get("encoding"); name != "" {
+		if enc, err = fileutils.TextEncodingByName(name, enc.BOM); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+
+	query := intQuery{r: r}
+	tail := query.int("tail", 0)
+	from := query.int("from", 1)
+	to := query.int("to", 0)
+	offset := query.int("offset", -1)
+	lines := query.int("lines", defaultTextLines)
+	if query.err != nil {
+		return http.StatusBadRequest, query.err
+	}
+
+	if r.URL.Query().Get("follow") == "true" {
+		return followText(w, r, d.token.Fs, file.Path, enc, offset)
+	}
+
+	opts := fileutils.TextOptions{
+		MaxLines:     int(lines),
+		MaxBytes:     maxTextBytes,
+		MaxLineBytes: maxTextLineBytes,
+		Partial:      true,
+	}
+
+	switch {
+	case tail > 0:
+		opts.MaxLines = int(tail)
+		offset, err = enc.TailOffset(fd, file.Size, int(tail))
+		if err != nil {
+			return errToStatus(err), err
+		}
+	case offset >= 0:
+	default:
+		if from < 1 || (to != 0 && to < from) {
+			return http.StatusBadRequest, errors.ErrInvalidRequestParams
+		}
+		offset = 0
+		opts.Skip = from - 1
+		if to != 0 {
+			opts.MaxLines = int(to - from + 1)
+		}
+	}
+	if opts.MaxLines < 1 || opts.MaxLines > maxTextLines {
+		opts.MaxLines = maxTextLines
+	}
+
+	rng, err := enc.ReadLines(fd, file.Size, offset, opts)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	if tail == 0 && offset == 0 {
+		rng.From = from
+	}
+
+	return renderJSON(w, r, rng)
+})
+
+// followText streams the lines appended to the file after offset, or
+// after its current end if offset is negative. A "lines" event is sent
+// for each batch of new lines and a "truncate" event when the file
+// shrinks, after which it is read again from the start.
+func followText(w http.ResponseWriter, r *http.Request, fs afero.Fs, name string,
+	enc *fileutils.TextEncoding, offset int64) (int, error) {
+	stream, err := newEventStream(w)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	opts := fileutils.TextOptions{
+		MaxLines:     maxTextLines,
+		MaxBytes:     maxTextBytes,
+		MaxLineBytes: maxTextLineBytes,
+	}
+
+	ticker := time.NewTicker(textFollowInterval)
+	defer ticker.Stop()
+
+	for {
+		info, err := fs.Stat(name)
+		if err != nil {
+			_ = stream.send("error", err.Error())
+			return 0, nil
+		}
+
+		size := info.Size()
+		switch {
+		case offset < 0:
+			offset = size
+		case size < offset:
+			offset = 0
+			if err = stream.send("truncate", size); err != nil {
+				return 0, nil
+			}
+		}
+
+		for size > offset {
+			rng, err := readTextRange(fs, name, enc, size, offset, opts) //nolint:govet
+			if err != nil {
+				_ = stream.send("error", err.Error())
+				return 0, nil
+			}
+			if len(rng.Lines) == 0 {
+				break
+			}
+			if err = stream.send("lines", rng); err != nil {
+				return 0, nil
+			}
+			offset = rng.NextOffset
+		}
+
+		select {
+		case <-r.Context().Done():
+			return 0, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// readTextRange opens the file for every read, so that a file that
+// was replaced, for instance by log rotation, is followed by name.
+func readTextRange(fs afero.Fs, name string, enc *fileutils.TextEncoding, size, offset int64,
+	opts fileutils.TextOptions) (*fileutils.TextRange, error) {
+	fd, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	return enc.ReadLines(fd, size, offset, opts)
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/filebrowser/filebrowser/v2/jobs"
+	"github.com/filebrowser/filebrowser/v2/trash"
+)
+
+// userTrash returns the trash of the current user, which trashPurger
+// purges from then on along with the versions.
+func userTrash(d *data, trashPurger *trash.Purger) *trash.Trash {
+	if err := trashPurger.Track(d.token.Scope); err != nil {
+		log.Printf("failed to track trash of %q: %v", d.token.Scope, err)
+	}
+	return trash.New(d.token.Fs)
+}
+
+func trashListHandler(trashPurger *trash.Purger) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		items, err := userTrash(d, trashPurger).List()
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		return renderJSON(w, r, items)
+	})
+}
+
+func trashRestoreHandler(trashPurger *trash.Purger) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.token.Perm.Create {
+			return http.StatusForbidden, nil
+		}
+
+		bin := userTrash(d, trashPurger)
+		item, err := bin.Get(mux.Vars(r)["id"])
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		dst := item.Path
+		if !d.Check(dst) {
+			return http.StatusForbidden, nil
+		}
+
+		if r.URL.Query().Get("rename") == "true" {
+			dst = addVersionSuffix(dst, d.token.Fs)
+		}
+
+		err = d.RunHook(func() error {
+			return bin.Restore(item.ID, dst)
+		}, "restore", dst, "", d.token)
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		return renderJSON(w, r, map[string]string{"path": dst})
+	})
+}
+
+func trashDeleteHandler(trashPurger *trash.Purger) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.token.Perm.Delete {
+			return http.StatusForbidden, nil
+		}
+
+		err := userTrash(d, trashPurger).Delete(r.Context(), mux.Vars(r)["id"], nil)
+		return errToStatus(err), err
+	})
+}
+
+func trashEmptyHandler(jobMgr *jobs.Manager, trashPurger *trash.Purger) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.token.Perm.Delete {
+			return http.StatusForbidden, nil
+		}
+
+		bin := userTrash(d, trashPurger)
+		if isAsync(r) {
+			job := jobMgr.Start(d.token.Session, "empty-trash", func(ctx context.Context, job *jobs.Job) error {
+				return bin.Empty(ctx, job)
+			})
+
+			return renderJSON(w, r, job.Info())
+		}
+
+		err := bin.Empty(r.Context(), nil)
+		return errToStatus(err), err
+	})
+}
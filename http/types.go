@@ -0,0 +1,64 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+const typesBatchSize = 100
+
+// typesHandler streams, as Server-Sent Events, the types of the entries
+// of a directory whose listing was requested with types=lazy, once their
+// first bytes were read. Each "types" event maps names to types and a
+// "done" event ends the stream.
+var typesHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	d, err := archiveData(d, r.URL.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	file, err := files.NewFileInfo(files.FileOptions{
+		Fs:         d.token.Fs,
+		Path:       r.URL.Path,
+		Modify:     d.token.Perm.Modify,
+		Expand:     true,
+		ReadHeader: d.server.TypeDetectionByHeader,
+		LazyType:   true,
+		Checker:    d,
+		Context:    r.Context(),
+	})
+	if err != nil {
+		return errToStatus(err), err
+	}
+	if !file.IsDir {
+		return http.StatusBadRequest, errors.ErrInvalidRequestParams
+	}
+
+	stream, err := newEventStream(w)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	batch := map[string]string{}
+	var sendErr error
+	err = file.Listing.DetectTypes(r.Context(), func(item *files.FileInfo) {
+		batch[item.Name] = item.Type
+		if len(batch) >= typesBatchSize && sendErr == nil {
+			sendErr = stream.send("types", batch)
+			batch = map[string]string{}
+		}
+	})
+	if err != nil || sendErr != nil {
+		return 0, nil
+	}
+
+	if len(batch) > 0 {
+		if err := stream.send("types", batch); err != nil {
+			return 0, nil
+		}
+	}
+	_ = stream.send("done", nil)
+	return 0, nil
+})
@@ -8,9 +8,11 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 
 	libErrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/img"
 )
 
 func renderJSON(w http.ResponseWriter, _ *http.Request, data interface{}) (int, error) {
@@ -46,6 +48,11 @@ func errToStatus(err error) int {
 		return http.StatusForbidden
 	case errors.Is(err, syscall.ENAMETOOLONG):
 		return http.StatusBadRequest
+	case errors.Is(err, libErrors.ErrUnsupportedArchive), errors.Is(err, libErrors.ErrInvalidArchiveEntry),
+		errors.Is(err, libErrors.ErrUnsupportedSubtitles):
+		return http.StatusBadRequest
+	case errors.Is(err, libErrors.ErrArchiveLimit), errors.Is(err, img.ErrImageTooLarge):
+		return http.StatusRequestEntityTooLarge
 	default:
 		return http.StatusInternalServerError
 	}
@@ -70,3 +77,24 @@ func stripPrefix(prefix string, h http.Handler) http.Handler {
 		h.ServeHTTP(w, r2)
 	})
 }
+
+// intQuery parses integer query parameters, keeping the first error.
+type intQuery struct {
+	r   *http.Request
+	err error
+}
+
+func (q *intQuery) int(key string, def int64) int64 {
+	value := q.r.URL.Query().Get(key)
+	if value == "" || q.err != nil {
+		return def
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		q.err = libErrors.ErrInvalidRequestParams
+		return def
+	}
+
+	return n
+}
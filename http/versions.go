@@ -0,0 +1,133 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// maxDiffSize is the biggest file, in bytes, that can be diffed.
+const maxDiffSize = 10 * 1024 * 1024 // 10 MB
+
+var versionsGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	file, err := files.NewFileInfo(files.FileOptions{
+		Fs:         d.token.Fs,
+		Path:       r.URL.Path,
+		Modify:     d.token.Perm.Modify,
+		Expand:     false,
+		ReadHeader: false,
+		Checker:    d,
+	})
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if file.IsDir {
+		return http.StatusBadRequest, errors.ErrIsDirectory
+	}
+
+	store := d.versions()
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		list, err := store.List(file.Path) //nolint:govet
+		if err != nil {
+			return errToStatus(err), err
+		}
+		return renderJSON(w, r, list)
+	}
+
+	if !d.token.Perm.Download {
+		return http.StatusAccepted, nil
+	}
+
+	fd, err := store.Open(file.Path, id)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	defer fd.Close()
+
+	if r.URL.Query().Get("diff") == "true" {
+		return versionDiffHandler(w, file, id, fd)
+	}
+
+	info, err := fd.Stat()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.Header().Set("Content-Disposition", "attachment; filename*=utf-8''"+url.PathEscape(file.Name))
+	w.Header().Set("Cache-Control", "private")
+	http.ServeContent(w, r, file.Name, info.ModTime(), fd)
+	return 0, nil
+})
+
+// versionDiffHandler writes an unified diff between a version and
+// the current content of the file.
+func versionDiffHandler(w http.ResponseWriter, file *files.FileInfo, id string, version afero.File) (int, error) {
+	info, err := version.Stat()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if info.Size() > maxDiffSize || file.Size > maxDiffSize {
+		return http.StatusRequestEntityTooLarge, nil
+	}
+
+	old, err := io.ReadAll(version)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	current, err := afero.ReadFile(file.Fs, file.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(old)),
+		B:        difflib.SplitLines(string(current)),
+		FromFile: file.Path + "@" + id,
+		ToFile:   file.Path,
+		Context:  3, //nolint:gomnd
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := io.WriteString(w, diff); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return 0, nil
+}
+
+func versionRestoreHandler(fileCache FileCache) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.token.Perm.Modify || !d.Check(r.URL.Path) {
+			return http.StatusForbidden, nil
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			return http.StatusBadRequest, errors.ErrInvalidRequestParams
+		}
+
+		err := d.RunHook(func() error {
+			if restoreErr := d.versions().Restore(r.URL.Path, id); restoreErr != nil {
+				return restoreErr
+			}
+			go delThumbs(context.Background(), fileCache, d.token.Fs, r.URL.Path)
+			return nil
+		}, "save", r.URL.Path, "", d.token)
+
+		return errToStatus(err), err
+	})
+}
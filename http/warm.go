@@ -0,0 +1,263 @@
+package http
+
+import (
+	"context"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/filetype"
+	"github.com/filebrowser/filebrowser/v2/preview"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/storage"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+// warmPriority is the priority of the resizes of the warmed previews,
+// which the requested ones overtake.
+const warmPriority = -1
+
+// DefaultWarmPresets are the presets whose previews are warmed, with
+// the @2x ones requested by the browsers of high density screens.
+var DefaultWarmPresets = []string{"thumb", "thumb@2x", "big", "big@2x"}
+
+// WarmStats counts the files a warming went through.
+type WarmStats struct {
+	// Warmed is the number of previews made.
+	Warmed int
+	// Skipped is the number of previews found in the cache, or that
+	// are the images themselves.
+	Skipped int
+	// Failed is the number of previews that couldn't be made.
+	Failed int
+}
+
+// PreviewWarmer makes the previews of the images before they are first
+// requested, as the preview handler would make them for a browser.
+type PreviewWarmer struct {
+	imgSvc       ImgService
+	fileCache    FileCache
+	previewGroup *preview.Group
+	store        *storage.Storage
+	server       *settings.Server
+	presets      []string
+	// interval is the least time between the resizes of two files, so
+	// that the warming doesn't starve the requests.
+	interval time.Duration
+}
+
+// NewPreviewWarmer creates a warmer making the previews of the presets,
+// DefaultWarmPresets if empty, of at most rate files per second. A rate
+// of 0 doesn't limit the warming. The previews being made by the
+// handler too are made once if they share previewGroup.
+func NewPreviewWarmer(imgSvc ImgService, fileCache FileCache, previewGroup *preview.Group,
+	store *storage.Storage, server *settings.Server, presets []string, rate float64) *PreviewWarmer {
+	if len(presets) == 0 {
+		presets = DefaultWarmPresets
+	}
+
+	var interval time.Duration
+	if rate > 0 {
+		interval = time.Duration(float64(time.Second) / rate)
+	}
+
+	return &PreviewWarmer{
+		imgSvc:       imgSvc,
+		fileCache:    fileCache,
+		previewGroup: previewGroup,
+		store:        store,
+		server:       server,
+		presets:      presets,
+		interval:     interval,
+	}
+}
+
+// Warm makes the missing previews of the images under scope, a path
+// relative to the root, modified after since.
+func (pw *PreviewWarmer) Warm(ctx context.Context, scope string, since time.Time) (WarmStats, error) {
+	return pw.warm(ctx, scope, func(name string, info os.FileInfo) bool {
+		return info.ModTime().After(since)
+	})
+}
+
+// warm makes the missing previews of the images under scope for which
+// visit returns true.
+func (pw *PreviewWarmer) warm(ctx context.Context, scope string,
+	visit func(name string, info os.FileInfo) bool) (WarmStats, error) {
+	var stats WarmStats
+
+	s, err := pw.store.Settings.Get()
+	if err != nil {
+		return stats, err
+	}
+	// the rules of the users don't apply, but the global ones do
+	checker := &data{settings: s, token: &users.TokenStruct{}}
+
+	var presets []settings.PreviewPreset
+	for _, name := range pw.presets {
+		if preset, ok := s.Previews.Preset(name); ok {
+			presets = append(presets, preset)
+		}
+	}
+
+	var last time.Time
+	fs := afero.NewBasePathFs(afero.NewOsFs(), pw.server.Root)
+	err = afero.Walk(fs, path.Clean("/"+scope), func(name string, info os.FileInfo, err error) error {
+		// the files that can't be read don't stop the warming of the
+		// others
+		if err != nil {
+			log.Printf("warm %s: %v", name, err)
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name = filepath.ToSlash(name)
+		if !checker.Check(name) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || !visit(name, info) {
+			return nil
+		}
+
+		file, err := files.NewFileInfo(files.FileOptions{
+			Fs:         fs,
+			Path:       name,
+			Expand:     true,
+			ReadHeader: pw.server.TypeDetectionByHeader,
+			Checker:    checker,
+		})
+		if err != nil {
+			log.Printf("warm %s: %v", name, err)
+			return nil
+		}
+		if filetype.Category(file.Category) != filetype.Image {
+			return nil
+		}
+
+		for _, preset := range presets {
+			skipped, err := pw.warmPreview(ctx, &s.Previews, file, preset, &last)
+			switch {
+			case ctx.Err() != nil:
+				return ctx.Err()
+			case err != nil:
+				stats.Failed++
+				log.Printf("warm %s preview of %s: %v", preset.Name, name, err)
+			case skipped:
+				stats.Skipped++
+			default:
+				stats.Warmed++
+			}
+		}
+		return nil
+	})
+
+	return stats, err
+}
+
+// warmPreview makes the preview of file at preset, and skips it if it
+// is cached or if the file is its own preview. It waits for the interval since last
+// before resizing.
+func (pw *PreviewWarmer) warmPreview(ctx context.Context, previews *settings.Previews, file *files.FileInfo,
+	preset settings.PreviewPreset, last *time.Time) (skipped bool, err error) {
+	format, resize, err := imagePreviewSource(pw.imgSvc, file, preset, pw.server.EnableThumbnails, pw.server.ResizePreview)
+	if err != nil || !resize {
+		return true, err
+	}
+
+	// the browsers all accept webp
+	previewFormat := previewFormat(pw.imgSvc, format, preset, true)
+	cacheKey, err := previewKey(ctx, pw.fileCache, previews, file, preset, previewFormat)
+	if err != nil {
+		return false, err
+	}
+	if _, ok, err := pw.fileCache.Load(ctx, cacheKey); err != nil || ok { //nolint:govet
+		return ok, err
+	}
+
+	if wait := time.Until(last.Add(pw.interval)); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	defer func() { *last = time.Now() }()
+
+	_, err = pw.previewGroup.Do(ctx, cacheKey, func(ctx context.Context) ([]byte, error) {
+		resized, err := createPreview(ctx, pw.imgSvc, file, preset, previewFormat, warmPriority) //nolint:govet
+		if err != nil {
+			return nil, err
+		}
+		return resized, cachePreview(ctx, pw.fileCache, previews, file, cacheKey, resized)
+	})
+	return false, err
+}
+
+// fileStamp tells whether a file changed since it was warmed.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func (s fileStamp) equal(other fileStamp) bool {
+	return s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+// Run warms the previews of the files under the scopes every interval,
+// or once if interval isn't positive, until ctx is done. The first run
+// goes through all the files, the next ones through the files that are
+// new or changed since, including the ones copied with their
+// modification time.
+func (pw *PreviewWarmer) Run(ctx context.Context, scopes []string, interval time.Duration) {
+	var ticks <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	seen := map[string]map[string]fileStamp{}
+	for {
+		for _, scope := range scopes {
+			// the files gone since the previous run are forgotten
+			last, current := seen[scope], map[string]fileStamp{}
+			stats, err := pw.warm(ctx, scope, func(name string, info os.FileInfo) bool {
+				stamp := fileStamp{size: info.Size(), modTime: info.ModTime()}
+				current[name] = stamp
+				previous, ok := last[name]
+				return !ok || !previous.equal(stamp)
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Printf("warm previews of %s: %v", scope, err)
+				continue
+			}
+			seen[scope] = current
+			if stats.Warmed > 0 || stats.Failed > 0 {
+				log.Printf("warmed %d previews of %s, %d failed", stats.Warmed, scope, stats.Failed)
+			}
+		}
+
+		if ticks == nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks:
+		}
+	}
+}
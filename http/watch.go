@@ -0,0 +1,77 @@
+package http
+
+import (
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/watch"
+)
+
+const watchPingInterval = 30 * time.Second
+
+// watchHandler streams the changes of the entries of a directory as
+// Server-Sent Events. The changes of the entries denied by the rules
+// are left out.
+func watchHandler(hub *watch.Hub) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if hub == nil {
+			return http.StatusNotImplemented, nil
+		}
+
+		file, err := files.NewFileInfo(files.FileOptions{
+			Fs:      d.token.Fs,
+			Path:    r.URL.Path,
+			Modify:  d.token.Perm.Modify,
+			Expand:  false,
+			Checker: d,
+		})
+		if err != nil {
+			return errToStatus(err), err
+		}
+		if !file.IsDir {
+			return http.StatusBadRequest, errors.ErrInvalidRequestParams
+		}
+
+		root := (&files.FileInfo{Fs: d.token.Fs, Path: "/"}).RealPath()
+		sub, err := hub.Subscribe(file.RealPath(), func(name string) (string, bool) {
+			rel, err := filepath.Rel(root, name) //nolint:govet
+			if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+				return "", false
+			}
+			p := path.Clean("/" + filepath.ToSlash(rel))
+			return p, d.Check(p)
+		})
+		if err != nil {
+			return errToStatus(err), err
+		}
+		defer sub.Close()
+
+		stream, err := newEventStream(w)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		ticker := time.NewTicker(watchPingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return 0, nil
+			case batch := <-sub.Events():
+				if err := stream.send("change", batch); err != nil {
+					return 0, nil
+				}
+			case <-ticker.C:
+				if err := stream.send("ping", nil); err != nil {
+					return 0, nil
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,283 @@
+package http
+
+import (
+	"context"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/trash"
+)
+
+const davPrefix = "/dav"
+
+// davLocks holds a lock system for each scope, so that users sharing
+// a path name in different scopes don't lock each other.
+type davLocks struct {
+	mu    sync.Mutex
+	locks map[string]webdav.LockSystem
+}
+
+func (l *davLocks) get(scope string) webdav.LockSystem {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ls, ok := l.locks[scope]
+	if !ok {
+		ls = webdav.NewMemLS()
+		l.locks[scope] = ls
+	}
+
+	return ls
+}
+
+// davHandler serves the user scope over WebDAV. Clients authenticate
+// with HTTP Basic auth where the username is the session id and the
+// password is the API token, or with the regular X-Auth and
+// X-Session-Id headers. Like for the API, the session only works from
+// the IP address and with the User-Agent it was created with: a client
+// can't use the session of a browser, it must log in itself.
+func davHandler(fileCache FileCache, trashPurger *trash.Purger) handleFunc {
+	locks := &davLocks{locks: map[string]webdav.LockSystem{}}
+
+	serve := withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if (r.Method == http.MethodGet || r.Method == http.MethodHead) && !d.token.Perm.Download {
+			return http.StatusForbidden, nil
+		}
+
+		// The router strips the base URL, but WebDAV needs it to build
+		// the hrefs of the responses and to parse the destinations.
+		r.URL.Path = d.server.BaseURL + r.URL.Path
+		r.URL.RawPath = ""
+
+		handler := &webdav.Handler{
+			Prefix:     d.server.BaseURL + davPrefix,
+			FileSystem: &davFS{d: d, fileCache: fileCache, trashPurger: trashPurger},
+			LockSystem: locks.get(d.token.Scope),
+			Logger: func(r *http.Request, err error) {
+				if err != nil {
+					log.Printf("WebDAV %s %s: %v", r.Method, r.URL.Path, err)
+				}
+			},
+		}
+		handler.ServeHTTP(w, r)
+
+		return 0, nil
+	})
+
+	return func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if sessionID, token, ok := r.BasicAuth(); ok {
+			r.Header.Set("X-Auth", token)
+			r.Header.Set("X-Session-Id", sessionID)
+		}
+
+		status, err := serve(w, r, d)
+		if status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", `Basic realm="File Browser"`)
+		}
+
+		return status, err
+	}
+}
+
+// davFS is a webdav.FileSystem over the user scope that enforces the
+// user permissions and rules, and runs the event hooks.
+type davFS struct {
+	d           *data
+	fileCache   FileCache
+	trashPurger *trash.Purger
+}
+
+func (fs *davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	name = slashClean(name)
+	if !fs.d.token.Perm.Create || !fs.d.Check(name) {
+		return os.ErrPermission
+	}
+
+	return fs.d.token.Fs.Mkdir(name, perm)
+}
+
+func (fs *davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	name = slashClean(name)
+	if !fs.d.Check(name) {
+		return nil, os.ErrNotExist
+	}
+
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		file, err := fs.d.token.Fs.OpenFile(name, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		// the files are opened to list them too, so the ones the user
+		// can't download are opened but not read, whatever the method
+		return &davFile{File: file, fs: fs, name: name, noRead: !fs.d.token.Perm.Download}, nil
+	}
+
+	evt := "upload"
+	info, err := fs.d.token.Fs.Stat(name)
+	switch {
+	case err == nil:
+		if !fs.d.token.Perm.Modify {
+			return nil, os.ErrPermission
+		}
+		if info.IsDir() {
+			return nil, os.ErrInvalid
+		}
+		evt = "save"
+		if flag&os.O_TRUNC != 0 {
+			if _, err = fs.d.versions().Save(name); err != nil { //nolint:govet
+				return nil, err
+			}
+		}
+	case os.IsNotExist(err):
+		if !fs.d.token.Perm.Create {
+			return nil, os.ErrPermission
+		}
+	default:
+		return nil, err
+	}
+
+	if err := fs.d.RunBefore(evt, name, "", fs.d.token); err != nil { //nolint:govet
+		return nil, err
+	}
+
+	file, err := fs.d.token.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &davFile{File: file, fs: fs, name: name, evt: evt}, nil
+}
+
+func (fs *davFS) RemoveAll(ctx context.Context, name string) error {
+	name = slashClean(name)
+	if name == "/" || !fs.d.token.Perm.Delete || !fs.d.Check(name) {
+		return os.ErrPermission
+	}
+
+	return fs.d.RunHook(func() error {
+		if !fs.d.settings.Trash.Disabled {
+			if _, err := userTrash(fs.d, fs.trashPurger).Move(name); err != nil {
+				return err
+			}
+			fs.delThumbs(name)
+			return nil
+		}
+		if err := fs.d.token.Fs.RemoveAll(name); err != nil {
+			return err
+		}
+		fs.delThumbs(name)
+		return fs.d.versions().Remove(name)
+	}, "delete", name, "", fs.d.token)
+}
+
+func (fs *davFS) Rename(ctx context.Context, oldName, newName string) error {
+	oldName, newName = slashClean(oldName), slashClean(newName)
+	if oldName == "/" || newName == "/" || !fs.d.token.Perm.Rename {
+		return os.ErrPermission
+	}
+	if !fs.d.Check(oldName) || !fs.d.Check(newName) {
+		return os.ErrPermission
+	}
+
+	return fs.d.RunHook(func() error {
+		if err := fileutils.MoveFile(fs.d.token.Fs, oldName, newName); err != nil {
+			return err
+		}
+		fs.delThumbs(oldName)
+		return fs.d.versions().Move(oldName, newName)
+	}, "rename", oldName, newName, fs.d.token)
+}
+
+func (fs *davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	name = slashClean(name)
+	if !fs.d.Check(name) {
+		return nil, os.ErrNotExist
+	}
+
+	return fs.d.token.Fs.Stat(name)
+}
+
+// delThumbs deletes the previews of name in the background.
+func (fs *davFS) delThumbs(name string) {
+	go delThumbs(context.Background(), fs.fileCache, fs.d.token.Fs, name)
+}
+
+// davFile hides the entries denied by the rules from directory
+// listings and runs the after hooks once a written file is closed.
+type davFile struct {
+	webdav.File
+	fs   *davFS
+	name string
+	evt  string
+	// noRead denies reading the contents of the file.
+	noRead bool
+}
+
+func (f *davFile) Read(p []byte) (int, error) {
+	if f.noRead {
+		return 0, os.ErrPermission
+	}
+	return f.File.Read(p)
+}
+
+func (f *davFile) Stat() (os.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil || !f.noRead || info.IsDir() {
+		return info, err
+	}
+	return davFileInfo{FileInfo: info}, nil
+}
+
+func (f *davFile) Readdir(count int) ([]os.FileInfo, error) {
+	infos, err := f.File.Readdir(count)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := infos[:0]
+	for _, info := range infos {
+		if f.fs.d.Check(path.Join(f.name, info.Name())) {
+			allowed = append(allowed, info)
+		}
+	}
+
+	return allowed, nil
+}
+
+func (f *davFile) Close() error {
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+
+	if f.evt == "" {
+		return nil
+	}
+	// the previews of the saved file are made again from its contents
+	if f.evt == "save" {
+		f.fs.delThumbs(f.name)
+	}
+
+	return f.fs.d.RunAfter(f.evt, f.name, "", f.fs.d.token)
+}
+
+// davFileInfo describes a file that can't be read, whose content type
+// is found from its extension instead of its contents.
+type davFileInfo struct {
+	os.FileInfo
+}
+
+// ContentType implements webdav.ContentTyper.
+func (fi davFileInfo) ContentType(ctx context.Context) (string, error) {
+	if ctype := mime.TypeByExtension(filepath.Ext(fi.Name())); ctype != "" {
+		return ctype, nil
+	}
+	return "application/octet-stream", nil
+}
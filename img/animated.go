@@ -0,0 +1,205 @@
+package img
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+
+	"github.com/disintegration/imaging"
+)
+
+// maxAnimatedSize is the size of the largest GIF resized with all its
+// frames.
+const maxAnimatedSize = 64 << 20
+
+// gifLayout is what scanGIF learns about a GIF without decoding it.
+type gifLayout struct {
+	width, height int
+	frames        int
+	// framePixels is the sum of the pixels of all the frames.
+	framePixels int64
+}
+
+// ResizeAnimated resizes all the frames of an animated GIF to width and
+// height, and encodes them to a GIF. The mode and quality options apply,
+// the format is always gif.
+func (s *Service) ResizeAnimated(ctx context.Context, in io.Reader, width, height int,
+	out io.Writer, options ...Option) error {
+	config := resizeConfig{
+		resizeMode: ResizeModeFit,
+		quality:    QualityMedium,
+	}
+	for _, option := range options {
+		option(&config)
+	}
+
+	if err := s.queue.acquire(ctx, config.priority); err != nil {
+		return err
+	}
+	defer s.queue.release()
+
+	data, err := io.ReadAll(io.LimitReader(in, maxAnimatedSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxAnimatedSize {
+		return fmt.Errorf("animated image over %d MiB: %w", maxAnimatedSize>>20, ErrImageTooLarge)
+	}
+
+	// the frames are all decoded at once, so they are counted first
+	layout, err := scanGIF(data)
+	if err != nil {
+		return err
+	}
+	canvasPixels := int64(layout.width) * int64(layout.height)
+	if pixels := canvasPixels + layout.framePixels; pixels > int64(s.maxPixels) {
+		return fmt.Errorf("%d frames of %dx%d pixels: %w", layout.frames, layout.width, layout.height, ErrImageTooLarge)
+	}
+	// the frames take a byte per pixel and the canvas is RGBA
+	release, err := s.reserveMemory(ctx, layout.framePixels+canvasPixels*bytesPerPixel)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	src, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", err.Error(), ErrUnsupportedFormat)
+	}
+
+	dst, err := resizeFrames(ctx, src, width, height, config)
+	if err != nil {
+		return err
+	}
+
+	return gif.EncodeAll(out, dst)
+}
+
+// resizeFrames draws the frames of src on a canvas, as their disposal
+// methods say, and resizes the canvas after each frame.
+func resizeFrames(ctx context.Context, src *gif.GIF, width, height int, config resizeConfig) (*gif.GIF, error) {
+	bounds := image.Rect(0, 0, src.Config.Width, src.Config.Height)
+	canvas := image.NewRGBA(bounds)
+	var previous *image.RGBA
+
+	dst := &gif.GIF{
+		Image:     make([]*image.Paletted, 0, len(src.Image)),
+		Delay:     src.Delay,
+		LoopCount: src.LoopCount,
+	}
+	for i, frame := range src.Image {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		disposal := byte(0)
+		if i < len(src.Disposal) {
+			disposal = src.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		var resized *image.NRGBA
+		switch config.resizeMode {
+		case ResizeModeFill:
+			resized = imaging.Fill(canvas, width, height, imaging.Center, config.quality.resampleFilter())
+		default:
+			resized = imaging.Fit(canvas, width, height, config.quality.resampleFilter())
+		}
+
+		paletted := image.NewPaletted(resized.Bounds(), framePalette(frame))
+		draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), resized, image.Point{})
+		dst.Image = append(dst.Image, paletted)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return dst, nil
+}
+
+// framePalette returns the palette of a frame, with a transparent color
+// so that the resized frames keep the transparency of the canvas.
+func framePalette(frame *image.Paletted) color.Palette {
+	for _, c := range frame.Palette {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			return frame.Palette
+		}
+	}
+	if len(frame.Palette) < 256 { //nolint:gomnd
+		return append(append(color.Palette{}, frame.Palette...), color.Transparent)
+	}
+	return frame.Palette
+}
+
+// scanGIF walks the blocks of a GIF to count its frames and their pixels
+// without decompressing them.
+func scanGIF(data []byte) (gifLayout, error) {
+	errFormat := fmt.Errorf("invalid GIF: %w", ErrUnsupportedFormat)
+
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") { //nolint:gomnd
+		return gifLayout{}, errFormat
+	}
+	layout := gifLayout{
+		width:  int(binary.LittleEndian.Uint16(data[6:8])),
+		height: int(binary.LittleEndian.Uint16(data[8:10])),
+	}
+
+	pos := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << ((flags & 0x07) + 1)
+	}
+
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // extension
+			pos += 2
+		case 0x2C: // image descriptor
+			if pos+10 > len(data) {
+				return gifLayout{}, errFormat
+			}
+			w := binary.LittleEndian.Uint16(data[pos+5:])
+			h := binary.LittleEndian.Uint16(data[pos+7:])
+			layout.frames++
+			layout.framePixels += int64(w) * int64(h)
+
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << ((flags & 0x07) + 1)
+			}
+			// LZW minimum code size
+			pos++
+		case 0x3B: // trailer
+			return layout, nil
+		default:
+			return gifLayout{}, errFormat
+		}
+
+		// data sub-blocks
+		for pos < len(data) && data[pos] != 0 {
+			pos += int(data[pos]) + 1
+		}
+		pos++
+	}
+
+	// some GIFs lack the trailer
+	if layout.frames == 0 {
+		return gifLayout{}, errFormat
+	}
+	return layout, nil
+}
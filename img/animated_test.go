@@ -0,0 +1,190 @@
+package img
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newAnimatedGif creates a GIF whose frames are each filled with one of
+// the colors.
+func newAnimatedGif(t *testing.T, width, height int, colors ...color.Color) []byte {
+	t.Helper()
+
+	palette := color.Palette{color.Transparent}
+	anim := &gif.GIF{}
+	for _, c := range colors {
+		palette = append(palette, c)
+	}
+	for i := range colors {
+		frame := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+		for j := range frame.Pix {
+			frame.Pix[j] = uint8(i + 1)
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10) //nolint:gomnd
+		anim.Disposal = append(anim.Disposal, gif.DisposalNone)
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, gif.EncodeAll(buf, anim))
+	return buf.Bytes()
+}
+
+// newAnimatedPng creates an APNG whose frames are each filled with one
+// of the colors, the first one being its default image.
+func newAnimatedPng(t *testing.T, width, height int, colors ...color.Color) []byte {
+	t.Helper()
+
+	var frames [][]byte
+	for _, c := range colors {
+		frame := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(frame, frame.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+		buf := &bytes.Buffer{}
+		require.NoError(t, png.Encode(buf, frame))
+		frames = append(frames, buf.Bytes())
+	}
+
+	chunk := func(out *bytes.Buffer, kind string, data []byte) {
+		_ = binary.Write(out, binary.BigEndian, uint32(len(data)))
+		body := append([]byte(kind), data...)
+		out.Write(body)
+		_ = binary.Write(out, binary.BigEndian, crc32.ChecksumIEEE(body))
+	}
+	frameControl := func(seq int) []byte {
+		data := make([]byte, 26) //nolint:gomnd
+		binary.BigEndian.PutUint32(data[0:], uint32(seq))
+		binary.BigEndian.PutUint32(data[4:], uint32(width))
+		binary.BigEndian.PutUint32(data[8:], uint32(height))
+		binary.BigEndian.PutUint16(data[20:], 1)
+		binary.BigEndian.PutUint16(data[22:], 10) //nolint:gomnd
+		return data
+	}
+	// the chunks of an encoded PNG but its IEND
+	chunks := func(encoded []byte) (kinds []string, datas [][]byte) {
+		for r := encoded[8:]; len(r) >= 12; { //nolint:gomnd
+			n := binary.BigEndian.Uint32(r)
+			if kind := string(r[4:8]); kind != "IEND" {
+				kinds = append(kinds, kind)
+				datas = append(datas, r[8:8+n])
+			}
+			r = r[12+n:]
+		}
+		return kinds, datas
+	}
+
+	out := &bytes.Buffer{}
+	out.Write(frames[0][:8])
+	seq := 0
+	kinds, datas := chunks(frames[0])
+	for i, kind := range kinds {
+		if kind == "IDAT" && seq == 0 {
+			actl := make([]byte, 8) //nolint:gomnd
+			binary.BigEndian.PutUint32(actl, uint32(len(colors)))
+			chunk(out, "acTL", actl)
+			chunk(out, "fcTL", frameControl(seq))
+			seq++
+		}
+		chunk(out, kind, datas[i])
+	}
+	for _, frame := range frames[1:] {
+		chunk(out, "fcTL", frameControl(seq))
+		seq++
+		kinds, datas = chunks(frame)
+		for i, kind := range kinds {
+			if kind == "IDAT" {
+				data := make([]byte, 4, 4+len(datas[i])) //nolint:gomnd
+				binary.BigEndian.PutUint32(data, uint32(seq))
+				chunk(out, "fdAT", append(data, datas[i]...))
+				seq++
+			}
+		}
+	}
+	chunk(out, "IEND", nil)
+
+	return out.Bytes()
+}
+
+func TestService_ResizeAnimated(t *testing.T) {
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	blue := color.RGBA{B: 0xFF, A: 0xFF}
+
+	testCases := map[string]struct {
+		svcOptions []ServiceOption
+		source     []byte
+		wantFrames int
+		wantErr    error
+	}{
+		"animated": {
+			source:     newAnimatedGif(t, 200, 100, red, blue, red),
+			wantFrames: 3,
+		},
+		"too many frame pixels": {
+			svcOptions: []ServiceOption{WithMaxPixels(200 * 100 * 3)},
+			source:     newAnimatedGif(t, 200, 100, red, blue, red),
+			wantErr:    ErrImageTooLarge,
+		},
+		"not a gif": {
+			source:  []byte("\x89PNG\r\n\x1A\n"),
+			wantErr: ErrUnsupportedFormat,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			svc := New(1, test.svcOptions...)
+			buf := &bytes.Buffer{}
+			err := svc.ResizeAnimated(context.Background(), bytes.NewReader(test.source), 50, 50, buf)
+			require.Truef(t, errors.Is(err, test.wantErr), "error = %v, wantErr %v", err, test.wantErr)
+			if err != nil {
+				return
+			}
+
+			resized, err := gif.DecodeAll(buf)
+			require.NoError(t, err)
+			require.Len(t, resized.Image, test.wantFrames)
+			for _, frame := range resized.Image {
+				require.Equal(t, image.Rect(0, 0, 50, 25), frame.Bounds())
+			}
+			require.Equal(t, blue, color.RGBAModel.Convert(resized.Image[1].At(25, 12)))
+		})
+	}
+}
+
+func TestService_ResizeFirstFrame(t *testing.T) {
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	blue := color.RGBA{B: 0xFF, A: 0xFF}
+
+	buf := &bytes.Buffer{}
+	source := newAnimatedGif(t, 200, 100, red, blue)
+	err := New(1).Resize(context.Background(), bytes.NewReader(source), 50, 50, buf, WithFormat(FormatPng))
+	require.NoError(t, err)
+
+	thumb, _, err := image.Decode(buf)
+	require.NoError(t, err)
+	require.Equal(t, red, color.RGBAModel.Convert(thumb.At(25, 12)))
+}
+
+func TestService_ResizeFirstFrameApng(t *testing.T) {
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	blue := color.RGBA{B: 0xFF, A: 0xFF}
+
+	buf := &bytes.Buffer{}
+	source := newAnimatedPng(t, 200, 100, red, blue)
+	err := New(1).Resize(context.Background(), bytes.NewReader(source), 50, 50, buf, WithFormat(FormatPng))
+	require.NoError(t, err)
+
+	thumb, _, err := image.Decode(buf)
+	require.NoError(t, err)
+	require.Equal(t, red, color.RGBAModel.Convert(thumb.At(25, 12)))
+}
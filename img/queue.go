@@ -0,0 +1,104 @@
+package img
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+// queue hands out a fixed number of slots to the waiters with the
+// highest priority first, and in arrival order among equal priorities.
+type queue struct {
+	mu      sync.Mutex
+	free    int
+	seq     uint64
+	waiters waiterHeap
+}
+
+type waiter struct {
+	priority int
+	seq      uint64
+	ready    chan struct{}
+	// index is the position in the heap, -1 once the slot is given.
+	index int
+}
+
+func newQueue(slots int) *queue {
+	return &queue{free: slots}
+}
+
+// acquire waits for a slot until ctx is done.
+func (q *queue) acquire(ctx context.Context, priority int) error {
+	q.mu.Lock()
+	if q.free > 0 && len(q.waiters) == 0 {
+		q.free--
+		q.mu.Unlock()
+		return nil
+	}
+
+	q.seq++
+	w := &waiter{priority: priority, seq: q.seq, ready: make(chan struct{})}
+	heap.Push(&q.waiters, w)
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		if w.index >= 0 {
+			heap.Remove(&q.waiters, w.index)
+			q.mu.Unlock()
+			return ctx.Err()
+		}
+		q.mu.Unlock()
+		// the slot was given meanwhile
+		q.release()
+		return ctx.Err()
+	}
+}
+
+// release gives the slot to the next waiter, if any.
+func (q *queue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.waiters) == 0 {
+		q.free++
+		return
+	}
+	w := heap.Pop(&q.waiters).(*waiter)
+	close(w.ready)
+}
+
+type waiterHeap []*waiter
+
+func (h waiterHeap) Len() int { return len(h) }
+
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() interface{} {
+	old := *h
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*h = old[:len(old)-1]
+	return w
+}
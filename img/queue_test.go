@@ -0,0 +1,46 @@
+package img
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue(t *testing.T) {
+	q := newQueue(1)
+	require.NoError(t, q.acquire(context.Background(), 0))
+
+	order := make(chan int, 3)
+	for i, priority := range []int{0, 1, 1} {
+		go func(i, priority int) {
+			require.NoError(t, q.acquire(context.Background(), priority))
+			order <- i
+			q.release()
+		}(i, priority)
+		waitForWaiters(t, q, i+1)
+	}
+
+	// a canceled waiter leaves the queue
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, q.acquire(ctx, 2), context.DeadlineExceeded)
+	waitForWaiters(t, q, 3)
+
+	q.release()
+	require.Equal(t, 1, <-order)
+	require.Equal(t, 2, <-order)
+	require.Equal(t, 0, <-order)
+
+	require.NoError(t, q.acquire(context.Background(), 0))
+}
+
+func waitForWaiters(t *testing.T, q *queue, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return len(q.waiters) == n
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,311 @@
+package img
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// rawExtensions are the extensions of the TIFF based camera RAW files.
+var rawExtensions = map[string]bool{
+	".cr2": true,
+	".nef": true,
+	".nrw": true,
+	".arw": true,
+	".srf": true,
+	".sr2": true,
+	".dng": true,
+	".orf": true,
+	".rw2": true,
+	".pef": true,
+	".srw": true,
+	".erf": true,
+	".3fr": true,
+}
+
+// IsRaw returns whether ext is the extension of a camera RAW file whose
+// embedded previews can be read. It is case insensitive.
+func IsRaw(ext string) bool {
+	return rawExtensions[strings.ToLower(ext)]
+}
+
+const (
+	// maxRawIFDs bounds the walk of the IFDs, which may loop in broken
+	// files.
+	maxRawIFDs = 64
+	// maxRawIFDEntries is the largest number of entries read from an IFD.
+	maxRawIFDEntries = 1024
+	// maxRawPreviewSize is the size of the largest embedded preview read.
+	maxRawPreviewSize = 64 << 20
+)
+
+// TIFF tags
+const (
+	tagCompression           = 0x0103
+	tagStripOffsets          = 0x0111
+	tagOrientation           = 0x0112
+	tagStripByteCounts       = 0x0117
+	tagSubIFDs               = 0x014A
+	tagJPEGInterchangeFormat = 0x0201
+	tagJPEGInterchangeLength = 0x0202
+	tagExifIFD               = 0x8769
+)
+
+// TIFF compressions of the JPEG strips
+const (
+	compressionOldJPEG = 6
+	compressionJPEG    = 7
+)
+
+type rawReader struct {
+	r     io.ReaderAt
+	size  int64
+	order binary.ByteOrder
+}
+
+type ifdEntry struct {
+	tag, typ uint16
+	count    uint32
+	value    [4]byte
+}
+
+type rawPreviewCandidate struct {
+	offset, length int64
+	pixels         int
+}
+
+// ResizeRaw resizes the largest JPEG preview embedded in a TIFF based
+// camera RAW file, such as CR2, NEF, ARW or DNG, which can't be decoded
+// themselves. The preview is rotated as the RAW file says.
+func (s *Service) ResizeRaw(ctx context.Context, in io.ReaderAt, size int64, width, height int,
+	out io.Writer, options ...Option) error {
+	preview, orientation, err := rawPreview(in, size)
+	if err != nil {
+		return err
+	}
+
+	options = append(options, withOrientation(orientation))
+	return s.Resize(ctx, bytes.NewReader(preview), width, height, out, options...)
+}
+
+// rawPreview returns the largest JPEG embedded in the RAW file and its
+// orientation, as found in the first IFD.
+func rawPreview(in io.ReaderAt, size int64) ([]byte, int, error) {
+	header := make([]byte, 8) //nolint:gomnd
+	if _, err := in.ReadAt(header, 0); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", err.Error(), ErrUnsupportedFormat)
+	}
+
+	rr := &rawReader{r: in, size: size}
+	switch string(header[:2]) {
+	case "II":
+		rr.order = binary.LittleEndian
+	case "MM":
+		rr.order = binary.BigEndian
+	default:
+		return nil, 0, fmt.Errorf("not a TIFF based RAW file: %w", ErrUnsupportedFormat)
+	}
+	// besides 42, the magic numbers of Olympus and Panasonic files
+	switch rr.order.Uint16(header[2:4]) {
+	case 42, 0x4F52, 0x5352, 0x55: //nolint:gomnd
+	default:
+		return nil, 0, fmt.Errorf("not a TIFF based RAW file: %w", ErrUnsupportedFormat)
+	}
+
+	var (
+		best        rawPreviewCandidate
+		orientation int
+		visited     = map[uint32]bool{}
+		queue       = []uint32{rr.order.Uint32(header[4:8])}
+	)
+	for len(queue) > 0 && len(visited) < maxRawIFDs {
+		offset := queue[0]
+		queue = queue[1:]
+		if offset == 0 || visited[offset] {
+			continue
+		}
+		first := len(visited) == 0
+		visited[offset] = true
+
+		entries, next, err := rr.readIFD(offset)
+		if err != nil {
+			continue
+		}
+		queue = append(queue, next)
+
+		tags := map[uint16]ifdEntry{}
+		for _, entry := range entries {
+			tags[entry.tag] = entry
+		}
+
+		if entry, ok := tags[tagOrientation]; ok && first {
+			orientation = int(rr.order.Uint16(entry.value[:2]))
+		}
+		for _, tag := range []uint16{tagSubIFDs, tagExifIFD} {
+			if entry, ok := tags[tag]; ok {
+				subIFDs, _ := rr.values(entry)
+				queue = append(queue, subIFDs...)
+			}
+		}
+
+		for _, candidate := range rr.candidates(tags) {
+			if candidate.pixels = rr.jpegPixels(candidate); candidate.pixels > best.pixels {
+				best = candidate
+			}
+		}
+	}
+
+	if best.pixels == 0 {
+		return nil, 0, fmt.Errorf("no embedded preview: %w", ErrUnsupportedFormat)
+	}
+
+	preview := make([]byte, best.length)
+	if _, err := in.ReadAt(preview, best.offset); err != nil {
+		return nil, 0, err
+	}
+
+	return preview, orientation, nil
+}
+
+func (rr *rawReader) readIFD(offset uint32) (entries []ifdEntry, next uint32, err error) {
+	buf := make([]byte, 2) //nolint:gomnd
+	if _, err = rr.r.ReadAt(buf, int64(offset)); err != nil {
+		return nil, 0, err
+	}
+	count := int(rr.order.Uint16(buf))
+	if count > maxRawIFDEntries {
+		return nil, 0, fmt.Errorf("%d IFD entries: %w", count, ErrUnsupportedFormat)
+	}
+
+	buf = make([]byte, count*12+4) //nolint:gomnd
+	if _, err = rr.r.ReadAt(buf, int64(offset)+2); err != nil {
+		return nil, 0, err
+	}
+
+	entries = make([]ifdEntry, count)
+	for i := range entries {
+		b := buf[i*12:] //nolint:gomnd
+		entries[i] = ifdEntry{
+			tag:   rr.order.Uint16(b[0:2]),
+			typ:   rr.order.Uint16(b[2:4]),
+			count: rr.order.Uint32(b[4:8]),
+		}
+		copy(entries[i].value[:], b[8:12])
+	}
+
+	return entries, rr.order.Uint32(buf[count*12:]), nil
+}
+
+// values returns the integers of a SHORT, LONG or IFD entry.
+func (rr *rawReader) values(entry ifdEntry) ([]uint32, error) {
+	var size int
+	switch entry.typ {
+	case 3: // SHORT
+		size = 2
+	case 4, 13: // LONG, IFD
+		size = 4
+	default:
+		return nil, fmt.Errorf("IFD entry type %d: %w", entry.typ, ErrUnsupportedFormat)
+	}
+	if entry.count > maxRawIFDEntries {
+		return nil, fmt.Errorf("%d IFD values: %w", entry.count, ErrUnsupportedFormat)
+	}
+
+	data := entry.value[:]
+	if n := int(entry.count) * size; n > len(data) {
+		data = make([]byte, n)
+		if _, err := rr.r.ReadAt(data, int64(rr.order.Uint32(entry.value[:]))); err != nil {
+			return nil, err
+		}
+	}
+
+	values := make([]uint32, entry.count)
+	for i := range values {
+		if size == 2 { //nolint:gomnd
+			values[i] = uint32(rr.order.Uint16(data[i*2:]))
+		} else {
+			values[i] = rr.order.Uint32(data[i*4:])
+		}
+	}
+	return values, nil
+}
+
+// candidates returns the JPEG streams of an IFD, either referred to as
+// the JPEG interchange format or stored as a single JPEG strip.
+func (rr *rawReader) candidates(tags map[uint16]ifdEntry) []rawPreviewCandidate {
+	var candidates []rawPreviewCandidate
+
+	if offset, ok := rr.value(tags, tagJPEGInterchangeFormat); ok {
+		if length, ok := rr.value(tags, tagJPEGInterchangeLength); ok {
+			candidates = append(candidates, rawPreviewCandidate{offset: int64(offset), length: int64(length)})
+		}
+	}
+
+	if compression, ok := rr.value(tags, tagCompression); ok &&
+		(compression == compressionOldJPEG || compression == compressionJPEG) {
+		offsets, errOffsets := rr.values(tags[tagStripOffsets])
+		counts, errCounts := rr.values(tags[tagStripByteCounts])
+		if errOffsets == nil && errCounts == nil && len(offsets) == 1 && len(counts) == 1 {
+			candidates = append(candidates, rawPreviewCandidate{offset: int64(offsets[0]), length: int64(counts[0])})
+		}
+	}
+
+	return candidates
+}
+
+// value returns the first integer of the tag.
+func (rr *rawReader) value(tags map[uint16]ifdEntry, tag uint16) (uint32, bool) {
+	entry, ok := tags[tag]
+	if !ok {
+		return 0, false
+	}
+	values, err := rr.values(entry)
+	if err != nil || len(values) == 0 {
+		return 0, false
+	}
+	return values[0], true
+}
+
+// jpegPixels returns the number of pixels of the JPEG stream, 0 if it
+// isn't a JPEG Go can decode, such as the lossless JPEG of the raw data.
+func (rr *rawReader) jpegPixels(candidate rawPreviewCandidate) int {
+	if candidate.length <= 2 || candidate.length > maxRawPreviewSize ||
+		candidate.offset < 0 || candidate.offset+candidate.length > rr.size {
+		return 0
+	}
+
+	config, err := jpeg.DecodeConfig(io.NewSectionReader(rr.r, candidate.offset, candidate.length))
+	if err != nil {
+		return 0
+	}
+	return config.Width * config.Height
+}
+
+// orient applies an EXIF orientation to img.
+func orient(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2: //nolint:gomnd
+		return imaging.FlipH(img)
+	case 3: //nolint:gomnd
+		return imaging.Rotate180(img)
+	case 4: //nolint:gomnd
+		return imaging.FlipV(img)
+	case 5: //nolint:gomnd
+		return imaging.Transpose(img)
+	case 6: //nolint:gomnd
+		return imaging.Rotate270(img)
+	case 7: //nolint:gomnd
+		return imaging.Transverse(img)
+	case 8: //nolint:gomnd
+		return imaging.Rotate90(img)
+	default:
+		return img
+	}
+}
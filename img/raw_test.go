@@ -0,0 +1,185 @@
+package img
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rawIFD is an IFD of the RAW files built by the tests, whose entries
+// are all LONG values.
+type rawIFD map[uint16][]uint32
+
+// encodeIFD encodes ifd to be written at offset, followed by the values
+// that don't fit in the entries.
+func encodeIFD(ifd rawIFD, offset uint32) []byte {
+	tags := make([]int, 0, len(ifd))
+	for tag := range ifd {
+		tags = append(tags, int(tag))
+	}
+	sort.Ints(tags)
+
+	extra := offset + 2 + uint32(len(ifd))*12 + 4
+	var entries, values []byte
+	entries = appendUint16(entries, uint16(len(ifd)))
+	for _, tag := range tags {
+		vs := ifd[uint16(tag)]
+		entries = appendUint16(entries, uint16(tag))
+		entries = appendUint16(entries, 4) //nolint:gomnd
+		entries = appendUint32(entries, uint32(len(vs)))
+		if len(vs) == 1 {
+			entries = appendUint32(entries, vs[0])
+			continue
+		}
+		entries = appendUint32(entries, extra+uint32(len(values)))
+		for _, v := range vs {
+			values = appendUint32(values, v)
+		}
+	}
+	// no next IFD
+	entries = append(entries, 0, 0, 0, 0)
+
+	return append(entries, values...)
+}
+
+// newRaw builds a little endian TIFF made of the blobs followed by the
+// IFDs, which are given the offsets of the blobs and chained in order.
+func newRaw(blobs [][]byte, ifds func(offsets []uint32) []rawIFD) []byte {
+	order := binary.LittleEndian
+	data := []byte("II*\x00\x00\x00\x00\x00")
+
+	offsets := make([]uint32, len(blobs))
+	for i, blob := range blobs {
+		offsets[i] = uint32(len(data))
+		data = append(data, blob...)
+	}
+
+	// the offset of the first IFD is at 4
+	next := 4
+	for _, ifd := range ifds(offsets) {
+		offset := uint32(len(data))
+		order.PutUint32(data[next:], offset)
+		next = len(data) + 2 + len(ifd)*12
+		data = append(data, encodeIFD(ifd, offset)...)
+	}
+
+	return data
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func newJpegBytes(t *testing.T, width, height int) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	require.NoError(t, jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, width, height)), nil))
+	return buf.Bytes()
+}
+
+func TestService_ResizeRaw(t *testing.T) {
+	small := newJpegBytes(t, 16, 8)
+	large := newJpegBytes(t, 64, 32)
+
+	testCases := map[string]struct {
+		raw        []byte
+		wantWidth  int
+		wantHeight int
+		wantErr    error
+	}{
+		"preview in a sub IFD": {
+			raw: newRaw([][]byte{small, large, encodeIFD(rawIFD{
+				tagCompression:     {compressionJPEG},
+				tagStripOffsets:    {8 + uint32(len(small))},
+				tagStripByteCounts: {uint32(len(large))},
+			}, 0)}, func(offsets []uint32) []rawIFD {
+				return []rawIFD{
+					{
+						tagJPEGInterchangeFormat: {offsets[0]},
+						tagJPEGInterchangeLength: {uint32(len(small))},
+						// the broken offset is skipped
+						tagSubIFDs: {offsets[2], 1 << 30},
+					},
+				}
+			}),
+			wantWidth:  64,
+			wantHeight: 32,
+		},
+		"largest preview strip": {
+			raw: newRaw([][]byte{small, large, []byte("raw sensor data")}, func(offsets []uint32) []rawIFD {
+				return []rawIFD{
+					{
+						tagJPEGInterchangeFormat: {offsets[0]},
+						tagJPEGInterchangeLength: {uint32(len(small))},
+					},
+					{
+						tagCompression:     {compressionOldJPEG},
+						tagStripOffsets:    {offsets[2]},
+						tagStripByteCounts: {15},
+					},
+					{
+						tagCompression:     {compressionJPEG},
+						tagStripOffsets:    {offsets[1]},
+						tagStripByteCounts: {uint32(len(large))},
+					},
+				}
+			}),
+			wantWidth:  64,
+			wantHeight: 32,
+		},
+		"orientation": {
+			raw: newRaw([][]byte{large}, func(offsets []uint32) []rawIFD {
+				return []rawIFD{
+					{
+						tagOrientation:           {6},
+						tagJPEGInterchangeFormat: {offsets[0]},
+						tagJPEGInterchangeLength: {uint32(len(large))},
+					},
+				}
+			}),
+			wantWidth:  32,
+			wantHeight: 64,
+		},
+		"no preview": {
+			raw: newRaw([][]byte{[]byte("raw sensor data")}, func(offsets []uint32) []rawIFD {
+				return []rawIFD{
+					{
+						tagCompression:     {compressionOldJPEG},
+						tagStripOffsets:    {offsets[0]},
+						tagStripByteCounts: {15},
+					},
+				}
+			}),
+			wantErr: ErrUnsupportedFormat,
+		},
+		"not a TIFF": {
+			raw:     large,
+			wantErr: ErrUnsupportedFormat,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			svc := New(1)
+			buf := &bytes.Buffer{}
+			err := svc.ResizeRaw(context.Background(), bytes.NewReader(test.raw), int64(len(test.raw)),
+				100, 100, buf, WithMode(ResizeModeFit))
+			require.Truef(t, errors.Is(err, test.wantErr), "error = %v, wantErr %v", err, test.wantErr)
+			if err != nil {
+				return
+			}
+			sizeMatcher(test.wantWidth, test.wantHeight)(t, buf)
+		})
+	}
+}
@@ -2,32 +2,112 @@
 package img
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"image"
 	"io"
+	"os"
+	"strings"
 
 	"github.com/disintegration/imaging"
 	"github.com/dsoprea/go-exif/v3"
 	"github.com/marusama/semaphore/v2"
+	_ "golang.org/x/image/webp" // register the webp decoder
 
 	exifcommon "github.com/dsoprea/go-exif/v3/common"
+
+	"github.com/filebrowser/filebrowser/v2/filetype"
+	"github.com/filebrowser/filebrowser/v2/preview"
+)
+
+var (
+	// ErrUnsupportedFormat means the given image format is not supported.
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	// ErrImageTooLarge means the image has more pixels or needs more
+	// memory than allowed.
+	ErrImageTooLarge = errors.New("image is too large")
 )
 
-// ErrUnsupportedFormat means the given image format is not supported.
-var ErrUnsupportedFormat = errors.New("unsupported image format")
+const (
+	// DefaultMaxPixels is the largest number of pixels of the decoded
+	// images if no limit is set.
+	DefaultMaxPixels = 100_000_000
+	// DefaultMaxMemory is the memory in bytes the images being resized
+	// may take if no limit is set.
+	DefaultMaxMemory = 2 << 30
+	// bytesPerPixel is the memory taken by a pixel, counting both the
+	// decoded image and its NRGBA copy made by the resize.
+	bytesPerPixel = 8
+)
 
 // Service
 type Service struct {
-	sem semaphore.Semaphore
+	queue *queue
+	// mem holds the memory of the images being resized, in KiB.
+	mem       semaphore.Semaphore
+	maxPixels int
+	// converter is the command converting the formats without Go
+	// decoder to png or jpeg.
+	converter []string
+	// webpEncoder is the command encoding png images to webp.
+	webpEncoder []string
+	runner      *preview.Runner
+}
+
+type ServiceOption func(*Service)
+
+// WithConverter sets the command converting AVIF and HEIC images, which
+// have no Go decoder, to png or jpeg on its standard output. The image
+// is given as $FILE and the largest dimension needed as $SIZE.
+func WithConverter(command []string) ServiceOption {
+	return func(s *Service) {
+		s.converter = command
+	}
+}
+
+// WithWebpEncoder sets the command encoding images to webp on its
+// standard output, there is no Go encoder. The image is given as a png
+// file $FILE.
+func WithWebpEncoder(command []string) ServiceOption {
+	return func(s *Service) {
+		s.webpEncoder = command
+	}
+}
+
+// WithMaxPixels sets the largest number of pixels of the images, the
+// larger ones are refused before being decoded.
+func WithMaxPixels(pixels int) ServiceOption {
+	return func(s *Service) {
+		if pixels > 0 {
+			s.maxPixels = pixels
+		}
+	}
+}
+
+// WithMaxMemory sets the memory in bytes the images being resized at
+// once may take. The images wait for the memory to be available.
+func WithMaxMemory(bytes int64) ServiceOption {
+	return func(s *Service) {
+		if bytes > 0 {
+			s.mem = semaphore.New(int(bytes >> 10))
+		}
+	}
 }
 
-func New(workers int) *Service {
-	return &Service{
-		sem: semaphore.New(workers),
+func New(workers int, opts ...ServiceOption) *Service {
+	s := &Service{
+		queue:     newQueue(workers),
+		mem:       semaphore.New(DefaultMaxMemory >> 10),
+		maxPixels: DefaultMaxPixels,
+		runner:    preview.NewRunner(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // Format is an image file format.
@@ -38,10 +118,20 @@ png
 gif
 tiff
 bmp
+webp
+avif
+heic
 )
 */
 type Format int
 
+// needsConverter returns whether the format has no Go decoder.
+func (x Format) needsConverter() bool {
+	return x == FormatAvif || x == FormatHeic
+}
+
+// toImaging returns the imaging format of the formats with a Go
+// encoder, and jpeg for the others.
 func (x Format) toImaging() imaging.Format {
 	switch x {
 	case FormatJpeg:
@@ -90,6 +180,15 @@ fill
 type ResizeMode int
 
 func (s *Service) FormatFromExtension(ext string) (Format, error) {
+	switch strings.ToLower(ext) {
+	case ".webp":
+		return FormatWebp, nil
+	case ".avif":
+		return s.converted(FormatAvif)
+	case ".heic", ".heif":
+		return s.converted(FormatHeic)
+	}
+
 	format, err := imaging.FormatFromExtension(ext)
 	if err != nil {
 		return -1, ErrUnsupportedFormat
@@ -109,10 +208,35 @@ func (s *Service) FormatFromExtension(ext string) (Format, error) {
 	return -1, ErrUnsupportedFormat
 }
 
+// CanEncode returns whether images can be resized to format.
+func (s *Service) CanEncode(format Format) bool {
+	switch format {
+	case FormatJpeg, FormatPng, FormatGif, FormatTiff, FormatBmp:
+		return true
+	case FormatWebp:
+		return len(s.webpEncoder) != 0
+	default:
+		return false
+	}
+}
+
+// converted returns format if a converter is set.
+func (s *Service) converted(format Format) (Format, error) {
+	if len(s.converter) == 0 {
+		return -1, ErrUnsupportedFormat
+	}
+	return format, nil
+}
+
 type resizeConfig struct {
 	format     Format
+	formatSet  bool
 	resizeMode ResizeMode
 	quality    Quality
+	priority   int
+	// orientation is the EXIF orientation of images whose own EXIF
+	// data is ignored, 0 if unknown.
+	orientation int
 }
 
 type Option func(*resizeConfig)
@@ -120,6 +244,7 @@ type Option func(*resizeConfig)
 func WithFormat(format Format) Option {
 	return func(config *resizeConfig) {
 		config.format = format
+		config.formatSet = true
 	}
 }
 
@@ -135,19 +260,22 @@ func WithQuality(quality Quality) Option {
 	}
 }
 
-func (s *Service) Resize(ctx context.Context, in io.Reader, width, height int, out io.Writer, options ...Option) error {
-	if err := s.sem.Acquire(ctx, 1); err != nil {
-		return err
+func withOrientation(orientation int) Option {
+	return func(config *resizeConfig) {
+		config.orientation = orientation
 	}
-	defer s.sem.Release(1)
+}
 
-	format, wrappedReader, err := s.detectFormat(in)
-	if err != nil {
-		return err
+// WithPriority sets the priority of the resize, the resizes waiting for
+// a worker with the highest priority run first.
+func WithPriority(priority int) Option {
+	return func(config *resizeConfig) {
+		config.priority = priority
 	}
+}
 
+func (s *Service) Resize(ctx context.Context, in io.Reader, width, height int, out io.Writer, options ...Option) error {
 	config := resizeConfig{
-		format:     format,
 		resizeMode: ResizeModeFit,
 		quality:    QualityMedium,
 	}
@@ -155,7 +283,23 @@ func (s *Service) Resize(ctx context.Context, in io.Reader, width, height int, o
 		option(&config)
 	}
 
-	if config.quality == QualityLow && format == FormatJpeg {
+	if err := s.queue.acquire(ctx, config.priority); err != nil {
+		return err
+	}
+	defer s.queue.release()
+
+	format, imgConfig, wrappedReader, err := s.detectFormat(in)
+	if err != nil {
+		return err
+	}
+	if !config.formatSet {
+		config.format = format
+		if !s.CanEncode(format) {
+			config.format = FormatJpeg
+		}
+	}
+
+	if config.quality == QualityLow && format == FormatJpeg && config.format == FormatJpeg && config.orientation == 0 {
 		thm, newWrappedReader, errThm := getEmbeddedThumbnail(wrappedReader)
 		wrappedReader = newWrappedReader
 		if errThm == nil {
@@ -166,10 +310,34 @@ func (s *Service) Resize(ctx context.Context, in io.Reader, width, height int, o
 		}
 	}
 
-	img, err := imaging.Decode(wrappedReader, imaging.AutoOrientation(true))
+	if format.needsConverter() {
+		size := width
+		if height > size {
+			size = height
+		}
+		converted, errConv := s.convert(ctx, wrappedReader, format, size)
+		if errConv != nil {
+			return errConv
+		}
+		wrappedReader = bytes.NewReader(converted)
+
+		imgConfig, _, err = image.DecodeConfig(bytes.NewReader(converted))
+		if err != nil {
+			return fmt.Errorf("%s: %w", err.Error(), ErrUnsupportedFormat)
+		}
+	}
+
+	release, err := s.reserve(ctx, imgConfig)
 	if err != nil {
 		return err
 	}
+	defer release()
+
+	img, err := imaging.Decode(wrappedReader, imaging.AutoOrientation(config.orientation == 0))
+	if err != nil {
+		return err
+	}
+	img = orient(img, config.orientation)
 
 	switch config.resizeMode {
 	case ResizeModeFill:
@@ -180,24 +348,111 @@ func (s *Service) Resize(ctx context.Context, in io.Reader, width, height int, o
 		img = imaging.Fit(img, width, height, config.quality.resampleFilter())
 	}
 
+	if config.format == FormatWebp {
+		if !s.CanEncode(FormatWebp) {
+			return fmt.Errorf("no webp encoder: %w", ErrUnsupportedFormat)
+		}
+		encoded, err := s.run(ctx, s.webpEncoder, FormatPng, 0, func(w io.Writer) error {
+			return imaging.Encode(w, img, imaging.PNG)
+		})
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(encoded)
+		return err
+	}
+
 	return imaging.Encode(out, img, config.format.toImaging())
 }
 
-func (s *Service) detectFormat(in io.Reader) (Format, io.Reader, error) {
+// detectFormat detects the format of in from its content. The formats
+// without Go decoder are recognized from their signature, and only if a
+// converter is set.
+func (s *Service) detectFormat(in io.Reader) (Format, image.Config, io.Reader, error) {
+	br := bufio.NewReaderSize(in, filetype.HeaderLen)
+	header, err := br.Peek(filetype.HeaderLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return -1, image.Config{}, nil, err
+	}
+
+	// the size of the formats without Go decoder is only known once
+	// they are converted.
+	switch filetype.Detect("", header).MIME {
+	case "image/avif":
+		format, err := s.converted(FormatAvif)
+		return format, image.Config{}, br, err
+	case "image/heic", "image/heif":
+		format, err := s.converted(FormatHeic)
+		return format, image.Config{}, br, err
+	}
+
 	buf := &bytes.Buffer{}
-	r := io.TeeReader(in, buf)
+	r := io.TeeReader(br, buf)
 
-	_, imgFormat, err := image.DecodeConfig(r)
+	imgConfig, imgFormat, err := image.DecodeConfig(r)
 	if err != nil {
-		return 0, nil, fmt.Errorf("%s: %w", err.Error(), ErrUnsupportedFormat)
+		return -1, image.Config{}, nil, fmt.Errorf("%s: %w", err.Error(), ErrUnsupportedFormat)
 	}
 
 	format, err := ParseFormat(imgFormat)
 	if err != nil {
-		return 0, nil, ErrUnsupportedFormat
+		return -1, image.Config{}, nil, fmt.Errorf("%s: %w", imgFormat, ErrUnsupportedFormat)
+	}
+
+	return format, imgConfig, io.MultiReader(buf, br), nil
+}
+
+// reserve checks the number of pixels of the image and waits for the
+// memory to decode it to be available. release gives the memory back.
+func (s *Service) reserve(ctx context.Context, imgConfig image.Config) (release func(), err error) {
+	pixels := int64(imgConfig.Width) * int64(imgConfig.Height)
+	if pixels > int64(s.maxPixels) {
+		return nil, fmt.Errorf("%dx%d pixels: %w", imgConfig.Width, imgConfig.Height, ErrImageTooLarge)
+	}
+
+	return s.reserveMemory(ctx, pixels*bytesPerPixel)
+}
+
+// reserveMemory waits for size bytes of memory to be available.
+func (s *Service) reserveMemory(ctx context.Context, size int64) (release func(), err error) {
+	kib := int(size>>10) + 1
+	if kib > s.mem.GetLimit() {
+		return nil, fmt.Errorf("image needs %d MiB: %w", kib>>10, ErrImageTooLarge)
+	}
+	if err := s.mem.Acquire(ctx, kib); err != nil {
+		return nil, err
+	}
+
+	return func() { s.mem.Release(kib) }, nil
+}
+
+// convert runs the converter on the image read from in.
+func (s *Service) convert(ctx context.Context, in io.Reader, format Format, size int) ([]byte, error) {
+	return s.run(ctx, s.converter, format, size, func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+}
+
+// run runs command on a temporary file of the given format filled by
+// write.
+func (s *Service) run(ctx context.Context, command []string, format Format, size int,
+	write func(w io.Writer) error) ([]byte, error) {
+	tmp, err := os.CreateTemp("", "filebrowser-*."+format.String())
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = write(tmp)
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return format, io.MultiReader(buf, in), nil
+	return s.runner.Run(ctx, command, preview.Options{Input: tmp.Name(), Size: size})
 }
 
 func getEmbeddedThumbnail(in io.Reader) ([]byte, io.Reader, error) {
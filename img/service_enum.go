@@ -19,9 +19,15 @@ const (
 	FormatTiff
 	// FormatBmp is a Format of type Bmp
 	FormatBmp
+	// FormatWebp is a Format of type Webp
+	FormatWebp
+	// FormatAvif is a Format of type Avif
+	FormatAvif
+	// FormatHeic is a Format of type Heic
+	FormatHeic
 )
 
-const _FormatName = "jpegpnggiftiffbmp"
+const _FormatName = "jpegpnggiftiffbmpwebpavifheic"
 
 var _FormatMap = map[Format]string{
 	0: _FormatName[0:4],
@@ -29,6 +35,9 @@ var _FormatMap = map[Format]string{
 	2: _FormatName[7:10],
 	3: _FormatName[10:14],
 	4: _FormatName[14:17],
+	5: _FormatName[17:21],
+	6: _FormatName[21:25],
+	7: _FormatName[25:29],
 }
 
 // String implements the Stringer interface.
@@ -45,6 +54,9 @@ var _FormatValue = map[string]Format{
 	_FormatName[7:10]:  2,
 	_FormatName[10:14]: 3,
 	_FormatName[14:17]: 4,
+	_FormatName[17:21]: 5,
+	_FormatName[21:25]: 6,
+	_FormatName[25:29]: 7,
 }
 
 // ParseFormat attempts to convert a string to a Format
@@ -19,12 +19,13 @@ import (
 
 func TestService_Resize(t *testing.T) {
 	testCases := map[string]struct {
-		options []Option
-		width   int
-		height  int
-		source  func(t *testing.T) afero.File
-		matcher func(t *testing.T, reader io.Reader)
-		wantErr bool
+		svcOptions []ServiceOption
+		options    []Option
+		width      int
+		height     int
+		source     func(t *testing.T) afero.File
+		matcher    func(t *testing.T, reader io.Reader)
+		wantErr    bool
 	}{
 		"fill upscale": {
 			options: []Option{WithMode(ResizeModeFill)},
@@ -273,11 +274,112 @@ func TestService_Resize(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		"webp": {
+			options: []Option{WithMode(ResizeModeFit)},
+			width:   50,
+			height:  50,
+			source: func(t *testing.T) afero.File {
+				t.Helper()
+				return openFile(t, "testdata/gopher.webp")
+			},
+			matcher: func(t *testing.T, reader io.Reader) {
+				t.Helper()
+				buf := &bytes.Buffer{}
+				_, err := io.Copy(buf, reader)
+				require.NoError(t, err)
+				formatMatcher(FormatJpeg)(t, bytes.NewReader(buf.Bytes()))
+				sizeMatcher(37, 50)(t, bytes.NewReader(buf.Bytes()))
+			},
+		},
+		"encode webp": {
+			options:    []Option{WithFormat(FormatWebp)},
+			svcOptions: []ServiceOption{WithWebpEncoder([]string{"cat", "$FILE"})},
+			width:      100,
+			height:     100,
+			source: func(t *testing.T) afero.File {
+				t.Helper()
+				return newGrayJpeg(t, 200, 150)
+			},
+			// the encoder is given a png
+			matcher: formatMatcher(FormatPng),
+		},
+		"encode webp without encoder": {
+			options: []Option{WithFormat(FormatWebp)},
+			width:   100,
+			height:  100,
+			source: func(t *testing.T) afero.File {
+				t.Helper()
+				return newGrayJpeg(t, 200, 150)
+			},
+			wantErr: true,
+		},
+		"heic with converter": {
+			options:    []Option{WithMode(ResizeModeFit)},
+			svcOptions: []ServiceOption{WithConverter([]string{"sh", "-c", `test -s "$FILE" && cat testdata/gray-sample.jpg`})},
+			width:      100,
+			height:     100,
+			source: func(t *testing.T) afero.File {
+				t.Helper()
+				return newHeic(t)
+			},
+			matcher: formatMatcher(FormatJpeg),
+		},
+		"heic without converter": {
+			options: []Option{WithMode(ResizeModeFit)},
+			width:   100,
+			height:  100,
+			source: func(t *testing.T) afero.File {
+				t.Helper()
+				return newHeic(t)
+			},
+			wantErr: true,
+		},
+		"failing converter": {
+			options:    []Option{WithMode(ResizeModeFit)},
+			svcOptions: []ServiceOption{WithConverter([]string{"false"})},
+			width:      100,
+			height:     100,
+			source: func(t *testing.T) afero.File {
+				t.Helper()
+				return newHeic(t)
+			},
+			wantErr: true,
+		},
+		"too many pixels": {
+			svcOptions: []ServiceOption{WithMaxPixels(200 * 149)},
+			width:      100,
+			height:     100,
+			source: func(t *testing.T) afero.File {
+				t.Helper()
+				return newGrayPng(t, 200, 150)
+			},
+			wantErr: true,
+		},
+		"too much memory": {
+			svcOptions: []ServiceOption{WithMaxMemory(200 * 149 * bytesPerPixel)},
+			width:      100,
+			height:     100,
+			source: func(t *testing.T) afero.File {
+				t.Helper()
+				return newGrayPng(t, 200, 150)
+			},
+			wantErr: true,
+		},
+		"within limits": {
+			svcOptions: []ServiceOption{WithMaxPixels(200 * 150), WithMaxMemory(200 * 151 * bytesPerPixel)},
+			width:      100,
+			height:     100,
+			source: func(t *testing.T) afero.File {
+				t.Helper()
+				return newGrayPng(t, 200, 150)
+			},
+			matcher: sizeMatcher(100, 75),
+		},
 	}
 
 	for name, test := range testCases {
 		t.Run(name, func(t *testing.T) {
-			svc := New(1)
+			svc := New(1, test.svcOptions...)
 			source := test.source(t)
 			defer source.Close()
 
@@ -388,6 +490,21 @@ func newGrayBmp(t *testing.T, width, height int) afero.File {
 	return file
 }
 
+// newHeic creates the start of a HEIC file, which is only recognized.
+func newHeic(t *testing.T) afero.File {
+	fs := afero.NewMemMapFs()
+	file, err := fs.Create("image.heic")
+	require.NoError(t, err)
+
+	_, err = file.WriteString("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic")
+	require.NoError(t, err)
+
+	_, err = file.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	return file
+}
+
 func openFile(t *testing.T, name string) afero.File {
 	appfs := afero.NewOsFs()
 	file, err := appfs.Open(name)
@@ -399,9 +516,10 @@ func openFile(t *testing.T, name string) afero.File {
 
 func TestService_FormatFromExtension(t *testing.T) {
 	testCases := map[string]struct {
-		ext     string
-		want    Format
-		wantErr error
+		ext       string
+		converter []string
+		want      Format
+		wantErr   error
 	}{
 		"jpg": {
 			ext:  ".jpg",
@@ -427,6 +545,24 @@ func TestService_FormatFromExtension(t *testing.T) {
 			ext:  ".bmp",
 			want: FormatBmp,
 		},
+		"webp": {
+			ext:  ".webp",
+			want: FormatWebp,
+		},
+		"avif": {
+			ext:       ".AVIF",
+			converter: []string{"convert"},
+			want:      FormatAvif,
+		},
+		"heif": {
+			ext:       ".heif",
+			converter: []string{"convert"},
+			want:      FormatHeic,
+		},
+		"heic without converter": {
+			ext:     ".heic",
+			wantErr: ErrUnsupportedFormat,
+		},
 		"unknown": {
 			ext:     ".mov",
 			wantErr: ErrUnsupportedFormat,
@@ -435,7 +571,7 @@ func TestService_FormatFromExtension(t *testing.T) {
 
 	for name, test := range testCases {
 		t.Run(name, func(t *testing.T) {
-			svc := New(1)
+			svc := New(1, WithConverter(test.converter))
 			got, err := svc.FormatFromExtension(test.ext)
 			require.Truef(t, errors.Is(err, test.wantErr), "error = %v, wantErr %v", err, test.wantErr)
 			if err != nil {
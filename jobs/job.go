@@ -0,0 +1,171 @@
+package jobs
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status describes the state of a job.
+type Status string
+
+const (
+	StatusRunning  Status = "running"
+	StatusDone     Status = "done"
+	StatusFailed   Status = "failed"
+	StatusCanceled Status = "canceled"
+)
+
+// Info is a point in time snapshot of a job.
+type Info struct {
+	ID         string      `json:"id"`
+	Kind       string      `json:"kind"`
+	Status     Status      `json:"status"`
+	BytesDone  int64       `json:"bytesDone"`
+	FilesDone  int64       `json:"filesDone"`
+	BytesTotal int64       `json:"bytesTotal"`
+	FilesTotal int64       `json:"filesTotal"`
+	Error      string      `json:"error,omitempty"`
+	Result     interface{} `json:"result,omitempty"`
+	Started    time.Time   `json:"started"`
+	Finished   *time.Time  `json:"finished,omitempty"`
+}
+
+// Job is a long running operation tracked by a Manager. It implements
+// fileutils.Progress so it can be handed directly to file operations.
+type Job struct {
+	id      string
+	owner   string
+	kind    string
+	started time.Time
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	bytesDone  int64
+	filesDone  int64
+	bytesTotal int64
+	filesTotal int64
+
+	mu           sync.RWMutex
+	status       Status
+	err          error
+	result       interface{}
+	finished     time.Time
+	artifact     string
+	artifactName string
+}
+
+// ID returns the job identifier.
+func (j *Job) ID() string {
+	return j.id
+}
+
+// Done returns a channel that is closed when the job finishes.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// AddBytes implements fileutils.Progress.
+func (j *Job) AddBytes(n int64) {
+	atomic.AddInt64(&j.bytesDone, n)
+}
+
+// AddFiles implements fileutils.Progress.
+func (j *Job) AddFiles(n int64) {
+	atomic.AddInt64(&j.filesDone, n)
+}
+
+// SetTotal sets the expected amount of work, if known.
+func (j *Job) SetTotal(bytes, files int64) {
+	atomic.StoreInt64(&j.bytesTotal, bytes)
+	atomic.StoreInt64(&j.filesTotal, files)
+}
+
+// SetResult attaches a JSON serializable result to the job.
+func (j *Job) SetResult(result interface{}) {
+	j.mu.Lock()
+	j.result = result
+	j.mu.Unlock()
+}
+
+// SetArtifact attaches a file produced by the job. The file is removed
+// when the job is dropped from the history.
+func (j *Job) SetArtifact(path, name string) {
+	j.mu.Lock()
+	j.artifact = path
+	j.artifactName = name
+	j.mu.Unlock()
+}
+
+// Artifact returns the path and the download name of the file
+// produced by the job, if any.
+func (j *Job) Artifact() (path, name string) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.artifact, j.artifactName
+}
+
+// Info returns a snapshot of the job state.
+func (j *Job) Info() Info {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	info := Info{
+		ID:         j.id,
+		Kind:       j.kind,
+		Status:     j.status,
+		BytesDone:  atomic.LoadInt64(&j.bytesDone),
+		FilesDone:  atomic.LoadInt64(&j.filesDone),
+		BytesTotal: atomic.LoadInt64(&j.bytesTotal),
+		FilesTotal: atomic.LoadInt64(&j.filesTotal),
+		Result:     j.result,
+		Started:    j.started,
+	}
+
+	if j.err != nil {
+		info.Error = j.err.Error()
+	}
+
+	if !j.finished.IsZero() {
+		finished := j.finished
+		info.Finished = &finished
+	}
+
+	return info
+}
+
+func (j *Job) finish(err error) {
+	j.mu.Lock()
+	switch {
+	case err == nil:
+		j.status = StatusDone
+	case err == context.Canceled:
+		j.status = StatusCanceled
+	default:
+		j.status = StatusFailed
+		j.err = err
+	}
+	j.finished = time.Now()
+	j.mu.Unlock()
+
+	j.cancel()
+	close(j.done)
+}
+
+func (j *Job) expired(retention time.Duration) bool {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return !j.finished.IsZero() && time.Since(j.finished) > retention
+}
+
+func (j *Job) cleanup() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.artifact != "" {
+		_ = os.Remove(j.artifact)
+		j.artifact = ""
+	}
+}
@@ -0,0 +1,145 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	libErrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// Func is the work done by a job. It must return as soon as
+// possible once ctx is canceled.
+type Func func(ctx context.Context, job *Job) error
+
+// Manager runs jobs in the background and keeps them queryable
+// for the configured retention period after they finish.
+type Manager struct {
+	retention time.Duration
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewManager creates a job manager.
+func NewManager(retention time.Duration) *Manager {
+	return &Manager{
+		retention: retention,
+		jobs:      map[string]*Job{},
+	}
+}
+
+// Start runs fn in a new goroutine and returns the job tracking it.
+func (m *Manager) Start(owner, kind string, fn Func) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	job := &Job{
+		id:      newID(),
+		owner:   owner,
+		kind:    kind,
+		started: time.Now(),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		status:  StatusRunning,
+	}
+
+	m.mu.Lock()
+	m.purge()
+	m.jobs[job.id] = job
+	m.mu.Unlock()
+
+	go func() {
+		err := fn(ctx, job)
+		if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+			err = context.Canceled
+		}
+		job.finish(err)
+	}()
+
+	return job
+}
+
+// Get returns the job with the given id if it belongs to owner.
+func (m *Manager) Get(owner, id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purge()
+
+	job, ok := m.jobs[id]
+	if !ok || job.owner != owner {
+		return nil, libErrors.ErrNotExist
+	}
+
+	return job, nil
+}
+
+// List returns the jobs that belong to owner, newest first.
+func (m *Manager) List(owner string) []Info {
+	m.mu.Lock()
+	m.purge()
+	list := []Info{}
+	for _, job := range m.jobs {
+		if job.owner == owner {
+			list = append(list, job.Info())
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Started.After(list[j].Started)
+	})
+
+	return list
+}
+
+// Cancel cancels a running job. Canceling a finished job is a no-op.
+func (m *Manager) Cancel(owner, id string) error {
+	job, err := m.Get(owner, id)
+	if err != nil {
+		return err
+	}
+
+	job.cancel()
+	return nil
+}
+
+// Run drops the finished jobs older than the retention period, and
+// their artifacts, every interval until ctx is done, so that they don't
+// wait for the next calls to the manager.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		m.mu.Lock()
+		m.purge()
+		m.mu.Unlock()
+	}
+}
+
+// purge drops finished jobs older than the retention period.
+// The caller must hold m.mu.
+func (m *Manager) purge() {
+	for id, job := range m.jobs {
+		if job.expired(m.retention) {
+			job.cleanup()
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func newID() string {
+	b := make([]byte, 16) //nolint:gomnd
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager(t *testing.T) {
+	m := NewManager(time.Hour)
+
+	job := m.Start("alice", "copy", func(ctx context.Context, job *Job) error {
+		job.SetTotal(10, 2)
+		job.AddBytes(10)
+		job.AddFiles(2)
+		return nil
+	})
+	<-job.Done()
+
+	info := job.Info()
+	require.Equal(t, StatusDone, info.Status)
+	require.Equal(t, int64(10), info.BytesDone)
+	require.Equal(t, int64(2), info.FilesDone)
+	require.NotNil(t, info.Finished)
+
+	_, err := m.Get("bob", job.ID())
+	require.Error(t, err)
+	require.Len(t, m.List("alice"), 1)
+	require.Empty(t, m.List("bob"))
+
+	failed := m.Start("alice", "delete", func(ctx context.Context, job *Job) error {
+		return errors.New("boom")
+	})
+	<-failed.Done()
+	require.Equal(t, StatusFailed, failed.Info().Status)
+	require.Equal(t, "boom", failed.Info().Error)
+}
+
+func TestManagerCancel(t *testing.T) {
+	m := NewManager(time.Hour)
+
+	job := m.Start("alice", "copy", func(ctx context.Context, job *Job) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	require.NoError(t, m.Cancel("alice", job.ID()))
+	<-job.Done()
+	require.Equal(t, StatusCanceled, job.Info().Status)
+}
+
+func TestManagerRetention(t *testing.T) {
+	m := NewManager(0)
+
+	job := m.Start("alice", "copy", func(ctx context.Context, job *Job) error {
+		return nil
+	})
+	<-job.Done()
+	time.Sleep(time.Millisecond)
+
+	_, err := m.Get("alice", job.ID())
+	require.Error(t, err)
+}
+
+func TestManagerRun(t *testing.T) {
+	m := NewManager(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx, time.Millisecond)
+
+	artifact, err := os.CreateTemp(t.TempDir(), "artifact")
+	require.NoError(t, err)
+	require.NoError(t, artifact.Close())
+
+	job := m.Start("alice", "compress", func(ctx context.Context, job *Job) error {
+		job.SetArtifact(artifact.Name(), "archive.zip")
+		return nil
+	})
+	<-job.Done()
+
+	// the artifact is removed without any further call to the manager
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(artifact.Name()) //nolint:govet
+		return os.IsNotExist(err)
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,172 @@
+// Package preview runs the external commands generating previews,
+// collapses the concurrent generations of the same preview and indexes
+// the hashes of the contents of the previewed files.
+package preview
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTimeout is the timeout of the commands if none is set.
+	DefaultTimeout = 30 * time.Second
+	// DefaultMaxConcurrent is the number of commands running at once
+	// if no limit is set.
+	DefaultMaxConcurrent = 2
+	// maxOutputSize is the size of the largest image read from a command.
+	maxOutputSize = 32 << 20
+	// maxStderrSize is the size of the command errors kept for the logs.
+	maxStderrSize = 4 << 10
+)
+
+var (
+	ErrEmptyCommand = errors.New("empty preview command")
+	ErrOutputLimit  = errors.New("preview command output is too large")
+)
+
+// Runner runs the preview commands with a limit on the number of
+// commands running at once.
+type Runner struct {
+	mu      sync.Mutex
+	running int
+	// released is closed and replaced whenever a command ends.
+	released chan struct{}
+}
+
+// NewRunner creates a runner.
+func NewRunner() *Runner {
+	return &Runner{released: make(chan struct{})}
+}
+
+// Options are the options of a command.
+type Options struct {
+	// Input is the path of the file to preview.
+	Input string
+	// Size is the largest dimension of the preview.
+	Size int
+	// Timeout is the time after which the command is killed.
+	Timeout time.Duration
+	// MaxConcurrent is the number of commands running at once.
+	MaxConcurrent int
+}
+
+// Run runs command, whose arguments may refer to the input file as
+// $FILE and to the size of the preview as $SIZE, and returns what it
+// wrote to its standard output. It waits for the running commands to
+// be fewer than opts.MaxConcurrent first.
+func (r *Runner) Run(ctx context.Context, command []string, opts Options) ([]byte, error) {
+	if len(command) == 0 {
+		return nil, ErrEmptyCommand
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxConcurrent <= 0 {
+		opts.MaxConcurrent = DefaultMaxConcurrent
+	}
+
+	if err := r.acquire(ctx, opts.MaxConcurrent); err != nil {
+		return nil, err
+	}
+	defer r.release()
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	mapping := func(key string) string {
+		switch key {
+		case "FILE":
+			return opts.Input
+		case "SIZE":
+			return strconv.Itoa(opts.Size)
+		default:
+			return "$" + key
+		}
+	}
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = os.Expand(arg, mapping)
+	}
+
+	stdout := &limitedBuffer{max: maxOutputSize}
+	stderr := &limitedBuffer{max: maxStderrSize, truncate: true}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) //nolint:gosec
+	cmd.Env = append(os.Environ(), "FILE="+opts.Input, "SIZE="+strconv.Itoa(opts.Size))
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		switch {
+		case stdout.exceeded:
+			return nil, ErrOutputLimit
+		case ctx.Err() != nil:
+			return nil, fmt.Errorf("%s: %w", args[0], ctx.Err())
+		default:
+			return nil, fmt.Errorf("%s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("%s: no output: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func (r *Runner) acquire(ctx context.Context, limit int) error {
+	for {
+		r.mu.Lock()
+		if r.running < limit {
+			r.running++
+			r.mu.Unlock()
+			return nil
+		}
+		released := r.released
+		r.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *Runner) release() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.running--
+	close(r.released)
+	r.released = make(chan struct{})
+}
+
+// limitedBuffer fails the writes past max bytes, or ignores them if
+// truncate is set.
+type limitedBuffer struct {
+	bytes.Buffer
+	max      int
+	truncate bool
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); len(p) > room {
+		if !b.truncate {
+			b.exceeded = true
+			return 0, ErrOutputLimit
+		}
+		b.Buffer.Write(p[:room])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,100 @@
+package preview
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnerRun(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "in.bin")
+	require.NoError(t, os.WriteFile(input, []byte("image data"), 0644))
+
+	testCases := map[string]struct {
+		command []string
+		opts    Options
+		want    string
+		wantErr bool
+	}{
+		"arguments": {
+			command: []string{"sh", "-c", `cat "$1"; printf " %s" "$2"`, "sh", "$FILE", "$SIZE"},
+			opts:    Options{Input: input, Size: 256},
+			want:    "image data 256",
+		},
+		"environment": {
+			command: []string{"sh", "-c", `cat "$FILE"`},
+			opts:    Options{Input: input},
+			want:    "image data",
+		},
+		"failure": {
+			command: []string{"sh", "-c", "echo broken >&2; exit 1"},
+			wantErr: true,
+		},
+		"no output": {
+			command: []string{"true"},
+			wantErr: true,
+		},
+		"timeout": {
+			command: []string{"sleep", "5"},
+			opts:    Options{Timeout: 50 * time.Millisecond},
+			wantErr: true,
+		},
+		"empty command": {
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			out, err := NewRunner().Run(context.Background(), tc.command, tc.opts)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, string(out))
+		})
+	}
+}
+
+func TestRunnerConcurrency(t *testing.T) {
+	runner := NewRunner()
+	command := []string{"sh", "-c", "sleep 0.05; echo done"}
+
+	maxRunning := 0
+	wg := sync.WaitGroup{}
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := runner.Run(context.Background(), command, Options{MaxConcurrent: 2})
+			require.NoError(t, err)
+		}()
+	}
+
+	// sample the number of commands holding a slot while they run
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			require.LessOrEqual(t, maxRunning, 2)
+			require.Greater(t, maxRunning, 0)
+			return
+		case <-time.After(5 * time.Millisecond):
+			runner.mu.Lock()
+			if runner.running > maxRunning {
+				maxRunning = runner.running
+			}
+			runner.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package preview
+
+import (
+	"context"
+	"sync"
+)
+
+// Group collapses the concurrent calls generating the same preview into
+// one. The preview is generated in its own context, which is canceled
+// once all the callers gave up waiting for it.
+type Group struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done    chan struct{}
+	result  []byte
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// NewGroup creates a group.
+func NewGroup() *Group {
+	return &Group{flights: map[string]*flight{}}
+}
+
+// Do returns the result of fn, which is only called if no call with the
+// same key is in flight.
+func (g *Group) Do(ctx context.Context, key string, fn func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	f, ok := g.flights[key]
+	if !ok {
+		flightCtx, cancel := context.WithCancel(context.Background())
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		g.flights[key] = f
+
+		go func() {
+			defer cancel()
+			f.result, f.err = fn(flightCtx)
+			g.forget(key, f)
+			close(f.done)
+		}()
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.result, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		abandoned := f.waiters == 0
+		if abandoned && g.flights[key] == f {
+			// the next callers start over instead of getting the
+			// cancellation error
+			delete(g.flights, key)
+		}
+		g.mu.Unlock()
+
+		if abandoned {
+			f.cancel()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (g *Group) forget(key string, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
@@ -0,0 +1,76 @@
+package preview
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupDo(t *testing.T) {
+	g := NewGroup()
+	release := make(chan struct{})
+	calls := 0
+
+	fn := func(ctx context.Context) ([]byte, error) {
+		calls++
+		<-release
+		return []byte("preview"), nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([][]byte, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := g.Do(context.Background(), "key", fn)
+			require.NoError(t, err)
+			results[i] = result
+		}(i)
+	}
+
+	// wait for all the callers to join the flight
+	for {
+		g.mu.Lock()
+		f := g.flights["key"]
+		joined := f != nil && f.waiters == len(results)
+		g.mu.Unlock()
+		if joined {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, 1, calls)
+	for _, result := range results {
+		require.Equal(t, "preview", string(result))
+	}
+}
+
+func TestGroupDoCanceled(t *testing.T) {
+	g := NewGroup()
+	canceled := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancel()
+	_, err := g.Do(ctx, "key", func(ctx context.Context) ([]byte, error) {
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	})
+	require.ErrorIs(t, err, context.Canceled)
+
+	// the work is canceled along with its only caller
+	<-canceled
+
+	result, err := g.Do(context.Background(), "key", func(ctx context.Context) ([]byte, error) {
+		return []byte("preview"), nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "preview", string(result))
+}
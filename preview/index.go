@@ -0,0 +1,94 @@
+package preview
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// indexKeyPrefix prefixes the keys of the entries of an Index.
+const indexKeyPrefix = "preview-index:"
+
+// Cache is where an Index keeps its entries, such as the cache of the
+// previews.
+type Cache interface {
+	Store(ctx context.Context, key string, value []byte) error
+	Load(ctx context.Context, key string) ([]byte, bool, error)
+	Delete(ctx context.Context, key string) error
+	// Record records key as the key of an entry about the file at name,
+	// so that the entry is deleted with the file.
+	Record(ctx context.Context, name, key string) error
+}
+
+// Index maps the paths of the files to the hashes of their contents,
+// so that the previews of identical files are made once. A file is
+// hashed again when its size or modification time change.
+type Index struct {
+	cache Cache
+}
+
+type indexEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Hash    string    `json:"hash"`
+}
+
+// NewIndex creates an index kept in cache.
+func NewIndex(cache Cache) *Index {
+	return &Index{cache: cache}
+}
+
+// Hash returns the hash of the contents of the file at name, which is
+// read with open unless it was hashed at the same size and modification
+// time.
+func (ix *Index) Hash(ctx context.Context, name string, size int64, modTime time.Time,
+	open func() (io.ReadCloser, error)) (string, error) {
+	key := IndexKey(name)
+	data, ok, err := ix.cache.Load(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		var entry indexEntry
+		if err := json.Unmarshal(data, &entry); err == nil && entry.Size == size && entry.ModTime.Equal(modTime) {
+			return entry.Hash, nil
+		}
+	}
+
+	r, err := open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, r); err != nil { //nolint:govet
+		return "", err
+	}
+	entry := indexEntry{Size: size, ModTime: modTime, Hash: hex.EncodeToString(hasher.Sum(nil))}
+
+	data, err = json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	if err := ix.cache.Store(ctx, key, data); err != nil {
+		return "", err
+	}
+	if err := ix.cache.Record(ctx, name, key); err != nil {
+		return "", err
+	}
+	return entry.Hash, nil
+}
+
+// Remove forgets the hash of the file at name.
+func (ix *Index) Remove(ctx context.Context, name string) error {
+	return ix.cache.Delete(ctx, IndexKey(name))
+}
+
+// IndexKey returns the key of the entry of the file at name.
+func IndexKey(name string) string {
+	return indexKeyPrefix + name
+}
@@ -0,0 +1,77 @@
+package preview
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type memCache map[string][]byte
+
+func (c memCache) Store(ctx context.Context, key string, value []byte) error {
+	c[key] = value
+	return nil
+}
+
+func (c memCache) Load(ctx context.Context, key string) ([]byte, bool, error) {
+	value, ok := c[key]
+	return value, ok, nil
+}
+
+func (c memCache) Delete(ctx context.Context, key string) error {
+	delete(c, key)
+	return nil
+}
+
+// Record keeps the last key recorded for name as the entry record:name.
+func (c memCache) Record(ctx context.Context, name, key string) error {
+	c["record:"+name] = []byte(key)
+	return nil
+}
+
+func TestIndexHash(t *testing.T) {
+	ctx := context.Background()
+	modTime := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	cache := memCache{}
+	ix := NewIndex(cache)
+	opened := 0
+	open := func(content string) func() (io.ReadCloser, error) {
+		return func() (io.ReadCloser, error) {
+			opened++
+			return io.NopCloser(strings.NewReader(content)), nil
+		}
+	}
+
+	hash, err := ix.Hash(ctx, "/a/photo.jpg", 5, modTime, open("photo"))
+	require.NoError(t, err)
+	require.Equal(t, 1, opened)
+	require.Equal(t, IndexKey("/a/photo.jpg"), string(cache["record:/a/photo.jpg"]))
+
+	// unchanged files aren't read again
+	indexed, err := ix.Hash(ctx, "/a/photo.jpg", 5, modTime, open("photo"))
+	require.NoError(t, err)
+	require.Equal(t, hash, indexed)
+	require.Equal(t, 1, opened)
+
+	// identical files share their hash
+	copied, err := ix.Hash(ctx, "/b/copy.jpg", 5, modTime, open("photo"))
+	require.NoError(t, err)
+	require.Equal(t, hash, copied)
+	require.Equal(t, 2, opened)
+
+	// modified files are hashed again
+	modified, err := ix.Hash(ctx, "/a/photo.jpg", 6, modTime.Add(time.Second), open("photo2"))
+	require.NoError(t, err)
+	require.NotEqual(t, hash, modified)
+	require.Equal(t, 3, opened)
+
+	require.NoError(t, ix.Remove(ctx, "/a/photo.jpg"))
+	_, err = ix.Hash(ctx, "/a/photo.jpg", 6, modTime.Add(time.Second), open("photo2"))
+	require.NoError(t, err)
+	require.Equal(t, 4, opened)
+}
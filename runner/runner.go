@@ -19,15 +19,8 @@ type Runner struct {
 
 // RunHook runs the hooks for the before and after event.
 func (r *Runner) RunHook(fn func() error, evt, path, dst string, token *users.TokenStruct) error {
-	if r.Enabled {
-		if val, ok := r.Commands["before_"+evt]; ok {
-			for _, command := range val {
-				err := r.exec(command, "before_"+evt, path, dst, token)
-				if err != nil {
-					return err
-				}
-			}
-		}
+	if err := r.RunBefore(evt, path, dst, token); err != nil {
+		return err
 	}
 
 	err := fn()
@@ -35,14 +28,30 @@ func (r *Runner) RunHook(fn func() error, evt, path, dst string, token *users.To
 		return err
 	}
 
-	if r.Enabled {
-		if val, ok := r.Commands["after_"+evt]; ok {
-			for _, command := range val {
-				err := r.exec(command, "after_"+evt, path, dst, token)
-				if err != nil {
-					return err
-				}
-			}
+	return r.RunAfter(evt, path, dst, token)
+}
+
+// RunBefore runs the hooks for the before event. It is meant for
+// operations that can't be wrapped by RunHook.
+func (r *Runner) RunBefore(evt, path, dst string, token *users.TokenStruct) error {
+	return r.run("before_"+evt, path, dst, token)
+}
+
+// RunAfter runs the hooks for the after event. It is meant for
+// operations that can't be wrapped by RunHook.
+func (r *Runner) RunAfter(evt, path, dst string, token *users.TokenStruct) error {
+	return r.run("after_"+evt, path, dst, token)
+}
+
+func (r *Runner) run(evt, path, dst string, token *users.TokenStruct) error {
+	if !r.Enabled {
+		return nil
+	}
+
+	for _, command := range r.Commands[evt] {
+		err := r.exec(command, evt, path, dst, token)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -1,14 +1,15 @@
 package search
 
 import (
-	"mime"
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"github.com/filebrowser/filebrowser/v2/filetype"
 )
 
 var (
-	typeRegexp = regexp.MustCompile(`type:(\w+)`)
+	typeRegexp = regexp.MustCompile(`type:([\w-]+)`)
 )
 
 type condition func(path string) bool
@@ -19,25 +20,23 @@ func extensionCondition(extension string) condition {
 	}
 }
 
-func imageCondition(path string) bool {
-	extension := filepath.Ext(path)
-	mimetype := mime.TypeByExtension(extension)
-
-	return strings.HasPrefix(mimetype, "image")
-}
-
-func audioCondition(path string) bool {
-	extension := filepath.Ext(path)
-	mimetype := mime.TypeByExtension(extension)
-
-	return strings.HasPrefix(mimetype, "audio")
+func categoryCondition(category filetype.Category) condition {
+	return func(path string) bool {
+		return filetype.ByName(path).Category == category
+	}
 }
 
-func videoCondition(path string) bool {
-	extension := filepath.Ext(path)
-	mimetype := mime.TypeByExtension(extension)
-
-	return strings.HasPrefix(mimetype, "video")
+// categoryByName returns the category named in a type: condition.
+func categoryByName(name string) (filetype.Category, bool) {
+	if name == "music" {
+		return filetype.Audio, true
+	}
+	for _, category := range filetype.Categories {
+		if string(category) == name {
+			return category, true
+		}
+	}
+	return "", false
 }
 
 func parseSearch(value string) *searchOptions {
@@ -58,14 +57,9 @@ func parseSearch(value string) *searchOptions {
 			continue
 		}
 
-		switch t[1] {
-		case "image":
-			opts.Conditions = append(opts.Conditions, imageCondition)
-		case "audio", "music":
-			opts.Conditions = append(opts.Conditions, audioCondition)
-		case "video":
-			opts.Conditions = append(opts.Conditions, videoCondition)
-		default:
+		if category, ok := categoryByName(t[1]); ok {
+			opts.Conditions = append(opts.Conditions, categoryCondition(category))
+		} else {
 			opts.Conditions = append(opts.Conditions, extensionCondition(t[1]))
 		}
 	}
@@ -0,0 +1,93 @@
+package settings
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/img"
+)
+
+// DefaultPreviewPresets are the preview sizes used when none is set.
+var DefaultPreviewPresets = []PreviewPreset{
+	{Name: "thumb", Width: 256, Height: 256, Mode: img.ResizeModeFill, Quality: img.QualityLow, Thumbnail: true},
+	{Name: "thumb@2x", Width: 512, Height: 512, Mode: img.ResizeModeFill, Quality: img.QualityMedium, Thumbnail: true},
+	{Name: "big", Width: 1080, Height: 1080, Mode: img.ResizeModeFit, Quality: img.QualityMedium},
+	{Name: "big@2x", Width: 2160, Height: 2160, Mode: img.ResizeModeFit, Quality: img.QualityMedium},
+}
+
+// Previews contains the settings of the previews.
+type Previews struct {
+	// Presets are the sizes previews are made at, DefaultPreviewPresets
+	// if empty.
+	Presets    []PreviewPreset    `json:"presets"`
+	Generators []PreviewGenerator `json:"generators"`
+	// MaxConcurrent is the number of generators running at once.
+	MaxConcurrent int `json:"maxConcurrent"`
+	// Timeout is the time after which a generator is killed.
+	Timeout time.Duration `json:"timeout"`
+	// ContentKeys keys the previews by the hash of the contents of the
+	// files rather than by their path and modification time, so that
+	// identical files share their previews.
+	ContentKeys bool `json:"contentKeys"`
+}
+
+// PreviewPreset is a size previews are made at.
+type PreviewPreset struct {
+	Name    string         `json:"name"`
+	Width   int            `json:"width"`
+	Height  int            `json:"height"`
+	Mode    img.ResizeMode `json:"mode"`
+	Quality img.Quality    `json:"quality"`
+	// Thumbnail marks the presets used in listings, which are disabled
+	// along with the thumbnails. The others are disabled along with
+	// the resize of the previews.
+	Thumbnail bool `json:"thumbnail"`
+}
+
+// AllPresets returns the presets, or the default ones if none is set.
+func (p *Previews) AllPresets() []PreviewPreset {
+	if len(p.Presets) == 0 {
+		return DefaultPreviewPresets
+	}
+	return p.Presets
+}
+
+// Preset returns the preset called name. The variants for high density
+// screens, such as thumb@2x, fall back to their base preset if missing.
+func (p *Previews) Preset(name string) (PreviewPreset, bool) {
+	presets := p.AllPresets()
+	for _, preset := range presets {
+		if preset.Name == name {
+			return preset, true
+		}
+	}
+
+	if base, _, ok := strings.Cut(name, "@"); ok {
+		return p.Preset(base)
+	}
+	return PreviewPreset{}, false
+}
+
+// PreviewGenerator is an external command writing to its standard
+// output an image previewing the file whose path is given as $FILE.
+// $SIZE is replaced by the largest dimension of the preview.
+type PreviewGenerator struct {
+	// MimeTypes are the patterns of the MIME types of the files, such
+	// as video/* or application/pdf.
+	MimeTypes []string `json:"mimeTypes"`
+	Command   []string `json:"command"`
+}
+
+// Generator returns the first generator of the MIME type, if any.
+func (p *Previews) Generator(mimetype string) (*PreviewGenerator, bool) {
+	mimetype = strings.ToLower(mimetype)
+	for i, gen := range p.Generators {
+		for _, pattern := range gen.MimeTypes {
+			if ok, _ := path.Match(strings.ToLower(pattern), mimetype); ok {
+				return &p.Generators[i], true
+			}
+		}
+	}
+	return nil, false
+}
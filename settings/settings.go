@@ -17,6 +17,9 @@ type Settings struct {
 	UserHomeBasePath string              `json:"userHomeBasePath"`
 	Defaults         UserDefaults        `json:"defaults"`
 	Branding         Branding            `json:"branding"`
+	Trash            Trash               `json:"trash"`
+	Versions         Versions            `json:"versions"`
+	Previews         Previews            `json:"previews"`
 	Commands         map[string][]string `json:"commands"`
 	Shell            []string            `json:"shell"`
 	Rules            []rules.Rule        `json:"rules"`
@@ -46,6 +49,8 @@ type Server struct {
 	TokenSecret            string `json:"tokenSecret"`
 	TokenCredentialsSecret string `json:"tokenCredentialsSecret"`
 	MountScriptPath        string `json:"mountScriptPath"`
+	ExtractMaxSize         int64  `json:"extractMaxSize"`
+	ExtractMaxEntries      int    `json:"extractMaxEntries"`
 }
 
 // Clean cleans any variables that might need cleaning.
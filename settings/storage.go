@@ -33,6 +33,15 @@ func (s *Storage) Get() (*Settings, error) {
 	if set.UserHomeBasePath == "" {
 		set.UserHomeBasePath = DefaultUsersHomeBasePath
 	}
+	// the settings without retention have the default one, see
+	// Trash.Retention
+	if set.Trash.Retention == 0 {
+		set.Trash.Retention = DefaultTrashRetention
+	}
+	// likewise for the versions, see Versions.MaxCount
+	if set.Versions.MaxCount == 0 {
+		set.Versions.MaxCount = DefaultVersionsMaxCount
+	}
 	return set, nil
 }
 
@@ -42,6 +51,9 @@ var defaultEvents = []string{
 	"rename",
 	"upload",
 	"delete",
+	"restore",
+	"extract",
+	"compress",
 }
 
 // Save saves the settings for the current instance.
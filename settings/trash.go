@@ -0,0 +1,16 @@
+package settings
+
+import "time"
+
+// DefaultTrashRetention is the retention of the settings that have
+// none.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// Trash contains the recycle bin settings of the app.
+type Trash struct {
+	Disabled bool `json:"disabled"`
+	// Retention is how long deleted items are kept. A negative one keeps
+	// them forever, and zero, which the settings saved before the trash
+	// existed have, stands for DefaultTrashRetention.
+	Retention time.Duration `json:"retention"`
+}
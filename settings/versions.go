@@ -0,0 +1,18 @@
+package settings
+
+import "time"
+
+// DefaultVersionsMaxCount is the maximum count of the settings that
+// have none.
+const DefaultVersionsMaxCount = 10
+
+// Versions contains the file version history settings of the app.
+// Versions are kept while they are one of the last MaxCount versions
+// of a file or while they are younger than MaxAge.
+type Versions struct {
+	// MaxCount is negative to keep the versions by age only. Zero, which
+	// the settings saved before the versions existed have, stands for
+	// DefaultVersionsMaxCount.
+	MaxCount int           `json:"maxCount"`
+	MaxAge   time.Duration `json:"maxAge"`
+}
@@ -6,12 +6,14 @@ import (
 	"github.com/filebrowser/filebrowser/v2/settings"
 	"github.com/filebrowser/filebrowser/v2/share"
 	"github.com/filebrowser/filebrowser/v2/storage"
+	"github.com/filebrowser/filebrowser/v2/trash"
 )
 
 // NewStorage creates a storage.Storage based on Bolt DB.
 func NewStorage(db *storm.DB) (*storage.Storage, error) {
 	shareStore := share.NewStorage(shareBackend{db: db})
 	settingsStore := settings.NewStorage(settingsBackend{db: db})
+	trashScopesStore := trash.NewScopes(trashScopesBackend{db: db})
 
 	err := save(db, "version", 2) //nolint:gomnd
 	if err != nil {
@@ -19,7 +21,8 @@ func NewStorage(db *storm.DB) (*storage.Storage, error) {
 	}
 
 	return &storage.Storage{
-		Share:    shareStore,
-		Settings: settingsStore,
+		Share:       shareStore,
+		Settings:    settingsStore,
+		TrashScopes: trashScopesStore,
 	}, nil
 }
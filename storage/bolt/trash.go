@@ -0,0 +1,18 @@
+package bolt
+
+import (
+	"github.com/asdine/storm/v3"
+)
+
+type trashScopesBackend struct {
+	db *storm.DB
+}
+
+func (s trashScopesBackend) Get() ([]string, error) {
+	var scopes []string
+	return scopes, get(s.db, "trashScopes", &scopes)
+}
+
+func (s trashScopesBackend) Save(scopes []string) error {
+	return save(s.db, "trashScopes", scopes)
+}
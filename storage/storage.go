@@ -3,11 +3,13 @@ package storage
 import (
 	"github.com/filebrowser/filebrowser/v2/settings"
 	"github.com/filebrowser/filebrowser/v2/share"
+	"github.com/filebrowser/filebrowser/v2/trash"
 )
 
 // Storage is a storage powered by a Backend which makes the necessary
 // verifications when fetching and saving data to ensure consistency.
 type Storage struct {
-	Share    *share.Storage
-	Settings *settings.Storage
+	Share       *share.Storage
+	Settings    *settings.Storage
+	TrashScopes *trash.Scopes
 }
@@ -0,0 +1,213 @@
+// Package subtitles converts subtitle files to WebVTT, the only format
+// browsers play.
+package subtitles
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// Formats are the extensions of the supported subtitle files.
+var Formats = []string{".vtt", ".srt", ".ass", ".ssa"}
+
+// IsFormat returns whether ext is the extension of a supported
+// subtitle file. It is case insensitive.
+func IsFormat(ext string) bool {
+	ext = strings.ToLower(ext)
+	for _, format := range Formats {
+		if ext == format {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	srtTiming    = regexp.MustCompile(`(\d+:\d{2}:\d{2}),(\d{3})`)
+	assOverrides = regexp.MustCompile(`\{[^}]*\}`)
+)
+
+// ToVTT writes the subtitles of src, a UTF-8 file of the given format,
+// as WebVTT.
+func ToVTT(w io.Writer, src []byte, ext string) error {
+	src = bytes.TrimPrefix(src, []byte("\xEF\xBB\xBF"))
+	src = bytes.ReplaceAll(src, []byte("\r\n"), []byte("\n"))
+
+	switch strings.ToLower(ext) {
+	case ".vtt":
+		_, err := w.Write(src)
+		return err
+	case ".srt":
+		return srtToVTT(w, src)
+	case ".ass", ".ssa":
+		return assToVTT(w, src)
+	default:
+		return errors.ErrUnsupportedSubtitles
+	}
+}
+
+// srtToVTT only has to add the header and replace the decimal commas of
+// the timings, the cues are otherwise compatible.
+func srtToVTT(w io.Writer, src []byte) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString("WEBVTT\n\n"); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	scanner.Buffer(nil, len(src)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.Contains(line, "-->") {
+			line = srtTiming.ReplaceAllString(line, "$1.$2")
+		}
+		if _, err := bw.WriteString(line + "\n"); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+type assCue struct {
+	start, end int64 // in milliseconds
+	text       string
+}
+
+// assToVTT converts the dialogue lines of the [Events] section. The
+// styles and the positioning are dropped.
+func assToVTT(w io.Writer, src []byte) error {
+	var (
+		inEvents bool
+		fields   []string
+		cues     []assCue
+	)
+
+	for _, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inEvents = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		if !inEvents {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Format":
+			fields = strings.Split(value, ",")
+			for i := range fields {
+				fields[i] = strings.TrimSpace(fields[i])
+			}
+		case "Dialogue":
+			if cue, ok := parseASSDialogue(fields, value); ok {
+				cues = append(cues, cue)
+			}
+		}
+	}
+
+	if fields == nil {
+		return errors.ErrUnsupportedSubtitles
+	}
+
+	sort.SliceStable(cues, func(i, j int) bool { return cues[i].start < cues[j].start })
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString("WEBVTT\n"); err != nil {
+		return err
+	}
+	for _, cue := range cues {
+		if _, err := fmt.Fprintf(bw, "\n%s --> %s\n%s\n",
+			vttTime(cue.start), vttTime(cue.end), cue.text); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func parseASSDialogue(fields []string, value string) (assCue, bool) {
+	if len(fields) == 0 {
+		return assCue{}, false
+	}
+
+	// the text is the last field and may contain commas
+	values := strings.SplitN(value, ",", len(fields))
+	if len(values) != len(fields) {
+		return assCue{}, false
+	}
+
+	var cue assCue
+	var startOk, endOk bool
+	for i, field := range fields {
+		v := strings.TrimSpace(values[i])
+		switch field {
+		case "Start":
+			cue.start, startOk = parseASSTime(v)
+		case "End":
+			cue.end, endOk = parseASSTime(v)
+		case "Text":
+			cue.text = assText(values[i])
+		}
+	}
+	if !startOk || !endOk || cue.text == "" {
+		return assCue{}, false
+	}
+
+	return cue, true
+}
+
+// parseASSTime parses a H:MM:SS.cc time.
+func parseASSTime(s string) (int64, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 { //nolint:gomnd
+		return 0, false
+	}
+
+	hours, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	minutes, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	seconds, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return (hours*3600+minutes*60)*1000 + int64(seconds*1000+0.5), true //nolint:gomnd
+}
+
+func assText(s string) string {
+	s = assOverrides.ReplaceAllString(s, "")
+	s = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(s)
+	// the text mustn't contain blank lines, which end a cue
+	lines := []string{}
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, strings.ReplaceAll(line, "-->", "->"))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func vttTime(ms int64) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000) //nolint:gomnd
+}
@@ -0,0 +1,65 @@
+package subtitles
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+)
+
+func TestToVTT(t *testing.T) {
+	testCases := map[string]struct {
+		ext     string
+		src     string
+		want    string
+		wantErr error
+	}{
+		"srt": {
+			ext: ".srt",
+			src: "\xEF\xBB\xBF1\r\n00:00:01,500 --> 00:00:03,250\r\nHello, world\r\n\r\n" +
+				"2\r\n00:01:00,000 --> 01:00:00,000\r\nBye\r\n",
+			want: "WEBVTT\n\n1\n00:00:01.500 --> 00:00:03.250\nHello, world\n\n" +
+				"2\n00:01:00.000 --> 01:00:00.000\nBye\n",
+		},
+		"ass": {
+			ext: ".ASS",
+			src: "[Script Info]\nTitle: test\n\n[V4+ Styles]\nFormat: Name, Fontname\nStyle: Default,Arial\n\n" +
+				"[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n" +
+				"Dialogue: 0,0:00:05.00,0:00:06.50,Default,,0,0,0,,{\\i1}Second{\\i0}, line\\Nwrapped\n" +
+				"Comment: 0,0:00:00.00,0:00:01.00,Default,,0,0,0,,ignored\n" +
+				"Dialogue: 0,0:00:01.25,0:00:02.00,Default,,0,0,0,,First\n",
+			want: "WEBVTT\n\n00:00:01.250 --> 00:00:02.000\nFirst\n" +
+				"\n00:00:05.000 --> 00:00:06.500\nSecond, line\nwrapped\n",
+		},
+		"vtt": {
+			ext:  ".vtt",
+			src:  "WEBVTT\n\n00:01.000 --> 00:02.000\nHi\n",
+			want: "WEBVTT\n\n00:01.000 --> 00:02.000\nHi\n",
+		},
+		"ass without events": {
+			ext:     ".ssa",
+			src:     "[Script Info]\nTitle: test\n",
+			wantErr: errors.ErrUnsupportedSubtitles,
+		},
+		"unsupported": {
+			ext:     ".sub",
+			src:     "{1}{2}Hi",
+			wantErr: errors.ErrUnsupportedSubtitles,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			err := ToVTT(buf, []byte(tc.src), tc.ext)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, buf.String())
+		})
+	}
+}
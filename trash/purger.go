@@ -0,0 +1,82 @@
+package trash
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/versions"
+)
+
+// Purger purges the trashes and prunes the versions of the scopes kept
+// in a store, so that the items past the retention go even if nobody
+// opens their trash again after a restart.
+type Purger struct {
+	scopes *Scopes
+	// root is the directory the scopes are relative to.
+	root string
+}
+
+// NewPurger creates a purger of the scopes, relative to root, which
+// are in scopes.
+func NewPurger(scopes *Scopes, root string) *Purger {
+	return &Purger{scopes: scopes, root: root}
+}
+
+// Track adds scope to the purged ones.
+func (p *Purger) Track(scope string) error {
+	return p.scopes.Add(scope)
+}
+
+// Purge purges the trashes of the items deleted longer than the trash
+// retention of set ago, prunes the versions with the limits of set, and
+// logs the scopes that fail.
+func (p *Purger) Purge(ctx context.Context, set *settings.Settings) error {
+	scopes, err := p.scopes.All()
+	if err != nil {
+		return err
+	}
+
+	for _, scope := range scopes {
+		if err := ctx.Err(); err != nil { //nolint:govet
+			return err
+		}
+
+		fs := afero.NewBasePathFs(afero.NewOsFs(), filepath.Join(p.root, filepath.Join("/", scope))) //nolint:gocritic
+		if err := New(fs).Purge(ctx, set.Trash.Retention); err != nil && ctx.Err() == nil {
+			log.Printf("failed to purge trash of %q: %v", scope, err)
+		}
+		if err := versions.New(fs, set.Versions.MaxCount, set.Versions.MaxAge).PruneAll(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("failed to prune versions of %q: %v", scope, err)
+		}
+	}
+
+	return nil
+}
+
+// Run purges the trashes every interval, with the settings returned by
+// getSettings at that time, until ctx is done.
+func (p *Purger) Run(ctx context.Context, interval time.Duration, getSettings func() (*settings.Settings, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		set, err := getSettings()
+		if err == nil {
+			err = p.Purge(ctx, set)
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("failed to purge trashes: %v", err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package trash
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// ScopesBackend is the interface to implement for a storage of the
+// scopes that have a trash.
+type ScopesBackend interface {
+	Get() ([]string, error)
+	Save(scopes []string) error
+}
+
+// Scopes is the storage of the scopes that have a trash, which are
+// purged even if nobody opens their trash again.
+type Scopes struct {
+	back ScopesBackend
+
+	mu    sync.Mutex
+	known map[string]bool
+}
+
+// NewScopes creates a scopes storage from a backend.
+func NewScopes(back ScopesBackend) *Scopes {
+	return &Scopes{back: back}
+}
+
+// All returns the scopes that have a trash.
+func (s *Scopes) All() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	scopes := make([]string, 0, len(s.known))
+	for scope := range s.known {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return scopes, nil
+}
+
+// Add adds scope to the scopes that have a trash.
+func (s *Scopes) Add(scope string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	if s.known[scope] {
+		return nil
+	}
+
+	scopes := []string{scope}
+	for known := range s.known {
+		scopes = append(scopes, known)
+	}
+	sort.Strings(scopes)
+	if err := s.back.Save(scopes); err != nil {
+		return err
+	}
+	s.known[scope] = true
+	return nil
+}
+
+// load reads the scopes from the backend once. The caller must hold
+// s.mu.
+func (s *Scopes) load() error {
+	if s.known != nil {
+		return nil
+	}
+
+	scopes, err := s.back.Get()
+	if err != nil && err != errors.ErrNotExist {
+		return err
+	}
+
+	s.known = map[string]bool{}
+	for _, scope := range scopes {
+		s.known[scope] = true
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package trash
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/versions"
+)
+
+// Dir is the directory, relative to the scope root, where the
+// deleted items are kept.
+const Dir = "/.trash"
+
+// IsTrashPath reports whether p is the trash directory or is inside it.
+func IsTrashPath(p string) bool {
+	p = path.Clean("/" + p)
+	return p == Dir || strings.HasPrefix(p, Dir+"/")
+}
+
+// Item describes an item in the trash.
+type Item struct {
+	ID      string    `json:"id"`
+	Path    string    `json:"path"`
+	Name    string    `json:"name"`
+	IsDir   bool      `json:"isDir"`
+	Size    int64     `json:"size"`
+	Deleted time.Time `json:"deleted"`
+}
+
+// Trash is the recycle bin of a scope. Deleted items are moved to
+// Dir/files, their previous versions to Dir/versions, and their
+// metadata is recorded in Dir/info.
+type Trash struct {
+	fs afero.Fs
+}
+
+// New creates the trash of the scope represented by fs.
+func New(fs afero.Fs) *Trash {
+	return &Trash{fs: fs}
+}
+
+func (t *Trash) filePath(id string) string {
+	return path.Join(Dir, "files", id)
+}
+
+func (t *Trash) infoPath(id string) string {
+	return path.Join(Dir, "info", id+".json")
+}
+
+func (t *Trash) versionsPath(id string) string {
+	return path.Join(Dir, "versions", id)
+}
+
+// Move moves the file or directory at p into the trash.
+func (t *Trash) Move(p string) (*Item, error) {
+	p = path.Clean("/" + p)
+	if p == "/" || IsTrashPath(p) {
+		return nil, os.ErrInvalid
+	}
+
+	info, err := t.fs.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+
+	item := &Item{
+		ID:      newID(),
+		Path:    p,
+		Name:    info.Name(),
+		IsDir:   info.IsDir(),
+		Deleted: time.Now(),
+	}
+	if !info.IsDir() {
+		item.Size = info.Size()
+	}
+
+	if err := t.fs.MkdirAll(path.Join(Dir, "files"), 0700); err != nil { //nolint:gomnd
+		return nil, err
+	}
+	if err := t.fs.MkdirAll(path.Join(Dir, "info"), 0700); err != nil { //nolint:gomnd
+		return nil, err
+	}
+
+	if err := t.writeInfo(item); err != nil {
+		return nil, err
+	}
+
+	if err := move(t.fs, p, t.filePath(item.ID)); err != nil {
+		_ = t.fs.Remove(t.infoPath(item.ID))
+		return nil, err
+	}
+
+	// the previous versions go along with the item
+	if _, err := t.fs.Stat(versions.Path(p)); err == nil {
+		if err := t.fs.MkdirAll(path.Join(Dir, "versions"), 0700); err != nil { //nolint:gomnd
+			return nil, err
+		}
+		if err := move(t.fs, versions.Path(p), t.versionsPath(item.ID)); err != nil {
+			return nil, err
+		}
+	}
+
+	return item, nil
+}
+
+// List returns the items in the trash, most recently deleted first.
+func (t *Trash) List() ([]*Item, error) {
+	entries, err := afero.ReadDir(t.fs, path.Join(Dir, "info"))
+	if os.IsNotExist(err) {
+		return []*Item{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*Item{}
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		item, err := t.Get(id) //nolint:govet
+		if err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Deleted.After(items[j].Deleted)
+	})
+
+	return items, nil
+}
+
+// Get returns the item with the given id.
+func (t *Trash) Get(id string) (*Item, error) {
+	if !validID(id) {
+		return nil, errors.ErrNotExist
+	}
+
+	raw, err := afero.ReadFile(t.fs, t.infoPath(id))
+	if os.IsNotExist(err) {
+		return nil, errors.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	item := &Item{}
+	if err := json.Unmarshal(raw, item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// Restore moves an item back to dst, which usually is its
+// original path. Missing parent directories are created.
+func (t *Trash) Restore(id, dst string) error {
+	if _, err := t.Get(id); err != nil {
+		return err
+	}
+
+	if _, err := t.fs.Stat(dst); err == nil {
+		return errors.ErrExist
+	}
+
+	if err := t.fs.MkdirAll(path.Dir(dst), 0775); err != nil { //nolint:gomnd
+		return err
+	}
+
+	if err := move(t.fs, t.filePath(id), dst); err != nil {
+		return err
+	}
+
+	if _, err := t.fs.Stat(t.versionsPath(id)); err == nil {
+		if err := t.fs.MkdirAll(path.Dir(versions.Path(dst)), 0700); err != nil { //nolint:gomnd
+			return err
+		}
+		// the versions left at dst belong to no file
+		_ = t.fs.RemoveAll(versions.Path(dst))
+		if err := move(t.fs, t.versionsPath(id), versions.Path(dst)); err != nil {
+			return err
+		}
+	}
+
+	return t.fs.Remove(t.infoPath(id))
+}
+
+// Delete permanently deletes an item.
+func (t *Trash) Delete(ctx context.Context, id string, progress fileutils.Progress) error {
+	if _, err := t.Get(id); err != nil {
+		return err
+	}
+
+	err := fileutils.RemoveAllContext(ctx, t.fs, t.filePath(id), progress)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := t.fs.RemoveAll(t.versionsPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return t.fs.Remove(t.infoPath(id))
+}
+
+// Empty permanently deletes every item.
+func (t *Trash) Empty(ctx context.Context, progress fileutils.Progress) error {
+	items, err := t.List()
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := t.Delete(ctx, item.ID, progress); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Purge permanently deletes the items deleted more than
+// retention ago. A negative retention keeps items forever.
+func (t *Trash) Purge(ctx context.Context, retention time.Duration) error {
+	if retention < 0 {
+		return nil
+	}
+
+	items, err := t.List()
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if time.Since(item.Deleted) <= retention {
+			continue
+		}
+		if err := t.Delete(ctx, item.ID, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *Trash) writeInfo(item *Item) error {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	return afero.WriteFile(t.fs, t.infoPath(item.ID), raw, 0600) //nolint:gomnd
+}
+
+// move renames src to dst, falling back to copy and delete when
+// they live on different devices.
+func move(fs afero.Fs, src, dst string) error {
+	if fs.Rename(src, dst) == nil {
+		return nil
+	}
+
+	if err := fileutils.Copy(fs, src, dst); err != nil {
+		_ = fs.RemoveAll(dst)
+		return err
+	}
+
+	return fs.RemoveAll(src)
+}
+
+func newID() string {
+	b := make([]byte, 8) //nolint:gomnd
+	_, _ = rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b)
+}
+
+func validID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, "/\\") && id != "." && id != ".."
+}
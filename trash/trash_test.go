@@ -0,0 +1,172 @@
+package trash
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/versions"
+)
+
+func TestTrash(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/docs/report.txt", []byte("content"), 0644))
+
+	bin := New(fs)
+	item, err := bin.Move("/docs/report.txt")
+	require.NoError(t, err)
+	require.Equal(t, "/docs/report.txt", item.Path)
+	require.Equal(t, int64(7), item.Size)
+
+	exists, err := afero.Exists(fs, "/docs/report.txt")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	items, err := bin.List()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.Equal(t, item.ID, items[0].ID)
+
+	require.NoError(t, bin.Restore(item.ID, item.Path))
+	content, err := afero.ReadFile(fs, "/docs/report.txt")
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+
+	items, err = bin.List()
+	require.NoError(t, err)
+	require.Empty(t, items)
+
+	// items older than the retention are purged
+	item, err = bin.Move("/docs")
+	require.NoError(t, err)
+	require.True(t, item.IsDir)
+	require.NoError(t, bin.Purge(ctx, time.Hour))
+	items, err = bin.List()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+
+	item.Deleted = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, bin.writeInfo(item))
+	require.NoError(t, bin.Purge(ctx, time.Hour))
+	items, err = bin.List()
+	require.NoError(t, err)
+	require.Empty(t, items)
+}
+
+func TestIsTrashPath(t *testing.T) {
+	cases := map[string]bool{
+		"/.trash":            true,
+		"/.trash/files/abc":  true,
+		".trash/info":        true,
+		"/.trashcan":         false,
+		"/docs/.trash/files": false,
+		"/":                  false,
+	}
+
+	for p, want := range cases {
+		if got := IsTrashPath(p); got != want {
+			t.Errorf("IsTrashPath(%s)=%v; want %v", p, got, want)
+		}
+	}
+}
+
+// memScopes keeps the scopes in memory.
+type memScopes struct {
+	scopes []string
+}
+
+func (m *memScopes) Get() ([]string, error) {
+	if m.scopes == nil {
+		return nil, errors.ErrNotExist
+	}
+	return m.scopes, nil
+}
+
+func (m *memScopes) Save(scopes []string) error {
+	m.scopes = scopes
+	return nil
+}
+
+func TestPurger(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	fs := afero.NewBasePathFs(afero.NewOsFs(), filepath.Join(root, "alice"))
+	require.NoError(t, fs.MkdirAll("/", 0755))
+	require.NoError(t, afero.WriteFile(fs, "/old.txt", []byte("old"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/new.txt", []byte("new"), 0644))
+
+	bin := New(fs)
+	old, err := bin.Move("/old.txt")
+	require.NoError(t, err)
+	old.Deleted = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, bin.writeInfo(old))
+	_, err = bin.Move("/new.txt")
+	require.NoError(t, err)
+
+	set := &settings.Settings{Trash: settings.Trash{Retention: time.Hour}}
+
+	// untracked trashes are left alone
+	backend := &memScopes{}
+	require.NoError(t, NewPurger(NewScopes(backend), root).Purge(ctx, set))
+	items, err := bin.List()
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+
+	// a negative retention keeps the items forever
+	require.NoError(t, NewPurger(NewScopes(backend), root).Track("/alice"))
+	set.Trash.Retention = -1
+	require.NoError(t, NewPurger(NewScopes(backend), root).Purge(ctx, set))
+	items, err = bin.List()
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+
+	// the tracked scopes are purged by the purgers created afterwards,
+	// as they are after a restart
+	set.Trash.Retention = time.Hour
+	require.NoError(t, NewPurger(NewScopes(backend), root).Purge(ctx, set))
+	items, err = bin.List()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.Equal(t, "/new.txt", items[0].Path)
+}
+
+func TestTrash_Versions(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewBasePathFs(afero.NewOsFs(), t.TempDir())
+	store := versions.New(fs, 5, 0)
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("old"), 0644))
+	_, err := store.Save("/a.txt")
+	require.NoError(t, err)
+
+	// the versions go to the trash with the file and come back with it
+	bin := New(fs)
+	item, err := bin.Move("/a.txt")
+	require.NoError(t, err)
+	list, err := store.List("/a.txt")
+	require.NoError(t, err)
+	require.Empty(t, list)
+
+	require.NoError(t, bin.Restore(item.ID, "/b.txt"))
+	list, err = store.List("/b.txt")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	// and are deleted along with it
+	item, err = bin.Move("/b.txt")
+	require.NoError(t, err)
+	require.NoError(t, bin.Delete(ctx, item.ID, nil))
+	exists, err := afero.DirExists(fs, path.Join(Dir, "versions", item.ID))
+	require.NoError(t, err)
+	require.False(t, exists)
+	list, err = store.List("/b.txt")
+	require.NoError(t, err)
+	require.Empty(t, list)
+}
@@ -69,6 +69,9 @@ type TokenStruct struct {
 	HideDotfiles         bool                 `json:"hideDotfiles"`
 	EncryptedCredentials EncryptedCredentials `json:"credentiald"`
 	Raw                  string               `json:"raw"`
+	// Session identifies the session of the token, which owns the
+	// background jobs it starts.
+	Session string `json:"-" yaml:"-"`
 }
 
 type AuthToken struct {
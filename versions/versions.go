@@ -0,0 +1,288 @@
+package versions
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+)
+
+// Dir is the directory, relative to the scope root, where the
+// previous versions of the files are kept.
+const Dir = "/.versions"
+
+const idLayout = "20060102T150405.000000000Z"
+
+// IsVersionsPath reports whether p is the versions directory or is inside it.
+func IsVersionsPath(p string) bool {
+	p = path.Clean("/" + p)
+	return p == Dir || strings.HasPrefix(p, Dir+"/")
+}
+
+// Version describes a previous version of a file.
+type Version struct {
+	ID       string    `json:"id"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Saved    time.Time `json:"saved"`
+}
+
+// Store keeps the previous versions of the files of a scope. A version
+// is kept while it is one of the last MaxCount versions of its file or
+// while it is younger than MaxAge.
+type Store struct {
+	fs       afero.Fs
+	maxCount int
+	maxAge   time.Duration
+}
+
+// New creates the version store of the scope represented by fs.
+func New(fs afero.Fs, maxCount int, maxAge time.Duration) *Store {
+	return &Store{fs: fs, maxCount: maxCount, maxAge: maxAge}
+}
+
+// Enabled reports whether any version would be kept at all.
+func (s *Store) Enabled() bool {
+	return s.maxCount > 0 || s.maxAge > 0
+}
+
+// Path returns the directory keeping the versions of the file at p, or
+// the versions of the files under the directory at p.
+func Path(p string) string {
+	return path.Join(Dir, path.Clean("/"+p))
+}
+
+func (s *Store) dir(p string) string {
+	return Path(p)
+}
+
+// Save records the current content of the file at p as a new version.
+func (s *Store) Save(p string) (*Version, error) {
+	if !s.Enabled() || IsVersionsPath(p) {
+		return nil, nil
+	}
+
+	info, err := s.fs.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+
+	saved := time.Now().UTC()
+	id := saved.Format(idLayout)
+	dst := path.Join(s.dir(p), id)
+	if err := fileutils.CopyFile(s.fs, p, dst); err != nil { //nolint:govet
+		_ = s.fs.Remove(dst)
+		return nil, err
+	}
+	if err := s.fs.Chtimes(dst, info.ModTime(), info.ModTime()); err != nil { //nolint:govet
+		return nil, err
+	}
+
+	if err := s.Prune(p); err != nil { //nolint:govet
+		return nil, err
+	}
+
+	return &Version{ID: id, Size: info.Size(), Modified: info.ModTime(), Saved: saved}, nil
+}
+
+// List returns the versions of the file at p, newest first.
+func (s *Store) List(p string) ([]*Version, error) {
+	entries, err := afero.ReadDir(s.fs, s.dir(p))
+	if os.IsNotExist(err) {
+		return []*Version{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := []*Version{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		saved, err := time.Parse(idLayout, entry.Name())
+		if err != nil {
+			continue
+		}
+		list = append(list, &Version{
+			ID:       entry.Name(),
+			Size:     entry.Size(),
+			Modified: entry.ModTime(),
+			Saved:    saved,
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Saved.After(list[j].Saved)
+	})
+
+	return list, nil
+}
+
+// Open opens a version of the file at p for reading.
+func (s *Store) Open(p, id string) (afero.File, error) {
+	if _, err := time.Parse(idLayout, id); err != nil {
+		return nil, errors.ErrNotExist
+	}
+
+	fd, err := s.fs.Open(path.Join(s.dir(p), id))
+	if os.IsNotExist(err) {
+		return nil, errors.ErrNotExist
+	}
+
+	return fd, err
+}
+
+// Restore replaces the content of the file at p with one of its
+// versions. The current content is saved as a new version first.
+func (s *Store) Restore(p, id string) error {
+	src, err := s.Open(p, id)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if _, err = s.Save(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	dst, err := s.fs.OpenFile(p, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0775) //nolint:gomnd
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// Move moves the versions of the file at src so they follow it to dst.
+// The versions of a file replaced at dst are kept along with them.
+func (s *Store) Move(src, dst string) error {
+	if _, err := s.fs.Stat(s.dir(src)); err != nil {
+		return nil
+	}
+
+	if _, err := s.fs.Stat(s.dir(dst)); err == nil {
+		return s.merge(src, dst)
+	}
+
+	if err := s.fs.MkdirAll(path.Dir(s.dir(dst)), 0700); err != nil { //nolint:gomnd
+		return err
+	}
+
+	return s.fs.Rename(s.dir(src), s.dir(dst))
+}
+
+// merge moves the versions of the file at src, or of the files under
+// it, into the existing versions at dst, and prunes them.
+func (s *Store) merge(src, dst string) error {
+	entries, err := afero.ReadDir(s.fs, s.dir(src))
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		from := path.Join(src, entry.Name())
+		to := path.Join(dst, entry.Name())
+		if entry.IsDir() {
+			if _, err = s.fs.Stat(s.dir(to)); err == nil {
+				if err = s.merge(from, to); err != nil {
+					return err
+				}
+				continue
+			}
+		} else if _, err = time.Parse(idLayout, entry.Name()); err != nil {
+			continue
+		}
+
+		if err = s.fs.Rename(s.dir(from), s.dir(to)); err != nil {
+			return err
+		}
+	}
+
+	if err := s.fs.RemoveAll(s.dir(src)); err != nil {
+		return err
+	}
+	return s.Prune(dst)
+}
+
+// Remove deletes the versions of the file at p, or of the files under
+// the directory at p.
+func (s *Store) Remove(p string) error {
+	if err := s.fs.RemoveAll(s.dir(p)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Prune deletes the versions of the file at p that are no longer
+// covered by the retention settings.
+func (s *Store) Prune(p string) error {
+	list, err := s.List(p)
+	if err != nil {
+		return err
+	}
+
+	for i, version := range list {
+		if s.maxCount > 0 && i < s.maxCount {
+			continue
+		}
+		if s.maxAge > 0 && time.Since(version.Saved) <= s.maxAge {
+			continue
+		}
+		if err := s.fs.Remove(path.Join(s.dir(p), version.ID)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PruneAll prunes the versions of every file, including the ones no
+// longer saved, until ctx is done.
+func (s *Store) PruneAll(ctx context.Context) error {
+	files := map[string]bool{}
+	err := afero.Walk(s.fs, Dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil { //nolint:govet
+			return err
+		}
+		// the versions are the files of the directory named after their file
+		if _, err := time.Parse(idLayout, info.Name()); err == nil && !info.IsDir() { //nolint:govet
+			files[strings.TrimPrefix(path.Dir(filepath.ToSlash(name)), Dir)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for p := range files {
+		if err := s.Prune(p); err != nil {
+			return err
+		}
+		if list, err := s.List(p); err == nil && len(list) == 0 {
+			_ = s.fs.Remove(s.dir(p))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package versions
+
+import (
+	"context"
+	"io"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func saveContents(t *testing.T, fs afero.Fs, s *Store, p string, contents ...string) {
+	t.Helper()
+	require.NoError(t, fs.MkdirAll(path.Dir(p), 0755))
+	for _, content := range contents {
+		require.NoError(t, afero.WriteFile(fs, p, []byte(content), 0644))
+		_, err := s.Save(p)
+		require.NoError(t, err)
+	}
+}
+
+func versionContents(t *testing.T, s *Store, p string) []string {
+	t.Helper()
+	list, err := s.List(p)
+	require.NoError(t, err)
+
+	contents := []string{}
+	for _, version := range list {
+		fd, err := s.Open(p, version.ID)
+		require.NoError(t, err)
+		content, err := io.ReadAll(fd)
+		require.NoError(t, err)
+		require.NoError(t, fd.Close())
+		contents = append(contents, string(content))
+	}
+	return contents
+}
+
+func TestStore_Save(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	s := New(fs, 2, 0)
+
+	saveContents(t, fs, s, "/a.txt", "1", "2", "3")
+	require.Equal(t, []string{"3", "2"}, versionContents(t, s, "/a.txt"))
+
+	// the versions themselves have no versions
+	version, err := s.Save(path.Join(Path("/a.txt"), "x"))
+	require.NoError(t, err)
+	require.Nil(t, version)
+
+	version, err = New(fs, 0, 0).Save("/a.txt")
+	require.NoError(t, err)
+	require.Nil(t, version)
+}
+
+func TestStore_Restore(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	s := New(fs, 5, 0)
+
+	saveContents(t, fs, s, "/a.txt", "1", "2")
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("3"), 0644))
+
+	list, err := s.List("/a.txt")
+	require.NoError(t, err)
+	require.NoError(t, s.Restore("/a.txt", list[1].ID))
+
+	content, err := afero.ReadFile(fs, "/a.txt")
+	require.NoError(t, err)
+	require.Equal(t, "1", string(content))
+	require.Equal(t, []string{"3", "2", "1"}, versionContents(t, s, "/a.txt"))
+}
+
+func TestStore_Move(t *testing.T) {
+	tests := map[string]struct {
+		src, dst []string
+		maxCount int
+		wantSrc  []string
+		wantDst  []string
+	}{
+		"Move to a new path": {
+			src:      []string{"1", "2"},
+			maxCount: 5,
+			wantSrc:  []string{},
+			wantDst:  []string{"2", "1"},
+		},
+		"Merge with the versions of the replaced file": {
+			src:      []string{"a1"},
+			dst:      []string{"b1", "b2"},
+			maxCount: 5,
+			wantSrc:  []string{},
+			wantDst:  []string{"a1", "b2", "b1"},
+		},
+		"Prune the merged versions": {
+			src:      []string{"a1"},
+			dst:      []string{"b1", "b2"},
+			maxCount: 2,
+			wantSrc:  []string{},
+			wantDst:  []string{"a1", "b2"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// the directories of the versions are renamed, which the
+			// memory filesystem doesn't do for their files
+			fs := afero.NewBasePathFs(afero.NewOsFs(), t.TempDir())
+			s := New(fs, test.maxCount, 0)
+			saveContents(t, fs, s, "/dst.txt", test.dst...)
+			saveContents(t, fs, s, "/src.txt", test.src...)
+
+			require.NoError(t, s.Move("/src.txt", "/dst.txt"))
+			require.Equal(t, test.wantSrc, versionContents(t, s, "/src.txt"))
+			require.Equal(t, test.wantDst, versionContents(t, s, "/dst.txt"))
+		})
+	}
+}
+
+func TestStore_MoveDir(t *testing.T) {
+	fs := afero.NewBasePathFs(afero.NewOsFs(), t.TempDir())
+	s := New(fs, 5, 0)
+	saveContents(t, fs, s, "/src/a.txt", "a1")
+	saveContents(t, fs, s, "/src/sub/b.txt", "b1")
+	saveContents(t, fs, s, "/dst/sub/b.txt", "b0")
+
+	require.NoError(t, s.Move("/src", "/dst"))
+	require.Equal(t, []string{"a1"}, versionContents(t, s, "/dst/a.txt"))
+	require.Equal(t, []string{"b0", "b1"}, versionContents(t, s, "/dst/sub/b.txt"))
+
+	exists, err := afero.DirExists(fs, Path("/src"))
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestStore_Remove(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	s := New(fs, 5, 0)
+	saveContents(t, fs, s, "/dir/a.txt", "1")
+	saveContents(t, fs, s, "/b.txt", "1")
+
+	require.NoError(t, s.Remove("/dir"))
+	require.Empty(t, versionContents(t, s, "/dir/a.txt"))
+	require.Equal(t, []string{"1"}, versionContents(t, s, "/b.txt"))
+
+	// removing the versions of a file without any succeeds
+	require.NoError(t, s.Remove("/c.txt"))
+}
+
+func TestStore_PruneAll(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	s := New(fs, 0, time.Hour)
+	saveContents(t, fs, s, "/dir/a.txt", "new")
+
+	// a version saved long ago of a file that was never saved since
+	old := time.Now().Add(-2 * time.Hour).UTC().Format(idLayout)
+	require.NoError(t, afero.WriteFile(fs, path.Join(Path("/dir/a.txt"), old), []byte("old"), 0644))
+	require.NoError(t, afero.WriteFile(fs, path.Join(Path("/b.txt"), old), []byte("old"), 0644))
+
+	require.NoError(t, s.PruneAll(context.Background()))
+	require.Equal(t, []string{"new"}, versionContents(t, s, "/dir/a.txt"))
+	require.Empty(t, versionContents(t, s, "/b.txt"))
+
+	exists, err := afero.DirExists(fs, Path("/b.txt"))
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// a store without versions has nothing to prune
+	require.NoError(t, New(afero.NewMemMapFs(), 0, time.Hour).PruneAll(context.Background()))
+}
+
+func TestIsVersionsPath(t *testing.T) {
+	cases := map[string]bool{
+		"/.versions":        true,
+		"/.versions/a.txt":  true,
+		".versions":         true,
+		"/.versionsx":       false,
+		"/docs/.versions/a": false,
+		"/":                 false,
+	}
+
+	for p, want := range cases {
+		if got := IsVersionsPath(p); got != want {
+			t.Errorf("IsVersionsPath(%s)=%v; want %v", p, got, want)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+// Package watch notifies the changes made to directories, whether they
+// come from File Browser itself or from other programs.
+package watch
+
+import (
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Op is the kind of a change.
+type Op string
+
+const (
+	OpCreate Op = "create"
+	OpWrite  Op = "write"
+	OpRemove Op = "remove"
+	OpRename Op = "rename"
+)
+
+// Event is a change of an entry of a watched directory.
+type Event struct {
+	Op   Op     `json:"op"`
+	Path string `json:"path"`
+}
+
+// Filter maps the host path of a changed entry to the path reported to
+// a subscriber. Changes it returns false for are not reported.
+type Filter func(name string) (string, bool)
+
+// Hub shares a single fsnotify watcher between all the subscribers and
+// watches each directory once, however many subscribers it has.
+type Hub struct {
+	delay   time.Duration
+	watcher *fsnotify.Watcher
+
+	mu   sync.Mutex
+	dirs map[string]map[*Subscription]struct{}
+}
+
+// NewHub creates a hub. The changes are delivered in batches at most
+// every delay, with the successive changes of an entry merged.
+func NewHub(delay time.Duration) (*Hub, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Hub{
+		delay:   delay,
+		watcher: watcher,
+		dirs:    map[string]map[*Subscription]struct{}{},
+	}
+	go h.run()
+
+	return h, nil
+}
+
+// Subscribe starts reporting the changes of the entries of dir, which
+// is a directory of the host filesystem.
+func (h *Hub) Subscribe(dir string, filter Filter) (*Subscription, error) {
+	dir = filepath.Clean(dir)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subs, ok := h.dirs[dir]
+	if !ok {
+		if err := h.watcher.Add(dir); err != nil {
+			return nil, err
+		}
+		subs = map[*Subscription]struct{}{}
+		h.dirs[dir] = subs
+	}
+
+	sub := &Subscription{
+		hub:     h,
+		dir:     dir,
+		filter:  filter,
+		events:  make(chan []Event, 1),
+		pending: map[string]Op{},
+	}
+	subs[sub] = struct{}{}
+
+	return sub, nil
+}
+
+// Close stops the hub. The subscriptions stop receiving events.
+func (h *Hub) Close() error {
+	return h.watcher.Close()
+}
+
+func (h *Hub) unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subs := h.dirs[sub.dir]
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.dirs, sub.dir)
+		_ = h.watcher.Remove(sub.dir)
+	}
+}
+
+func (h *Hub) run() {
+	for {
+		select {
+		case event, ok := <-h.watcher.Events:
+			if !ok {
+				return
+			}
+			h.dispatch(event)
+		case err, ok := <-h.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("watch: %v", err)
+		}
+	}
+}
+
+func (h *Hub) dispatch(event fsnotify.Event) {
+	var op Op
+	switch {
+	case event.Has(fsnotify.Create):
+		op = OpCreate
+	case event.Has(fsnotify.Remove):
+		op = OpRemove
+	case event.Has(fsnotify.Rename):
+		op = OpRename
+	case event.Has(fsnotify.Write):
+		op = OpWrite
+	default:
+		return
+	}
+
+	name := filepath.Clean(event.Name)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.dirs[filepath.Dir(name)] {
+		sub.add(name, op)
+	}
+	// the watched directory itself went away
+	if op == OpRemove || op == OpRename {
+		for sub := range h.dirs[name] {
+			sub.add(name, op)
+		}
+	}
+}
+
+// Subscription receives the changes of a directory.
+type Subscription struct {
+	hub    *Hub
+	dir    string
+	filter Filter
+	events chan []Event
+
+	mu      sync.Mutex
+	order   []string
+	pending map[string]Op
+	timer   *time.Timer
+	closed  bool
+}
+
+// Events returns the channel the batches of changes are sent to.
+func (s *Subscription) Events() <-chan []Event {
+	return s.events
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.hub.unsubscribe(s)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+func (s *Subscription) add(name string, op Op) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	prev, ok := s.pending[name]
+	if !ok {
+		s.order = append(s.order, name)
+		s.pending[name] = op
+	} else if merged, keep := merge(prev, op); keep {
+		s.pending[name] = merged
+	} else {
+		delete(s.pending, name)
+	}
+
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.hub.delay, s.flush)
+	}
+}
+
+// merge combines two successive changes of the same entry into the
+// one a client comparing the before and after states would see. It
+// returns false if they cancel each other out.
+func merge(prev, next Op) (Op, bool) {
+	switch {
+	case prev == OpCreate && next == OpWrite:
+		return OpCreate, true
+	case prev == OpCreate && (next == OpRemove || next == OpRename):
+		return "", false
+	case (prev == OpRemove || prev == OpRename) && next == OpCreate:
+		return OpWrite, true
+	default:
+		return next, true
+	}
+}
+
+func (s *Subscription) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.timer = nil
+	if s.closed {
+		return
+	}
+
+	batch := []Event{}
+	seen := map[string]bool{}
+	for _, name := range s.order {
+		op, ok := s.pending[name]
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if s.filter != nil {
+			if name, ok = s.filter(name); !ok {
+				continue
+			}
+		}
+		batch = append(batch, Event{Op: op, Path: name})
+	}
+	if len(batch) == 0 {
+		s.order, s.pending = nil, map[string]Op{}
+		return
+	}
+
+	select {
+	case s.events <- batch:
+		s.order, s.pending = nil, map[string]Op{}
+	default:
+		// the subscriber is busy, keep merging until the next attempt
+		s.timer = time.AfterFunc(s.hub.delay, s.flush)
+	}
+}
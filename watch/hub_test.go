@@ -0,0 +1,60 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func nextBatch(t *testing.T, sub *Subscription) []Event {
+	t.Helper()
+
+	select {
+	case batch := <-sub.Events():
+		return batch
+	case <-time.After(5 * time.Second):
+		t.Fatal("no events received")
+		return nil
+	}
+}
+
+func TestHub(t *testing.T) {
+	dir := t.TempDir()
+
+	hub, err := NewHub(100 * time.Millisecond)
+	require.NoError(t, err)
+	defer hub.Close()
+
+	sub, err := hub.Subscribe(dir, func(name string) (string, bool) {
+		rel, err := filepath.Rel(dir, name)
+		return rel, err == nil && rel != "hidden.txt"
+	})
+	require.NoError(t, err)
+	defer sub.Close()
+
+	// create and successive writes are reported once
+	name := filepath.Join(dir, "a.txt")
+	require.NoError(t, os.WriteFile(name, []byte("a"), 0644))
+	require.NoError(t, os.WriteFile(name, []byte("ab"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hidden.txt"), []byte("h"), 0644))
+	require.Equal(t, []Event{{Op: OpCreate, Path: "a.txt"}}, nextBatch(t, sub))
+
+	require.NoError(t, os.WriteFile(name, []byte("abc"), 0644))
+	require.Equal(t, []Event{{Op: OpWrite, Path: "a.txt"}}, nextBatch(t, sub))
+
+	require.NoError(t, os.Rename(name, filepath.Join(dir, "b.txt")))
+	require.ElementsMatch(t, []Event{
+		{Op: OpRename, Path: "a.txt"},
+		{Op: OpCreate, Path: "b.txt"},
+	}, nextBatch(t, sub))
+
+	// a file created and removed in the same batch is not reported
+	tmp := filepath.Join(dir, "tmp.txt")
+	require.NoError(t, os.WriteFile(tmp, nil, 0644))
+	require.NoError(t, os.Remove(tmp))
+	require.NoError(t, os.Remove(filepath.Join(dir, "b.txt")))
+	require.Equal(t, []Event{{Op: OpRemove, Path: "b.txt"}}, nextBatch(t, sub))
+}